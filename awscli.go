@@ -1,6 +1,21 @@
 // Package awscli - smaller aws toolkit
 package awscli
 
+import (
+	"flag"
+	"os"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/credentials/ec2rolecreds"
+	"github.com/aws/aws-sdk-go/aws/session"
+)
+
+// DefaultRegion - region used when none can be resolved from flags, env, profile or
+// instance metadata
+const DefaultRegion = "us-east-1"
+
 // AwsCli - base struct
 type AwsCli struct {
 	Version   int    `json:"version"`
@@ -9,15 +24,111 @@ type AwsCli struct {
 	Token     string `json:"token"`
 	Region    string `json:"region"`
 	Account   string `json:"account"`
+	Profile   string `json:"profile"`
+
+	// EndpointURL - -endpoint-url override, see Endpoint
+	EndpointURL string `json:"endpoint_url"`
+	// S3PathStyle - force path style s3 addressing for minio and friends
+	S3PathStyle bool `json:"s3_path_style"`
 }
 
 // New - returns a new pointer to AwsCli
 func New(version int, keyID, accessKey, token, region, account string) *AwsCli {
 	return &AwsCli{
 		Version:   version,
+		KeyID:     keyID,
 		AccessKey: accessKey,
 		Token:     token,
 		Region:    region,
 		Account:   account,
 	}
 }
+
+// SetFlags - register the shared credential and endpoint flags every tool accepts on fs
+func (a *AwsCli) SetFlags(fs *flag.FlagSet) {
+	fs.StringVar(&a.Profile, "profile", a.Profile, "AWS shared credentials profile. E.g. -profile=default")
+	fs.StringVar(&a.KeyID, "access-key-id", a.KeyID, "AWS access key id, overrides env and profile credentials")
+	fs.StringVar(&a.AccessKey, "secret-access-key", a.AccessKey, "AWS secret access key, overrides env and profile credentials")
+	fs.StringVar(&a.Token, "session-token", a.Token, "AWS session token for temporary credentials")
+	fs.StringVar(&a.EndpointURL, "endpoint-url", a.EndpointURL, "override the service endpoint. E.g. -endpoint-url=http://localhost:4566 or -endpoint-url='sqs=http://localhost:9324,s3=http://localhost:9000'")
+	fs.BoolVar(&a.S3PathStyle, "s3-path-style", a.S3PathStyle, "use path style s3 addressing (http://host/bucket/key), required by most s3 stand-ins")
+}
+
+// GetProfile - return the profile in use: -profile, then $AWS_PROFILE, then 'default'
+func (a *AwsCli) GetProfile() string {
+	if a.Profile != "" {
+		return a.Profile
+	}
+	if profile := os.Getenv("AWS_PROFILE"); profile != "" {
+		return profile
+	}
+	return "default"
+}
+
+// GetRegion - return the region in use: -region, then $AWS_REGION, $AWS_DEFAULT_REGION,
+// the profile's region in the shared config file, the instance's region from ec2
+// metadata and finally DefaultRegion
+func (a *AwsCli) GetRegion() string {
+	if a.Region != "" {
+		return a.Region
+	}
+	for _, env := range []string{"AWS_REGION", "AWS_DEFAULT_REGION"} {
+		if region := os.Getenv(env); region != "" {
+			return region
+		}
+	}
+	if region := ProfileValue(a.GetProfile(), "region"); region != "" {
+		return region
+	}
+	if doc, err := a.Identity(); err == nil && doc.Region != "" {
+		return doc.Region
+	}
+	return DefaultRegion
+}
+
+// Credentials - build the credential chain shared by every tool.
+//
+// Static credentials from flags win, followed by the environment, the shared
+// credentials file and finally the EC2 instance role, unless
+// $AWS_EC2_METADATA_DISABLED is set. An explicit -profile skips the
+// environment and instance role so the wrong account is never used silently.
+func (a *AwsCli) Credentials() *credentials.Credentials {
+	var providers []credentials.Provider
+	if a.KeyID != "" || a.AccessKey != "" {
+		providers = append(providers, &credentials.StaticProvider{Value: credentials.Value{
+			AccessKeyID:     a.KeyID,
+			SecretAccessKey: a.AccessKey,
+			SessionToken:    a.Token,
+		}})
+	}
+
+	if a.Profile != "" {
+		providers = append(providers, &credentials.SharedCredentialsProvider{Profile: a.Profile})
+	} else {
+		providers = append(providers, &credentials.EnvProvider{}, &credentials.SharedCredentialsProvider{})
+		if !MetadataDisabled() {
+			providers = append(providers, &ec2rolecreds.EC2RoleProvider{
+				Client:       a.MetadataClient(),
+				ExpiryWindow: 5 * time.Minute,
+			})
+		}
+	}
+
+	return credentials.NewCredentials(&credentials.ChainProvider{
+		VerboseErrors: true,
+		Providers:     providers,
+	})
+}
+
+// Config - return an aws.Config with the resolved region and credentials
+func (a *AwsCli) Config() *aws.Config {
+	return aws.NewConfig().
+		WithRegion(a.GetRegion()).
+		WithCredentials(a.Credentials()).
+		WithCredentialsChainVerboseErrors(true)
+}
+
+// Session - return a new session for service clients to share
+func (a *AwsCli) Session() *session.Session {
+	return session.New(a.Config())
+}
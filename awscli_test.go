@@ -0,0 +1,263 @@
+package awscli_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/aidevops/awscli"
+	"github.com/aidevops/awscli/fakeaws"
+)
+
+var _ = Describe("AwsCli", func() {
+
+	var (
+		dir     string
+		saved   map[string]string
+		envKeys = []string{
+			"AWS_ACCESS_KEY_ID", "AWS_ACCESS_KEY", "AWS_SECRET_ACCESS_KEY", "AWS_SECRET_KEY",
+			"AWS_SESSION_TOKEN", "AWS_PROFILE", "AWS_REGION", "AWS_DEFAULT_REGION",
+			"AWS_SHARED_CREDENTIALS_FILE", "AWS_CONFIG_FILE",
+			"AWS_ENDPOINT_URL", "AWS_ENDPOINT_URL_SQS", "AWS_S3_FORCE_PATH_STYLE",
+			"AWS_EC2_METADATA_DISABLED", "AWS_EC2_METADATA_SERVICE_ENDPOINT", "AWS_EC2_METADATA_V1_DISABLED",
+		}
+	)
+
+	BeforeEach(func() {
+		var err error
+		dir, err = ioutil.TempDir("", "awscli")
+		Expect(err).NotTo(HaveOccurred())
+
+		saved = make(map[string]string)
+		for _, key := range envKeys {
+			saved[key] = os.Getenv(key)
+			os.Unsetenv(key)
+		}
+		// keep the instance role and region lookups off 169.254.169.254
+		os.Setenv("AWS_EC2_METADATA_DISABLED", "true")
+
+		credentials := "[default]\naws_access_key_id = default-id\naws_secret_access_key = default-secret\n\n" +
+			"[staging]\naws_access_key_id = staging-id\naws_secret_access_key = staging-secret\n"
+		config := "[default]\nregion = eu-west-1\n\n[profile staging]\nregion = ap-southeast-2\n" +
+			"endpoint_url = http://localhost:4566\ns3_endpoint_url = http://localhost:9000\ns3_force_path_style = true\n"
+		Expect(ioutil.WriteFile(filepath.Join(dir, "credentials"), []byte(credentials), 0600)).To(Succeed())
+		Expect(ioutil.WriteFile(filepath.Join(dir, "config"), []byte(config), 0600)).To(Succeed())
+		os.Setenv("AWS_SHARED_CREDENTIALS_FILE", filepath.Join(dir, "credentials"))
+		os.Setenv("AWS_CONFIG_FILE", filepath.Join(dir, "config"))
+	})
+
+	AfterEach(func() {
+		for key, value := range saved {
+			if value == "" {
+				os.Unsetenv(key)
+			} else {
+				os.Setenv(key, value)
+			}
+		}
+		os.RemoveAll(dir)
+	})
+
+	Describe("New", func() {
+		It("Keeps the key id", func() {
+			cli := awscli.New(1, "id", "secret", "token", "us-west-2", "123456789012")
+			Expect(cli.KeyID).To(Equal("id"))
+			Expect(cli.AccessKey).To(Equal("secret"))
+		})
+	})
+
+	Describe("Credentials", func() {
+		It("Prefers static credentials from flags", func() {
+			os.Setenv("AWS_ACCESS_KEY_ID", "env-id")
+			os.Setenv("AWS_SECRET_ACCESS_KEY", "env-secret")
+			cli := awscli.New(1, "flag-id", "flag-secret", "", "", "")
+			value, err := cli.Credentials().Get()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(value.AccessKeyID).To(Equal("flag-id"))
+		})
+		It("Falls back to the environment", func() {
+			os.Setenv("AWS_ACCESS_KEY_ID", "env-id")
+			os.Setenv("AWS_SECRET_ACCESS_KEY", "env-secret")
+			value, err := (&awscli.AwsCli{}).Credentials().Get()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(value.AccessKeyID).To(Equal("env-id"))
+		})
+		It("Uses the default profile without env credentials", func() {
+			value, err := (&awscli.AwsCli{}).Credentials().Get()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(value.AccessKeyID).To(Equal("default-id"))
+		})
+		It("Uses an explicit profile over the environment", func() {
+			os.Setenv("AWS_ACCESS_KEY_ID", "env-id")
+			os.Setenv("AWS_SECRET_ACCESS_KEY", "env-secret")
+			value, err := (&awscli.AwsCli{Profile: "staging"}).Credentials().Get()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(value.AccessKeyID).To(Equal("staging-id"))
+		})
+		It("Fails on a missing profile instead of falling back", func() {
+			os.Setenv("AWS_ACCESS_KEY_ID", "env-id")
+			os.Setenv("AWS_SECRET_ACCESS_KEY", "env-secret")
+			_, err := (&awscli.AwsCli{Profile: "missing"}).Credentials().Get()
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	Describe("GetRegion", func() {
+		It("Prefers the flag", func() {
+			os.Setenv("AWS_REGION", "us-west-1")
+			Expect((&awscli.AwsCli{Region: "us-west-2"}).GetRegion()).To(Equal("us-west-2"))
+		})
+		It("Falls back to the environment", func() {
+			os.Setenv("AWS_DEFAULT_REGION", "us-west-1")
+			Expect((&awscli.AwsCli{}).GetRegion()).To(Equal("us-west-1"))
+		})
+		It("Reads the profile's region", func() {
+			Expect((&awscli.AwsCli{}).GetRegion()).To(Equal("eu-west-1"))
+			Expect((&awscli.AwsCli{Profile: "staging"}).GetRegion()).To(Equal("ap-southeast-2"))
+		})
+		It("Falls back to the instance's region", func() {
+			md := fakeaws.NewMetadata()
+			defer md.Close()
+			md.Region = "sa-east-1"
+			os.Unsetenv("AWS_EC2_METADATA_DISABLED")
+			os.Setenv("AWS_CONFIG_FILE", filepath.Join(dir, "missing"))
+			Expect((&awscli.AwsCli{EndpointURL: "ec2metadata=" + md.URL}).GetRegion()).To(Equal("sa-east-1"))
+		})
+		It("Defaults to us-east-1", func() {
+			os.Setenv("AWS_CONFIG_FILE", filepath.Join(dir, "missing"))
+			Expect((&awscli.AwsCli{}).GetRegion()).To(Equal(awscli.DefaultRegion))
+		})
+	})
+
+	Describe("Endpoint", func() {
+		It("Uses the regional endpoint without overrides", func() {
+			cli := &awscli.AwsCli{}
+			Expect(cli.Endpoint("sqs")).To(BeEmpty())
+			Expect(cli.ServiceConfig("sqs").Endpoint).To(BeNil())
+		})
+		It("Applies a single -endpoint-url to every service", func() {
+			cli := &awscli.AwsCli{EndpointURL: "http://localhost:4566"}
+			Expect(cli.Endpoint("sqs")).To(Equal("http://localhost:4566"))
+			Expect(cli.Endpoint("ec2")).To(Equal("http://localhost:4566"))
+		})
+		It("Applies per service -endpoint-url pairs", func() {
+			cli := &awscli.AwsCli{EndpointURL: "sqs=http://localhost:9324,s3=http://localhost:9000"}
+			Expect(cli.Endpoint("s3")).To(Equal("http://localhost:9000"))
+			Expect(cli.Endpoint("sqs")).To(Equal("http://localhost:9324"))
+			Expect(cli.Endpoint("ec2")).To(BeEmpty())
+		})
+		It("Prefers the service env var over the global one", func() {
+			os.Setenv("AWS_ENDPOINT_URL", "http://global:1")
+			os.Setenv("AWS_ENDPOINT_URL_SQS", "http://sqs:2")
+			cli := &awscli.AwsCli{}
+			Expect(cli.Endpoint("sqs")).To(Equal("http://sqs:2"))
+			Expect(cli.Endpoint("ec2")).To(Equal("http://global:1"))
+		})
+		It("Reads the profile's endpoints and s3 addressing", func() {
+			cli := &awscli.AwsCli{Profile: "staging"}
+			Expect(cli.Endpoint("ec2")).To(Equal("http://localhost:4566"))
+			Expect(cli.Endpoint("s3")).To(Equal("http://localhost:9000"))
+			cfg := cli.ServiceConfig("s3")
+			Expect(*cfg.Endpoint).To(Equal("http://localhost:9000"))
+			Expect(*cfg.S3ForcePathStyle).To(BeTrue())
+		})
+	})
+	Describe("Metadata", func() {
+		var md *fakeaws.Metadata
+
+		BeforeEach(func() {
+			md = fakeaws.NewMetadata()
+			os.Unsetenv("AWS_EC2_METADATA_DISABLED")
+			os.Setenv("AWS_EC2_METADATA_SERVICE_ENDPOINT", md.URL)
+		})
+
+		AfterEach(func() {
+			md.Close()
+		})
+
+		It("Prefers an ec2metadata -endpoint-url pair and ignores a single -endpoint-url", func() {
+			Expect((&awscli.AwsCli{EndpointURL: "ec2metadata=http://imds:1,sqs=http://sqs:2"}).MetadataEndpoint()).To(Equal("http://imds:1"))
+			Expect((&awscli.AwsCli{EndpointURL: "http://localhost:4566"}).MetadataEndpoint()).To(Equal(md.URL))
+		})
+		It("Discovers the account and instance id", func() {
+			cli := &awscli.AwsCli{}
+			Expect(cli.GetAccount()).To(Equal(fakeaws.DefaultAccount))
+			Expect(cli.InstanceID()).To(Equal(fakeaws.DefaultInstanceID))
+			Expect(md.Requests()).To(Equal([]string{"PUT /latest/api/token", "GET /latest/dynamic/instance-identity/document"}))
+		})
+		It("Prefers the -account flag", func() {
+			Expect((&awscli.AwsCli{Account: "210987654321"}).GetAccount()).To(Equal("210987654321"))
+			Expect(md.Requests()).To(BeEmpty())
+		})
+		It("Reuses one IMDSv2 token across requests", func() {
+			md.RequireToken = true
+			for i := 0; i < 2; i++ {
+				id, err := (&awscli.AwsCli{}).MetadataClient().GetMetadata("instance-id")
+				Expect(err).NotTo(HaveOccurred())
+				Expect(id).To(Equal(md.InstanceID))
+			}
+			Expect(md.TokensIssued()).To(Equal(1))
+		})
+		It("Fetches a new token when the cached one is rejected", func() {
+			md.RequireToken = true
+			client := (&awscli.AwsCli{}).MetadataClient()
+			_, err := client.GetMetadata("instance-id")
+			Expect(err).NotTo(HaveOccurred())
+			md.ExpireTokens()
+			_, err = client.GetMetadata("instance-id")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(md.TokensIssued()).To(Equal(2))
+		})
+		It("Renews tokens before the granted ttl runs out", func() {
+			token := &awscli.MetadataToken{Endpoint: md.URL + "/latest", TTL: time.Hour}
+			md.MaxTokenTTL = 2 * time.Second
+			first, err := token.Token()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(token.Token()).To(Equal(first))
+			time.Sleep(1100 * time.Millisecond)
+			Expect(token.Token()).NotTo(Equal(first))
+		})
+		It("Falls back to IMDSv1 without a token service", func() {
+			md.NoTokens = true
+			Expect((&awscli.AwsCli{}).InstanceID()).To(Equal(md.InstanceID))
+		})
+		It("Fails without a token service when IMDSv1 is disabled", func() {
+			md.NoTokens = true
+			os.Setenv("AWS_EC2_METADATA_V1_DISABLED", "true")
+			_, err := (&awscli.AwsCli{}).MetadataClient().GetMetadata("instance-id")
+			Expect(err).To(HaveOccurred())
+		})
+		It("Never falls back when the token service refuses", func() {
+			md.Disabled = true
+			token := &awscli.MetadataToken{Endpoint: md.URL + "/latest", AllowV1: true}
+			_, err := token.Token()
+			Expect(err).To(HaveOccurred())
+			Expect(md.Requests()).To(Equal([]string{"PUT /latest/api/token"}))
+		})
+		It("Reads instance role credentials with a token", func() {
+			md.RequireToken = true
+			os.Setenv("AWS_SHARED_CREDENTIALS_FILE", filepath.Join(dir, "missing"))
+			value, err := (&awscli.AwsCli{}).Credentials().Get()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(value.AccessKeyID).To(Equal("AKIDFAKEROLE"))
+			Expect(value.SessionToken).To(Equal("fakeaws-role-token"))
+		})
+		It("Stays off the service when disabled", func() {
+			os.Setenv("AWS_EC2_METADATA_DISABLED", "true")
+			_, err := (&awscli.AwsCli{}).InstanceID()
+			Expect(err).To(Equal(awscli.ErrMetadataDisabled))
+			Expect((&awscli.AwsCli{}).GetAccount()).To(BeEmpty())
+			Expect(md.Requests()).To(BeEmpty())
+		})
+		It("Leaves the instance role out of the credentials when disabled", func() {
+			os.Setenv("AWS_EC2_METADATA_DISABLED", "true")
+			os.Setenv("AWS_SHARED_CREDENTIALS_FILE", filepath.Join(dir, "missing"))
+			_, err := (&awscli.AwsCli{}).Credentials().Get()
+			Expect(err).To(HaveOccurred())
+			Expect(md.Requests()).To(BeEmpty())
+		})
+	})
+})
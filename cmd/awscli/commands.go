@@ -13,8 +13,24 @@ import (
 // Commands - map for sub commands
 var Commands map[string]cli.CommandFactory
 
+// Tools - the standalone tools folded into awscli, keyed by the name awscli is
+// invoked as through a symlink, mapped to the sub command that replaces them
+var Tools = map[string]string{
+	"ec2_tag":     "ec2 tag",
+	"ecr_login":   "ecr login",
+	"s3_util":     "s3",
+	"sg_register": "sg",
+	"sqs_util":    "sqs",
+}
+
 func init() {
-	ui := &cli.BasicUi{Writer: os.Stdout}
+	ui := &cli.BasicUi{Reader: os.Stdin, Writer: os.Stdout}
+	meta := command.Meta{
+		UI:                ui,
+		Revision:          GitCommit,
+		Version:           Version,
+		VersionPrerelease: VersionPrerelease,
+	}
 
 	Commands = map[string]cli.CommandFactory{
 		"ec2": func() (cli.Command, error) {
@@ -23,18 +39,156 @@ func init() {
 			}, nil
 		},
 
+		"ec2 instances": func() (cli.Command, error) {
+			return &command.EC2InstancesCommand{
+				Meta: meta,
+			}, nil
+		},
+
+		"ec2 inventory": func() (cli.Command, error) {
+			return &command.EC2InventoryCommand{
+				Meta: meta,
+			}, nil
+		},
+
+		"ec2 reboot": func() (cli.Command, error) {
+			return &command.EC2StateCommand{
+				Meta:   meta,
+				Action: "reboot",
+			}, nil
+		},
+
+		"ec2 start": func() (cli.Command, error) {
+			return &command.EC2StateCommand{
+				Meta:   meta,
+				Action: "start",
+			}, nil
+		},
+
+		"ec2 stop": func() (cli.Command, error) {
+			return &command.EC2StateCommand{
+				Meta:   meta,
+				Action: "stop",
+			}, nil
+		},
+
+		"ec2 tag": func() (cli.Command, error) {
+			return &command.EC2TagCommand{
+				Meta: meta,
+			}, nil
+		},
+
+		"ec2 terminate": func() (cli.Command, error) {
+			return &command.EC2StateCommand{
+				Meta:   meta,
+				Action: "terminate",
+			}, nil
+		},
+
+		"ec2 wait": func() (cli.Command, error) {
+			return &command.EC2WaitCommand{
+				Meta: meta,
+			}, nil
+		},
+
 		"ecr": func() (cli.Command, error) {
 			return &command.ECRCommand{
 				UI: ui,
 			}, nil
 		},
 
+		"ecr login": func() (cli.Command, error) {
+			return &command.ECRLoginCommand{
+				Meta: meta,
+			}, nil
+		},
+
 		"ecs": func() (cli.Command, error) {
 			return &command.ECSCommand{
 				UI: ui,
 			}, nil
 		},
 
+		"metadata serve": func() (cli.Command, error) {
+			return &command.MetadataServeCommand{
+				Meta: meta,
+			}, nil
+		},
+
+		"s3": func() (cli.Command, error) {
+			return &command.S3Command{
+				Meta: meta,
+			}, nil
+		},
+
+		"s3 get": func() (cli.Command, error) {
+			return &command.S3Command{
+				Meta: meta,
+				Mode: "get",
+			}, nil
+		},
+
+		"s3 put": func() (cli.Command, error) {
+			return &command.S3Command{
+				Meta: meta,
+				Mode: "put",
+			}, nil
+		},
+
+		"sg": func() (cli.Command, error) {
+			return &command.SGCommand{
+				Meta: meta,
+			}, nil
+		},
+
+		"sg register": func() (cli.Command, error) {
+			return &command.SGCommand{
+				Meta: meta,
+				Mode: "register",
+			}, nil
+		},
+
+		"sg deregister": func() (cli.Command, error) {
+			return &command.SGCommand{
+				Meta: meta,
+				Mode: "deregister",
+			}, nil
+		},
+
+		"sg sync": func() (cli.Command, error) {
+			return &command.SGCommand{
+				Meta: meta,
+				Mode: "sync",
+			}, nil
+		},
+
+		"sg reap": func() (cli.Command, error) {
+			return &command.SGCommand{
+				Meta: meta,
+				Mode: "reap",
+			}, nil
+		},
+
+		"sqs": func() (cli.Command, error) {
+			return &command.SQSCommand{
+				Meta: meta,
+			}, nil
+		},
+
+		"sqs send": func() (cli.Command, error) {
+			return &command.SQSCommand{
+				Meta: meta,
+				Mode: "send",
+			}, nil
+		},
+
+		"sqs recv": func() (cli.Command, error) {
+			return &command.SQSCommand{
+				Meta: meta,
+				Mode: "recv",
+			}, nil
+		},
+
 		"version": func() (cli.Command, error) {
 			return &command.VersionCommand{
 				Revision:          GitCommit,
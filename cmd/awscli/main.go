@@ -5,26 +5,16 @@ package main
 import (
 	"fmt"
 	"os"
+	"path/filepath"
+	"strings"
 
 	"github.com/mitchellh/cli"
 )
 
 // main - run our app
 func main() {
-	args := os.Args[1:]
-
-	for _, arg := range args {
-		if arg == "-v" || arg == "--version" {
-			newArgs := make([]string, len(args)+1)
-			newArgs[0] = "version"
-			copy(newArgs[1:], args)
-			args = newArgs
-			break
-		}
-	}
-
 	cli := &cli.CLI{
-		Args:     args,
+		Args:     Args(os.Args[0], os.Args[1:]),
 		Commands: Commands,
 		HelpFunc: cli.BasicHelpFunc("awscli"),
 	}
@@ -36,3 +26,22 @@ func main() {
 
 	os.Exit(exitCode)
 }
+
+// Args - the cli arguments for an invocation as name, when awscli runs through a
+// symlink named after one of the standalone Tools its sub command is prepended and
+// the tool's flags, -version included, are passed through untouched
+func Args(name string, args []string) []string {
+	if sub, ok := Tools[strings.TrimSuffix(filepath.Base(name), ".exe")]; ok {
+		return append(strings.Fields(sub), args...)
+	}
+
+	for _, arg := range args {
+		if arg == "-v" || arg == "--version" {
+			newArgs := make([]string, len(args)+1)
+			newArgs[0] = "version"
+			copy(newArgs[1:], args)
+			return newArgs
+		}
+	}
+	return args
+}
@@ -1 +1,24 @@
-package main_test
+package main
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Args", func() {
+	It("Prepends the sub command replacing a standalone tool", func() {
+		Expect(Args("/bin/ec2_tag", []string{"-resources=i-1"})).To(Equal([]string{"ec2", "tag", "-resources=i-1"}))
+		Expect(Args("sqs_util", []string{"-send", "--version"})).To(Equal([]string{"sqs", "-send", "--version"}))
+	})
+
+	It("Runs the version command for -v anywhere when invoked as awscli", func() {
+		Expect(Args("/usr/local/bin/awscli", []string{"ec2", "-v"})).To(Equal([]string{"version", "ec2", "-v"}))
+		Expect(Args("awscli", []string{"sqs", "send"})).To(Equal([]string{"sqs", "send"}))
+	})
+
+	It("Maps every tool to a registered command", func() {
+		for _, sub := range Tools {
+			Expect(Commands).To(HaveKey(sub))
+		}
+	})
+})
@@ -0,0 +1,20 @@
+package command_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/onsi/ginkgo/reporters"
+
+	"os"
+	"testing"
+)
+
+func TestCommand(t *testing.T) {
+	RegisterFailHandler(Fail)
+	if os.Getenv("TEAMCITY") == "true" {
+		RunSpecsWithCustomReporters(t, "Command Test Suite", []Reporter{reporters.NewTeamCityReporter(os.Stdout)})
+	} else {
+		RunSpecs(t, "Command Test Suite")
+	}
+}
@@ -1 +1,549 @@
 package command_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/mitchellh/cli"
+
+	"github.com/aidevops/awscli/command"
+	"github.com/aidevops/awscli/fakeaws"
+)
+
+var _ = Describe("Command", func() {
+
+	var (
+		srv  *fakeaws.Server
+		ui   *cli.MockUi
+		meta command.Meta
+		args []string
+	)
+
+	BeforeEach(func() {
+		srv = fakeaws.New()
+		ui = new(cli.MockUi)
+		meta = command.Meta{UI: ui, Version: "0.0.4", VersionPrerelease: "dev", Revision: "abc123"}
+		args = []string{
+			"-access-key-id=AKIDFAKEAWS",
+			"-secret-access-key=fakeaws-secret",
+			"-endpoint-url=" + srv.URL,
+			"-region=" + srv.Region,
+		}
+	})
+
+	AfterEach(func() {
+		srv.Close()
+	})
+
+	Describe("ToMap", func() {
+		It("Splits comma separated pairs and unquotes values", func() {
+			Expect(command.ToMap(`hello=world,one="two",empty`)).To(Equal(map[string]string{"hello": "world", "one": "two", "empty": ""}))
+			Expect(command.ToMap("")).To(BeEmpty())
+		})
+
+		It("Refuses empty and repeated keys with ParseMap", func() {
+			Expect(command.ParseMap(`hello=world,one="two"`)).To(Equal(map[string]string{"hello": "world", "one": "two"}))
+			_, err := command.ParseMap("hello=world,=two")
+			Expect(err).To(MatchError("pair 2 of 'hello=world,=two' has no key"))
+			_, err = command.ParseMap("role=web,role=db")
+			Expect(err).To(MatchError("key 'role' is given twice in 'role=web,role=db'"))
+		})
+	})
+
+	Describe("ToSlice", func() {
+		It("Splits on whitespace", func() {
+			Expect(command.ToSlice(" i-1  i-2 ")).To(Equal([]string{"i-1", "i-2"}))
+			Expect(command.ToSlice("")).To(BeEmpty())
+		})
+	})
+
+	Describe("EC2TagCommand", func() {
+		It("Tags the resources and prints the result", func() {
+			c := &command.EC2TagCommand{Meta: meta}
+			code := c.Run(append(args, "-account="+srv.Account, "-resources=i-12345678", "-tags=Name=web,env=prod", "-output=text", "-query=Tags.Name"))
+			Expect(code).To(Equal(0), ui.ErrorWriter.String())
+			Expect(srv.Tags("i-12345678")).To(Equal(map[string]string{"Name": "web", "env": "prod"}))
+			Expect(ui.OutputWriter.String()).To(Equal("web\n"))
+		})
+
+		It("Tags the instance it runs on with -self", func() {
+			md := fakeaws.NewMetadata()
+			defer md.Close()
+			args[2] = "-endpoint-url=ec2=" + srv.URL + ",ec2metadata=" + md.URL
+			c := &command.EC2TagCommand{Meta: meta}
+			Expect(c.Run(append(args, "-self", "-tags=role=web"))).To(Equal(0), ui.ErrorWriter.String())
+			Expect(srv.Tags(md.InstanceID)).To(Equal(map[string]string{"role": "web"}))
+		})
+
+		It("Tags every instance matching -filter, reporting each", func() {
+			web := srv.AddInstance(&ec2.Instance{Tags: []*ec2.Tag{{Key: aws.String("role"), Value: aws.String("web")}}})
+			srv.AddInstance(&ec2.Instance{Tags: []*ec2.Tag{{Key: aws.String("role"), Value: aws.String("db")}}})
+			c := &command.EC2TagCommand{Meta: meta}
+			code := c.Run(append(args, "-account="+srv.Account, "-filter=tag:role=web,instance-state-name=running", "-tags=env=prod",
+				"-output=text", "-query=Resources[].[Resource,OK]"))
+			Expect(code).To(Equal(0), ui.ErrorWriter.String())
+			Expect(ui.OutputWriter.String()).To(Equal(web + "\tTrue\n"))
+			Expect(srv.Tags(web)).To(HaveKeyWithValue("env", "prod"))
+
+			c = &command.EC2TagCommand{Meta: meta}
+			Expect(c.Run(append(args, "-account="+srv.Account, "-filter=tag:role=none", "-tags=env=prod"))).To(Equal(254))
+			Expect(ui.ErrorWriter.String()).To(ContainSubstring("nothing matches"))
+		})
+
+		It("Exits 254 when some resources could not be tagged", func() {
+			web := srv.AddInstance(&ec2.Instance{Tags: []*ec2.Tag{{Key: aws.String("role"), Value: aws.String("web")}}})
+			c := &command.EC2TagCommand{Meta: meta}
+			code := c.Run(append(args, "-account="+srv.Account, "-filter=tag:role=web", "-resources=i-0badbad0", "-tags=env=prod"))
+			Expect(code).To(Equal(254))
+			Expect(ui.ErrorWriter.String()).To(ContainSubstring("failed to tag 1 of 2"))
+			Expect(srv.Tags(web)).To(HaveKeyWithValue("env", "prod"))
+		})
+
+		It("Propagates the instance's tags to its volumes with -propagate", func() {
+			vol := srv.AddVolume(&ec2.Volume{})
+			id := srv.AddInstance(&ec2.Instance{
+				BlockDeviceMappings: []*ec2.InstanceBlockDeviceMapping{{Ebs: &ec2.EbsInstanceBlockDevice{VolumeId: aws.String(vol)}}},
+				Tags:                []*ec2.Tag{{Key: aws.String("Name"), Value: aws.String("web-1")}},
+			})
+			c := &command.EC2TagCommand{Meta: meta}
+			code := c.Run(append(args, "-account="+srv.Account, "-resources="+id, "-tags=environment=prod,role=web",
+				"-propagate=volumes", "-propagate-keys=environment,role", "-output=text", "-query=Propagated.Changes[].[Resource,Key]"))
+			Expect(code).To(Equal(0), ui.ErrorWriter.String())
+			Expect(ui.OutputWriter.String()).To(Equal(vol + "\tenvironment\n" + vol + "\trole\n"))
+			Expect(srv.Tags(vol)).To(Equal(map[string]string{"environment": "prod", "role": "web"}))
+
+			c = &command.EC2TagCommand{Meta: meta}
+			Expect(c.Run(append(args, "-account="+srv.Account, "-resources="+id, "-propagate=volumes", "-list"))).To(Equal(255))
+		})
+
+		It("Checks tags against ec2's rules and a -policy before tagging", func() {
+			dir, err := ioutil.TempDir("", "command")
+			Expect(err).NotTo(HaveOccurred())
+			defer os.RemoveAll(dir)
+			policy := filepath.Join(dir, "policy.yaml")
+			Expect(ioutil.WriteFile(policy, []byte("required: [environment, role]\nallowed:\n  environment: ^(dev|prod)$\n"), 0600)).To(Succeed())
+
+			c := &command.EC2TagCommand{Meta: meta}
+			Expect(c.Run(append(args, "-account="+srv.Account, "-resources=i-12345678", "-tags=aws:cost=1,environment=qa", "-policy="+policy))).To(Equal(255))
+			Expect(ui.ErrorWriter.String()).To(ContainSubstring("tag 'aws:cost': the aws: prefix is reserved for aws"))
+			Expect(ui.ErrorWriter.String()).To(ContainSubstring("tag 'environment': value 'qa' doesn't match the policy's ^(dev|prod)$"))
+
+			c = &command.EC2TagCommand{Meta: meta}
+			Expect(c.Run(append(args, "-account="+srv.Account, "-resources=i-12345678", "-tags=environment=prod", "-policy="+policy))).To(Equal(255))
+			Expect(ui.ErrorWriter.String()).To(ContainSubstring("i-12345678: tag 'role': required by the policy"))
+
+			c = &command.EC2TagCommand{Meta: meta}
+			Expect(c.Run(append(args, "-account="+srv.Account, "-resources=i-12345678", "-tags=role=web,role=db"))).To(Equal(255))
+			Expect(srv.CallCount("CreateTags")).To(Equal(0))
+
+			srv.SetTags("i-12345678", map[string]string{"role": "web"})
+			c = &command.EC2TagCommand{Meta: meta}
+			Expect(c.Run(append(args, "-account="+srv.Account, "-resources=i-12345678", "-tags=environment=prod", "-policy="+policy))).To(Equal(0), ui.ErrorWriter.String())
+
+			c = &command.EC2TagCommand{Meta: meta}
+			Expect(c.Run(append(args, "-account="+srv.Account, "-resources=i-12345678", "-delete=role", "-policy="+policy))).To(Equal(255))
+			Expect(ui.ErrorWriter.String()).To(ContainSubstring("i-12345678: tag 'role': required by the policy"))
+			Expect(srv.CallCount("DeleteTags")).To(Equal(0))
+
+			srv.Fail("DescribeTags", "UnauthorizedOperation", "not allowed")
+			c = &command.EC2TagCommand{Meta: meta}
+			Expect(c.Run(append(args, "-account="+srv.Account, "-resources=i-12345678", "-tags=environment=dev", "-policy="+policy))).To(Equal(254))
+			Expect(ui.ErrorWriter.String()).To(ContainSubstring("can't check the tags against the policy"))
+			Expect(srv.Tags("i-12345678")).To(HaveKeyWithValue("environment", "prod"))
+		})
+
+		It("Syncs tags, printing the plan before applying it", func() {
+			srv.SetTags("i-12345678", map[string]string{"Name": "web-1", "role": "db", "consul_dc": "east"})
+			c := &command.EC2TagCommand{Meta: meta}
+			code := c.Run(append(args, "-account="+srv.Account, "-resources=i-12345678", "-tags=role=web", "-sync",
+				"-output=text", "-query=Changes[].[Action,Key]"))
+			Expect(code).To(Equal(0), ui.ErrorWriter.String())
+			Expect(ui.OutputWriter.String()).To(Equal("update\trole\nremove\tconsul_dc\n"))
+			Expect(srv.Tags("i-12345678")).To(Equal(map[string]string{"Name": "web-1", "role": "web"}))
+		})
+
+		It("Only prints the plan with -dryrun", func() {
+			srv.SetTags("i-12345678", map[string]string{"role": "db"})
+			c := &command.EC2TagCommand{Meta: meta}
+			code := c.Run(append(args, "-account="+srv.Account, "-resources=i-12345678", "-tags=role=web", "-sync", "-dryrun"))
+			Expect(code).To(Equal(0), ui.ErrorWriter.String())
+			Expect(srv.Tags("i-12345678")).To(Equal(map[string]string{"role": "db"}))
+			Expect(srv.CallCount("CreateTags")).To(Equal(0))
+		})
+
+		It("Deletes keys and conditional key=value pairs", func() {
+			srv.SetTags("i-12345678", map[string]string{"role": "db", "consul_dc": "east", "env": "prod"})
+			c := &command.EC2TagCommand{Meta: meta}
+			code := c.Run(append(args, "-account="+srv.Account, "-resources=i-12345678", "-delete=role,consul_dc=west"))
+			Expect(code).To(Equal(0), ui.ErrorWriter.String())
+			Expect(srv.Tags("i-12345678")).To(Equal(map[string]string{"consul_dc": "east", "env": "prod"}))
+		})
+
+		It("Lists tags without resources", func() {
+			srv.SetTags("i-12345678", map[string]string{"role": "web"})
+			srv.SetTags("vol-12345678", map[string]string{"role": "web"})
+			c := &command.EC2TagCommand{Meta: meta}
+			code := c.Run(append(args, "-account="+srv.Account, "-list", "-resource-type=volume", "-tags=role",
+				"-output=text", "-query=Tags[].[ResourceId,Key,Value]"))
+			Expect(code).To(Equal(0), ui.ErrorWriter.String())
+			Expect(ui.OutputWriter.String()).To(Equal("vol-12345678\trole\tweb\n"))
+		})
+
+		It("Takes one mode at a time", func() {
+			c := &command.EC2TagCommand{Meta: meta}
+			Expect(c.Run(append(args, "-account="+srv.Account, "-list", "-delete=role"))).To(Equal(255))
+			Expect(ui.ErrorWriter.String()).To(ContainSubstring("mutually exclusive"))
+		})
+
+		It("Refuses -sync without -tags", func() {
+			c := &command.EC2TagCommand{Meta: meta}
+			Expect(c.Run(append(args, "-account="+srv.Account, "-resources=i-12345678", "-sync"))).To(Equal(255))
+		})
+
+		It("Reads templated tags from a file, -tags overriding them", func() {
+			md := fakeaws.NewMetadata()
+			defer md.Close()
+			args[2] = "-endpoint-url=ec2=" + srv.URL + ",ec2metadata=" + md.URL
+
+			dir, err := ioutil.TempDir("", "command")
+			Expect(err).NotTo(HaveOccurred())
+			defer os.RemoveAll(dir)
+			file := filepath.Join(dir, "tags.env")
+			Expect(ioutil.WriteFile(file, []byte("role=web\nnode_name={{.InstanceID}}\nbuild=web-{{env \"COMMAND_TEST_VERSION\"}},{{.Region}}\n"), 0600)).To(Succeed())
+			os.Setenv("COMMAND_TEST_VERSION", "1.0")
+			defer os.Unsetenv("COMMAND_TEST_VERSION")
+
+			c := &command.EC2TagCommand{Meta: meta}
+			Expect(c.Run(append(args, "-self", "-tags-file="+file, "-tags=role=db"))).To(Equal(0), ui.ErrorWriter.String())
+			Expect(srv.Tags(md.InstanceID)).To(Equal(map[string]string{"role": "db", "node_name": md.InstanceID, "build": "web-1.0,us-east-1"}))
+		})
+
+		It("Prints the legacy tool's version", func() {
+			c := &command.EC2TagCommand{Meta: meta}
+			Expect(c.Run([]string{"-version"})).To(Equal(0))
+			Expect(ui.OutputWriter.String()).To(Equal("ec2_tag v0.0.4.dev (abc123)\n"))
+		})
+	})
+
+	Describe("EC2InstancesCommand", func() {
+		It("Lists the instances matching -filter and -tag in every region", func() {
+			srv.Regions = []string{"us-east-1", "eu-west-1"}
+			web := srv.AddInstance(&ec2.Instance{Tags: []*ec2.Tag{{Key: aws.String("Name"), Value: aws.String("web-1")}, {Key: aws.String("role"), Value: aws.String("web")}}})
+			srv.AddInstance(&ec2.Instance{Tags: []*ec2.Tag{{Key: aws.String("role"), Value: aws.String("db")}}})
+
+			c := &command.EC2InstancesCommand{Meta: meta}
+			code := c.Run(append(args, "-filter=instance-state-name=running", "-tag=role=web", "-regions=all", "-output=text", "-columns=Region,InstanceId,Name,State"))
+			Expect(code).To(Equal(0), ui.ErrorWriter.String())
+			Expect(ui.OutputWriter.String()).To(Equal("eu-west-1\t" + web + "\tweb-1\trunning\nus-east-1\t" + web + "\tweb-1\trunning\n"))
+		})
+
+		It("Prints the summary columns as a table by default", func() {
+			srv.AddInstance(&ec2.Instance{PrivateIpAddress: aws.String("10.0.0.10")})
+			c := &command.EC2InstancesCommand{Meta: meta}
+			Expect(c.Run(args)).To(Equal(0), ui.ErrorWriter.String())
+			for _, header := range []string{"ID", "Name", "State", "Type", "PrivateIP", "PublicIP", "AZ", "Launched", "10.0.0.10"} {
+				Expect(ui.OutputWriter.String()).To(ContainSubstring(header))
+			}
+		})
+
+		It("Fails naming the region that could not be listed", func() {
+			srv.Fail("DescribeInstances", "UnauthorizedOperation", "You are not authorized to perform this operation.")
+			c := &command.EC2InstancesCommand{Meta: meta}
+			Expect(c.Run(args)).To(Equal(1))
+			Expect(ui.ErrorWriter.String()).To(ContainSubstring("us-east-1: Could not describe instances"))
+		})
+	})
+
+	Describe("EC2InventoryCommand", func() {
+		It("Prints the running instances as ssh config", func() {
+			web := srv.AddInstance(&ec2.Instance{PrivateIpAddress: aws.String("10.0.0.10"), Tags: []*ec2.Tag{{Key: aws.String("Name"), Value: aws.String("web-1")}}})
+			stopped := srv.AddInstance(&ec2.Instance{PrivateIpAddress: aws.String("10.0.0.11")})
+			srv.SetInstanceState(stopped, ec2.InstanceStateNameStopped)
+
+			c := &command.EC2InventoryCommand{Meta: meta}
+			code := c.Run(append(args, "-format=ssh-config", "-bastion=jump.example.com"))
+			Expect(code).To(Equal(0), ui.ErrorWriter.String())
+			Expect(ui.OutputWriter.String()).To(Equal("# " + web + " us-east-1\nHost web-1\n    HostName 10.0.0.10\n    ProxyJump jump.example.com\n"))
+		})
+
+		It("Refuses unknown formats and addresses", func() {
+			c := &command.EC2InventoryCommand{Meta: meta}
+			Expect(c.Run(append(args, "-format=csv"))).To(Equal(1))
+			Expect(c.Run(append(args, "-address=ipv6"))).To(Equal(1))
+			Expect(ui.ErrorWriter.String()).To(ContainSubstring("unknown -address 'ipv6'"))
+		})
+	})
+
+	Describe("EC2StateCommand", func() {
+		var dev string
+
+		BeforeEach(func() {
+			dev = srv.AddInstance(&ec2.Instance{Tags: []*ec2.Tag{{Key: aws.String("environment"), Value: aws.String("dev-1")}}})
+			srv.AddInstance(&ec2.Instance{Tags: []*ec2.Tag{{Key: aws.String("environment"), Value: aws.String("prod")}}})
+		})
+
+		It("Lists then stops the instances matching -tag and waits for them", func() {
+			c := &command.EC2StateCommand{Meta: meta, Action: "stop"}
+			code := c.Run(append(args, "-tag=environment=dev-*", "-wait", "-interval=1ms", "-output=text"))
+			Expect(code).To(Equal(0), ui.ErrorWriter.String())
+			Expect(ui.OutputWriter.String()).To(HavePrefix("stop 1 instance(s):\n  " + dev + "\trunning\t\n" + dev + "\t\trunning\tstopping\n"))
+			Expect(ui.OutputWriter.String()).To(ContainSubstring("waiting for instance-stopped, poll 1"))
+			Expect(aws.StringValue(srv.Instance(dev).State.Name)).To(Equal("stopped"))
+		})
+
+		It("Terminates only once the count is typed or -yes is given", func() {
+			c := &command.EC2StateCommand{Meta: meta, Action: "terminate"}
+			ui.InputReader = strings.NewReader("y\n")
+			Expect(c.Run(append(args, "-ids="+dev))).To(Equal(1))
+			Expect(ui.ErrorWriter.String()).To(ContainSubstring("not confirmed"))
+			Expect(srv.CallCount("TerminateInstances")).To(Equal(0))
+
+			ui.InputReader = strings.NewReader("1\n")
+			Expect(c.Run(append(args, "-ids="+dev))).To(Equal(0), ui.ErrorWriter.String())
+			Expect(aws.StringValue(srv.Instance(dev).State.Name)).To(Equal("terminated"))
+
+			c = &command.EC2StateCommand{Meta: meta, Action: "terminate"}
+			Expect(c.Run(append(args, "-ids="+dev, "-yes"))).To(Equal(0), ui.ErrorWriter.String())
+		})
+
+		It("Only checks permissions with -dryrun", func() {
+			c := &command.EC2StateCommand{Meta: meta, Action: "terminate"}
+			Expect(c.Run(append(args, "-ids="+dev, "-dryrun"))).To(Equal(0), ui.ErrorWriter.String())
+			Expect(ui.OutputWriter.String()).To(ContainSubstring("dry run"))
+			Expect(aws.StringValue(srv.Instance(dev).State.Name)).To(Equal("running"))
+		})
+
+		It("Refuses to run on every instance or to wait after a reboot", func() {
+			c := &command.EC2StateCommand{Meta: meta, Action: "stop"}
+			Expect(c.Run(args)).To(Equal(1))
+			Expect(ui.ErrorWriter.String()).To(ContainSubstring("select the instances"))
+
+			c = &command.EC2StateCommand{Meta: meta, Action: "reboot"}
+			Expect(c.Run(append(args, "-ids="+dev, "-wait"))).To(Equal(1))
+			Expect(srv.CallCount("RebootInstances")).To(Equal(0))
+		})
+	})
+
+	Describe("EC2WaitCommand", func() {
+		It("Exits 0 once the instances are running", func() {
+			id := srv.AddInstance(&ec2.Instance{})
+			c := &command.EC2WaitCommand{Meta: meta}
+			Expect(c.Run(append([]string{"instance-running"}, append(args, "-ids="+id, "-interval=1ms")...))).To(Equal(0), ui.ErrorWriter.String())
+			Expect(ui.OutputWriter.String()).To(ContainSubstring("waiting for instance-running, poll 1"))
+			Expect(ui.OutputWriter.String()).To(ContainSubstring("instance-running: " + id))
+		})
+
+		It("Exits apart on a failure state and on timeout", func() {
+			id := srv.AddInstance(&ec2.Instance{State: &ec2.InstanceState{Code: aws.Int64(48), Name: aws.String(ec2.InstanceStateNameTerminated)}})
+			c := &command.EC2WaitCommand{Meta: meta}
+			Expect(c.Run(append(args, "-ids="+id, "-interval=1ms", "instance-running"))).To(Equal(command.WaitFailed))
+
+			c = &command.EC2WaitCommand{Meta: meta}
+			Expect(c.Run(append([]string{"instance-stopped"}, append(args, "-ids="+id, "-interval=1ms", "-timeout=5ms")...))).To(Equal(command.WaitFailed))
+
+			srv.SetInstanceState(id, ec2.InstanceStateNameRunning)
+			c = &command.EC2WaitCommand{Meta: meta}
+			Expect(c.Run(append([]string{"instance-stopped"}, append(args, "-ids="+id, "-interval=1ms", "-timeout=5ms")...))).To(Equal(command.WaitTimedOut))
+			Expect(ui.ErrorWriter.String()).To(ContainSubstring("timed out"))
+		})
+
+		It("Refuses unknown conditions and missing ids", func() {
+			c := &command.EC2WaitCommand{Meta: meta}
+			Expect(c.Run(append([]string{"instance-happy"}, append(args, "-ids=i-12345678")...))).To(Equal(1))
+			Expect(ui.ErrorWriter.String()).To(ContainSubstring("unknown condition 'instance-happy'"))
+			Expect(c.Run(append([]string{"instance-running"}, args...))).To(Equal(1))
+			Expect(ui.ErrorWriter.String()).To(ContainSubstring("missing resource ids"))
+		})
+	})
+
+	Describe("EC2Command", func() {
+		It("Refuses unknown sub commands instead of ignoring them", func() {
+			c := &command.EC2Command{UI: ui}
+			Expect(c.Run(append(args, "bogus"))).To(Equal(1))
+			Expect(ui.ErrorWriter.String()).To(ContainSubstring("Unknown ec2 sub command 'bogus'"))
+		})
+
+		It("Prints every page of the DescribeInstances response", func() {
+			srv.PageSize = 1
+			one := srv.AddInstance(&ec2.Instance{})
+			two := srv.AddInstance(&ec2.Instance{})
+			c := &command.EC2Command{UI: ui}
+			Expect(c.Run(append(args, "-output=text", "-query=Reservations[].Instances[].InstanceId"))).To(Equal(0), ui.ErrorWriter.String())
+			Expect(strings.Fields(ui.OutputWriter.String())).To(ConsistOf(one, two))
+			Expect(srv.CallCount("DescribeInstances")).To(Equal(2))
+		})
+	})
+
+	Describe("MetadataServeCommand", func() {
+		It("Refuses an invalid fixture", func() {
+			c := &command.MetadataServeCommand{Meta: meta}
+			Expect(c.Run([]string{"-fixture=" + filepath.Join(os.TempDir(), "missing-imds.yaml")})).To(Equal(1))
+			Expect(c.Run([]string{"-http-tokens=sometimes"})).To(Equal(1))
+			Expect(ui.ErrorWriter.String()).To(ContainSubstring("http-tokens"))
+		})
+	})
+
+	Describe("SQSCommand", func() {
+		BeforeEach(func() {
+			srv.CreateQueue("jobs")
+			args = append(args, "-account="+srv.Account, "-queue=jobs")
+		})
+
+		It("Sends with the send mode", func() {
+			c := &command.SQSCommand{Meta: meta, Mode: "send"}
+			Expect(c.Run(append(args, "-message=hello"))).To(Equal(0), ui.ErrorWriter.String())
+			messages := srv.Messages("jobs")
+			Expect(messages).To(HaveLen(1))
+			Expect(aws.StringValue(messages[0].Body)).To(Equal("hello"))
+		})
+
+		It("Keeps the legacy -send flag", func() {
+			c := &command.SQSCommand{Meta: meta}
+			Expect(c.Run(append(args, "-send", "-message=hello"))).To(Equal(0), ui.ErrorWriter.String())
+			Expect(srv.Messages("jobs")).To(HaveLen(1))
+		})
+
+		It("Prints the queue url and returns with -url", func() {
+			c := &command.SQSCommand{Meta: meta, Mode: "recv"}
+			Expect(c.Run(append(args, "-url", "-output=text"))).To(Equal(0), ui.ErrorWriter.String())
+			Expect(ui.OutputWriter.String()).To(Equal(srv.URL + "/" + srv.Account + "/jobs\n"))
+			Expect(srv.CallCount("ReceiveMessage")).To(Equal(0))
+		})
+
+		It("Requires a mode", func() {
+			c := &command.SQSCommand{Meta: meta}
+			Expect(c.Run(args)).To(Equal(1))
+			Expect(ui.ErrorWriter.String()).To(ContainSubstring("-send or -recv"))
+		})
+	})
+
+	Describe("SGCommand", func() {
+		It("Registers and deregisters by group name", func() {
+			id := srv.CreateSecurityGroup("web", "vpc-1")
+			args = append(args, "-sg-name=web", "-ip=10.0.0.1/32", "-from-port=22", "-to-port=22")
+
+			c := &command.SGCommand{Meta: meta, Mode: "register"}
+			Expect(c.Run(args)).To(Equal(0), ui.ErrorWriter.String())
+			Expect(srv.SecurityGroup(id).IpPermissions).To(HaveLen(1))
+
+			c = &command.SGCommand{Meta: meta}
+			Expect(c.Run(append(args, "-deregister"))).To(Equal(0), ui.ErrorWriter.String())
+			Expect(srv.SecurityGroup(id).IpPermissions).To(BeEmpty())
+		})
+
+		It("Looks -sg-name up by -vpc-id and -sg-tag", func() {
+			id := srv.CreateSecurityGroup("web", "vpc-1")
+			other := srv.CreateSecurityGroup("web", "vpc-2")
+			srv.SetTags(other, map[string]string{"environment": "prod"})
+			args = append(args, "-sg-name=web", "-ip=10.0.0.1/32", "-from-port=22")
+
+			c := &command.SGCommand{Meta: meta, Mode: "register"}
+			Expect(c.Run(args)).To(Equal(253))
+			Expect(ui.ErrorWriter.String()).To(ContainSubstring("2 security groups named 'web' match"))
+
+			c = &command.SGCommand{Meta: meta, Mode: "register"}
+			Expect(c.Run(append(args, "-vpc-id=vpc-1"))).To(Equal(0), ui.ErrorWriter.String())
+			Expect(srv.SecurityGroup(id).IpPermissions).To(HaveLen(1))
+
+			c = &command.SGCommand{Meta: meta, Mode: "register"}
+			Expect(c.Run(append(args, "-sg-tag=environment=prod"))).To(Equal(0), ui.ErrorWriter.String())
+			Expect(srv.SecurityGroup(other).IpPermissions).To(HaveLen(1))
+
+			c = &command.SGCommand{Meta: meta, Mode: "register"}
+			Expect(c.Run(append(args, "-sg-tag=environment=dev"))).To(Equal(253))
+			Expect(ui.ErrorWriter.String()).To(ContainSubstring("no security group named 'web' matches tag:environment=dev"))
+		})
+
+		It("Registers source groups, ipv6 and egress", func() {
+			id := srv.CreateSecurityGroup("web", "vpc-1")
+			lb := srv.CreateSecurityGroup("lb", "vpc-1")
+			args = append(args, "-sg-id="+id)
+
+			c := &command.SGCommand{Meta: meta, Mode: "register"}
+			Expect(c.Run(append(args, "-source-group=lb", "-ip=10.0.0.0/8,2001:db8::/32", "-from-port=8080"))).To(Equal(0), ui.ErrorWriter.String())
+			perm := srv.SecurityGroup(id).IpPermissions[0]
+			Expect(aws.Int64Value(perm.ToPort)).To(Equal(int64(8080)))
+			Expect(aws.StringValue(perm.UserIdGroupPairs[0].GroupId)).To(Equal(lb))
+			Expect(perm.IpRanges).To(HaveLen(1))
+			Expect(perm.Ipv6Ranges).To(HaveLen(1))
+
+			c = &command.SGCommand{Meta: meta, Mode: "register"}
+			Expect(c.Run(append(args, "-egress", "-protocol=icmp", "-from-port=8", "-ip=10.0.0.0/8"))).To(Equal(0), ui.ErrorWriter.String())
+			egress := srv.SecurityGroup(id).IpPermissionsEgress
+			Expect(egress).To(HaveLen(2))
+
+			c = &command.SGCommand{Meta: meta, Mode: "register"}
+			Expect(c.Run(append(args, "-protocol=all", "-from-port=22"))).To(Equal(1))
+			Expect(ui.ErrorWriter.String()).To(ContainSubstring("protocol -1 has no ports"))
+			Expect(c.Run(append(args, "-protocol=icmpv6", "-ip=10.0.0.0/8"))).To(Equal(1))
+		})
+
+		It("Exits 2 when the rule is already as asked, 253 with -strict", func() {
+			id := srv.CreateSecurityGroup("web", "vpc-1")
+			args = append(args, "-sg-id="+id, "-ip=10.0.0.1/32", "-from-port=22", "-to-port=22")
+
+			c := &command.SGCommand{Meta: meta, Mode: "deregister"}
+			Expect(c.Run(args)).To(Equal(command.SGUnchanged), ui.ErrorWriter.String())
+			Expect(ui.OutputWriter.String()).To(ContainSubstring(id + " doesn't have ingress tcp 22 from 10.0.0.1/32, nothing to deregister"))
+
+			c = &command.SGCommand{Meta: meta, Mode: "register"}
+			Expect(c.Run(args)).To(Equal(0), ui.ErrorWriter.String())
+			Expect(c.Run(args)).To(Equal(command.SGUnchanged), ui.ErrorWriter.String())
+			Expect(c.Run(append(args, "-strict"))).To(Equal(253))
+			Expect(ui.ErrorWriter.String()).To(ContainSubstring(id + " already has ingress tcp 22 from 10.0.0.1/32"))
+			Expect(srv.SecurityGroup(id).IpPermissions).To(HaveLen(1))
+		})
+
+		It("Syncs a group with a rules file", func() {
+			id := srv.CreateSecurityGroup("web", "vpc-1")
+			dir, err := ioutil.TempDir("", "command")
+			Expect(err).NotTo(HaveOccurred())
+			defer os.RemoveAll(dir)
+			rules := filepath.Join(dir, "rules.yaml")
+			Expect(ioutil.WriteFile(rules, []byte("ingress:\n  - {protocol: tcp, ports: 443, cidrs: [10.0.0.0/8]}\negress: []\n"), 0600)).To(Succeed())
+
+			c := &command.SGCommand{Meta: meta, Mode: "sync"}
+			Expect(c.Run(append(args, "-sg-name=web", "-rules="+rules, "-dryrun"))).To(Equal(0), ui.ErrorWriter.String())
+			Expect(ui.OutputWriter.String()).To(Equal("+ ingress tcp 443 from 10.0.0.0/8\n- egress all to 0.0.0.0/0\ndry run, nothing changed\n"))
+			Expect(srv.SecurityGroup(id).IpPermissionsEgress).To(HaveLen(1))
+
+			c = &command.SGCommand{Meta: meta}
+			Expect(c.Run(append(args, "-sync", "-sg-id="+id, "-rules="+rules))).To(Equal(0), ui.ErrorWriter.String())
+			Expect(srv.SecurityGroup(id).IpPermissions).To(HaveLen(1))
+			Expect(srv.SecurityGroup(id).IpPermissionsEgress).To(BeEmpty())
+
+			c = &command.SGCommand{Meta: meta, Mode: "sync"}
+			Expect(c.Run(append(args, "-sg-id="+id, "-rules="+rules))).To(Equal(0), ui.ErrorWriter.String())
+			Expect(ui.OutputWriter.String()).To(HaveSuffix(id + " is in sync with " + rules + "\n"))
+
+			c = &command.SGCommand{Meta: meta, Mode: "sync"}
+			Expect(c.Run(append(args, "-sg-name=cache", "-rules="+rules))).To(Equal(253))
+		})
+
+		It("Registers for a -ttl and leaves the rule to reap until it has passed", func() {
+			id := srv.CreateSecurityGroup("web", "vpc-1")
+			base := args
+			args = append(args[:len(args):len(args)], "-sg-id="+id, "-ip=10.0.0.1/32", "-from-port=22", "-to-port=22")
+
+			c := &command.SGCommand{Meta: meta, Mode: "register"}
+			Expect(c.Run(append(args, "-ttl=2h"))).To(Equal(0), ui.ErrorWriter.String())
+			Expect(ui.OutputWriter.String()).To(ContainSubstring(`"Expires"`))
+			description := srv.SecurityGroup(id).IpPermissions[0].IpRanges[0].Description
+			Expect(aws.StringValue(description)).To(HavePrefix("sg_register expires "))
+
+			c = &command.SGCommand{Meta: meta, Mode: "reap"}
+			Expect(c.Run(append(base, "-output=text"))).To(Equal(0), ui.ErrorWriter.String())
+			Expect(srv.SecurityGroup(id).IpPermissions).To(HaveLen(1))
+
+			c = &command.SGCommand{Meta: meta, Mode: "deregister"}
+			Expect(c.Run(append(args, "-ttl=2h"))).To(Equal(1))
+		})
+	})
+})
@@ -1,14 +1,17 @@
 package command
 
 import (
+	"context"
 	"flag"
 	"fmt"
 	"strings"
 
+	"github.com/aws/aws-sdk-go/service/ec2"
 	"github.com/mitchellh/cli"
 
 	"github.com/aidevops/awscli"
-	"github.com/aidevops/awscli/logger"
+	"github.com/aidevops/awscli/ec2info"
+	"github.com/aidevops/awscli/output"
 )
 
 // EC2Command -
@@ -19,67 +22,71 @@ type EC2Command struct {
 // Help -
 func (c *EC2Command) Help() string {
 	helpText := `
-Usage: awscli ec2 [options] name
-  
-  EC2.....
+Usage: awscli ec2 [options]
+       awscli ec2 <instances|inventory|start|stop|reboot|terminate|tag|wait> [options]
+
+  Print the DescribeInstances response, every page of it merged. See
+  'awscli ec2 instances' for a summary across regions.
 
 Options:
   
-  -verbose=true  Display additional information from 
-                 behind the scenes.
+  -region=name   AWS region, defaults to $AWS_REGION, the profile's
+                 region, the instance's region, then us-east-1.
+
+  -profile=name  AWS shared credentials profile.
+
+  -output=json   Output format: json, text, table or yaml.
+
+  -query=expr    JMESPath expression applied to the response before
+                 printing it, e.g. -query='Reservations[].Instances[].InstanceId'.
+
+  -columns=list  Table and text columns as 'Header=expression' pairs.
 `
 	return strings.TrimSpace(helpText)
 }
 
 // Run -
 func (c *EC2Command) Run(args []string) int {
-	var (
-		format  string
-		level   string
-		logfile string
-		verbose bool
-	)
-
+	cli := &awscli.AwsCli{}
+	printer := output.New("")
+	printer.Out = &uiWriter{ui: c.UI}
 	cmdFlags := flag.NewFlagSet("ec2", flag.ContinueOnError)
 	cmdFlags.Usage = func() { c.UI.Output(c.Help()) }
 
-	cmdFlags.StringVar(&format, "format", "text", "Format response as either json or regular text.")
-	cmdFlags.StringVar(&level, "level", "info", "logging level: error, warn, info, or debug")
-	cmdFlags.StringVar(&logfile, "log", "/tmp/cloudconfig.log", "logfile path")
-	cmdFlags.BoolVar(&verbose, "verbose", false, "verbose")
+	cli.SetFlags(cmdFlags)
+	printer.SetFlags(cmdFlags)
+	cmdFlags.StringVar(&cli.Region, "region", "", "AWS region.")
 
 	if err := cmdFlags.Parse(args); err != nil {
 		return 1
 	}
 
-	args = cmdFlags.Args()
-	if len(args) < 1 {
-		c.UI.Error("arguments must be specified.")
-		c.UI.Error("")
-		c.UI.Error(c.Help())
+	if err := printer.Validate(); err != nil {
+		c.UI.Error(err.Error())
 		return 1
-	} else if len(args) > 1 {
-		c.UI.Error("Too many command line arguments.")
+	}
+
+	if args = cmdFlags.Args(); len(args) > 0 {
+		c.UI.Error(fmt.Sprintf("Unknown ec2 sub command '%s', expected instances, inventory, start, stop, reboot, terminate, tag or wait.", args[0]))
 		c.UI.Error("")
 		c.UI.Error(c.Help())
 		return 1
 	}
 
-	ec2 := args[0]
-
-	c.UI.Output(fmt.Sprintf("Setting ec2 to '%s'! Verbosity enabled: %#v",
-		ec2, verbose))
-
-	log := logger.NewCLILogger(level, logfile, "ec2", format, c.UI)
-
-	awscli.EC2Info()
-
-	log.Flush()
-
+	svc := ec2.New(cli.Session(), cli.ServiceConfig(ec2.ServiceName))
+	reservations, err := ec2info.Reservations(context.Background(), svc, &ec2.DescribeInstancesInput{})
+	if err != nil {
+		c.UI.Error(err.Error())
+		return 1
+	}
+	if err := printer.Print(&ec2.DescribeInstancesOutput{Reservations: reservations}); err != nil {
+		c.UI.Error(err.Error())
+		return 1
+	}
 	return 0
 }
 
 // Synopsis -
 func (c *EC2Command) Synopsis() string {
-	return "ec2....."
+	return "Print the DescribeInstances response"
 }
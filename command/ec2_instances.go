@@ -0,0 +1,156 @@
+package command
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/ec2/ec2iface"
+
+	"github.com/aidevops/awscli"
+	"github.com/aidevops/awscli/ec2info"
+	"github.com/aidevops/awscli/ec2tag"
+)
+
+// EC2InstancesCommand - list ec2 instances
+type EC2InstancesCommand struct {
+	Meta
+}
+
+// Help -
+func (c *EC2InstancesCommand) Help() string {
+	helpText := `
+Usage: awscli ec2 instances [options]
+
+  List ec2 instances: id, Name tag, state, type, private and public ip,
+  availability zone and launch time, in one or more regions at once.
+
+Options:
+
+  -filter=list       Ec2 filters, 'instance-state-name=running,vpc-id=vpc-1a2b'.
+                     Separate several values of a filter with '|'.
+
+  -tag=list          Tags the instances must have, 'role=web|api,env'. A
+                     key alone matches any value.
+
+  -regions=list      Comma separated regions to list, or 'all' for every
+                     region enabled for the account. Defaults to -region.
+
+  -region=name       AWS region, defaults to $AWS_REGION, the profile's
+                     region, the instance's region, then us-east-1.
+
+  -profile=name      AWS shared credentials profile.
+
+  -output=table      Output format: json, text, table or yaml.
+
+  -query=expr        JMESPath expression applied to the list, e.g.
+                     -query='[?State==` + "`running`" + `].InstanceId'.
+
+  -columns=list      Table and text columns as 'Header=expression' pairs,
+                     defaults to the summary columns. Every instance also
+                     has Region and Tags.
+
+  -verbose=true      Display additional information from
+                     behind the scenes.
+`
+	return strings.TrimSpace(helpText)
+}
+
+// Run -
+func (c *EC2InstancesCommand) Run(args []string) int {
+	var (
+		filter  string
+		tag     string
+		regions string
+	)
+
+	cli := &awscli.AwsCli{}
+	printer := c.printer("table")
+	printer.Columns = ec2info.Columns
+	cmdFlags := flag.NewFlagSet("ec2 instances", flag.ContinueOnError)
+	cmdFlags.Usage = func() { c.UI.Output(c.Help()) }
+
+	cli.SetFlags(cmdFlags)
+	printer.SetFlags(cmdFlags)
+	cmdFlags.StringVar(&cli.Region, "region", "", "AWS region, defaults to $AWS_REGION, the profile's region, the instance's region, then us-east-1. E.g. -region=us-east-1")
+	cmdFlags.StringVar(&filter, "filter", "", "-filter 'instance-state-name=running,vpc-id=vpc-1a2b'")
+	cmdFlags.StringVar(&tag, "tag", "", "-tag 'role=web|api,env' instances must have")
+	cmdFlags.StringVar(&regions, "regions", "", "-regions 'us-east-1,eu-west-1' or 'all'")
+	cmdFlags.BoolVar(&c.verbose, "verbose", false, "be more verbose.....")
+
+	if err := cmdFlags.Parse(args); err != nil {
+		return 1
+	}
+	if len(cmdFlags.Args()) > 0 {
+		c.UI.Error(fmt.Sprintf("ec2 instances: unexpected arguments '%s'", strings.Join(cmdFlags.Args(), " ")))
+		return 1
+	}
+
+	if err := printer.Validate(); err != nil {
+		c.UI.Error(fmt.Sprintf("ec2 instances: %s", err))
+		return 1
+	}
+
+	filters, err := ParseMap(filter)
+	if err != nil {
+		c.UI.Error(fmt.Sprintf("ec2 instances: -filter: %s", err))
+		return 1
+	}
+	tags, err := ParseMap(tag)
+	if err != nil {
+		c.UI.Error(fmt.Sprintf("ec2 instances: -tag: %s", err))
+		return 1
+	}
+
+	ctx := context.Background()
+	clients, err := regionClients(ctx, cli, regions)
+	if err != nil {
+		c.UI.Error(fmt.Sprintf("[ERROR]: -regions=%s: %s", regions, err))
+		return 1
+	}
+	c.debugf("[DEBUG]: listing instances in %d region(s)\n", len(clients))
+
+	instances, err := ec2info.List(ctx, clients, append(ec2tag.Filters(filters), ec2info.TagFilters(tags)...))
+	if instances == nil {
+		instances = []ec2info.Instance{}
+	}
+	if perr := printer.Print(instances); perr != nil {
+		c.UI.Error(fmt.Sprintf("[ERROR]: %s", perr))
+		return 1
+	}
+	if err != nil {
+		c.UI.Error(fmt.Sprintf("[ERROR]: failed to list instances: %s", err))
+		return 1
+	}
+	return 0
+}
+
+// regionClients - an ec2 client for each of the comma separated regions, every
+// region of the account for 'all', the cli's region when none are given
+func regionClients(ctx context.Context, cli *awscli.AwsCli, regions string) (map[string]ec2iface.EC2API, error) {
+	var err error
+	sess := cli.Session()
+	list := splitList(regions)
+	if regions == "all" {
+		svc := ec2.New(sess, cli.ServiceConfig(ec2.ServiceName))
+		if list, err = ec2info.Regions(ctx, svc); err != nil {
+			return nil, err
+		}
+	}
+	if len(list) == 0 {
+		list = []string{cli.GetRegion()}
+	}
+
+	clients := make(map[string]ec2iface.EC2API, len(list))
+	for _, region := range list {
+		clients[region] = ec2.New(sess, cli.ServiceConfig(ec2.ServiceName).WithRegion(region))
+	}
+	return clients, nil
+}
+
+// Synopsis -
+func (c *EC2InstancesCommand) Synopsis() string {
+	return "List ec2 instances across regions"
+}
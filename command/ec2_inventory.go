@@ -0,0 +1,151 @@
+package command
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/service/ec2"
+
+	"github.com/aidevops/awscli"
+	"github.com/aidevops/awscli/ec2info"
+	"github.com/aidevops/awscli/ec2inventory"
+	"github.com/aidevops/awscli/ec2tag"
+)
+
+// EC2InventoryCommand - print ec2 instances as an ansible inventory, ssh config or hosts file
+type EC2InventoryCommand struct {
+	Meta
+}
+
+// Help -
+func (c *EC2InventoryCommand) Help() string {
+	helpText := `
+Usage: awscli ec2 inventory [options]
+
+  Print the running instances as an ansible dynamic inventory, ssh config
+  Host entries or /etc/hosts lines. Hosts are named after their Name tag,
+  or id when they have none, the id is appended to names several share.
+
+Options:
+
+  -format=ansible    ansible, ssh-config or hosts.
+
+  -group-by=list     Comma separated tag keys to make ansible groups of,
+                     tag_<key>_<value>, e.g. 'role,environment,consul_dc'.
+                     Every tag is also a ec2_tag_<key> host var.
+
+  -address=auto      The address hosts are reached on: auto for the public
+                     ip falling back to the private one, private or public.
+
+  -user=name         The ssh user, ansible_user in the inventory.
+
+  -bastion=host      Jump through this host to the ones reached on their
+                     private ip, ProxyJump in ssh config and
+                     ansible_ssh_common_args in the inventory.
+
+  -filter=list       Ec2 filters, 'vpc-id=vpc-1a2b', defaults to running
+                     instances when there is no instance-state-name.
+
+  -tag=list          Tags the instances must have, 'environment=prod,role'.
+
+  -regions=list      Comma separated regions, or 'all'. Defaults to -region.
+
+  -region=name       AWS region, defaults to $AWS_REGION, the profile's
+                     region, the instance's region, then us-east-1.
+
+  -profile=name      AWS shared credentials profile.
+
+  -verbose=true      Display additional information from
+                     behind the scenes.
+`
+	return strings.TrimSpace(helpText)
+}
+
+// Run -
+func (c *EC2InventoryCommand) Run(args []string) int {
+	var (
+		format  string
+		groupBy string
+		filter  string
+		tag     string
+		regions string
+		opts    ec2inventory.Options
+	)
+
+	cli := &awscli.AwsCli{}
+	cmdFlags := flag.NewFlagSet("ec2 inventory", flag.ContinueOnError)
+	cmdFlags.Usage = func() { c.UI.Output(c.Help()) }
+
+	cli.SetFlags(cmdFlags)
+	cmdFlags.StringVar(&cli.Region, "region", "", "AWS region, defaults to $AWS_REGION, the profile's region, the instance's region, then us-east-1. E.g. -region=us-east-1")
+	cmdFlags.StringVar(&format, "format", "ansible", "ansible, ssh-config or hosts")
+	cmdFlags.StringVar(&groupBy, "group-by", "", "-group-by 'role,environment' tag keys to group by")
+	cmdFlags.StringVar(&opts.Address, "address", "auto", "auto, private or public")
+	cmdFlags.StringVar(&opts.User, "user", "", "the ssh user")
+	cmdFlags.StringVar(&opts.Bastion, "bastion", "", "host to jump through to private ips")
+	cmdFlags.StringVar(&filter, "filter", "", "-filter 'vpc-id=vpc-1a2b'")
+	cmdFlags.StringVar(&tag, "tag", "", "-tag 'environment=prod,role' instances must have")
+	cmdFlags.StringVar(&regions, "regions", "", "-regions 'us-east-1,eu-west-1' or 'all'")
+	cmdFlags.BoolVar(&c.verbose, "verbose", false, "be more verbose.....")
+
+	if err := cmdFlags.Parse(args); err != nil {
+		return 1
+	}
+	if len(cmdFlags.Args()) > 0 {
+		c.UI.Error(fmt.Sprintf("ec2 inventory: unexpected arguments '%s'", strings.Join(cmdFlags.Args(), " ")))
+		return 1
+	}
+	if !contains(ec2inventory.Formats, format) {
+		c.UI.Error(fmt.Sprintf("ec2 inventory: unknown -format '%s', expected one of: %s", format, strings.Join(ec2inventory.Formats, ", ")))
+		return 1
+	}
+	if !contains(ec2inventory.Addresses, opts.Address) {
+		c.UI.Error(fmt.Sprintf("ec2 inventory: unknown -address '%s', expected one of: %s", opts.Address, strings.Join(ec2inventory.Addresses, ", ")))
+		return 1
+	}
+	opts.GroupBy = splitList(groupBy)
+
+	filters, err := ParseMap(filter)
+	if err != nil {
+		c.UI.Error(fmt.Sprintf("ec2 inventory: -filter: %s", err))
+		return 1
+	}
+	tags, err := ParseMap(tag)
+	if err != nil {
+		c.UI.Error(fmt.Sprintf("ec2 inventory: -tag: %s", err))
+		return 1
+	}
+	if _, ok := filters["instance-state-name"]; !ok {
+		filters["instance-state-name"] = ec2.InstanceStateNameRunning
+	}
+
+	ctx := context.Background()
+	clients, err := regionClients(ctx, cli, regions)
+	if err != nil {
+		c.UI.Error(fmt.Sprintf("[ERROR]: -regions=%s: %s", regions, err))
+		return 1
+	}
+	c.debugf("[DEBUG]: listing instances in %d region(s)\n", len(clients))
+
+	instances, lerr := ec2info.List(ctx, clients, append(ec2tag.Filters(filters), ec2info.TagFilters(tags)...))
+	out, err := ec2inventory.Render(format, ec2inventory.Hosts(instances, opts), opts)
+	if err != nil {
+		c.UI.Error(fmt.Sprintf("[ERROR]: %s", err))
+		return 1
+	}
+	if len(out) > 0 {
+		c.UI.Output(strings.TrimSuffix(string(out), "\n"))
+	}
+	if lerr != nil {
+		c.UI.Error(fmt.Sprintf("[ERROR]: failed to list instances: %s", lerr))
+		return 1
+	}
+	return 0
+}
+
+// Synopsis -
+func (c *EC2InventoryCommand) Synopsis() string {
+	return "Print ec2 instances as an ansible inventory, ssh config or hosts file"
+}
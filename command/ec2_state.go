@@ -0,0 +1,197 @@
+package command
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/service/ec2"
+
+	"github.com/aidevops/awscli"
+	"github.com/aidevops/awscli/ec2info"
+	"github.com/aidevops/awscli/ec2state"
+	"github.com/aidevops/awscli/ec2tag"
+)
+
+// EC2StateCommand - start, stop, reboot or terminate ec2 instances, Action
+// is the one the command runs
+type EC2StateCommand struct {
+	Meta
+	Action string
+}
+
+// Help -
+func (c *EC2StateCommand) Help() string {
+	helpText := `
+Usage: awscli ec2 ` + c.Action + ` [options]
+
+  ` + strings.Title(c.Action) + ` the instances given by id or matching the filters, after
+  listing them. Filters don't match terminated instances. Terminating asks
+  to type the number of instances unless -yes is given.
+
+Options:
+
+  -ids=list          Space separated instance ids, 'i-86424106 i-864241..'.
+
+  -filter=list       Ec2 filters, 'instance-state-name=running,vpc-id=vpc-1a2b'.
+                     Separate several values of a filter with '|'.
+
+  -tag=list          Tags the instances must have, 'environment=dev-*|qa,role'.
+                     A key alone matches any value.
+
+  -yes=true          Terminate without asking.
+
+  -dryrun=true       Only check the call would be permitted.
+
+  -wait=true         Wait until the instances are running, stopped or
+                     terminated, exiting 2 when one can't get there and 3 on
+                     timeout. Not for reboot.
+
+  -interval=15s      Time between polls of -wait.
+
+  -timeout=10m       Time -wait gives up after.
+
+  -region=name       AWS region, defaults to $AWS_REGION, the profile's
+                     region, the instance's region, then us-east-1.
+
+  -profile=name      AWS shared credentials profile.
+
+  -output=table      Output format of the state changes: json, text, table or yaml.
+
+  -query=expr        JMESPath expression applied to the changes.
+
+  -columns=list      Table and text columns as 'Header=expression' pairs.
+
+  -verbose=true      Display additional information from
+                     behind the scenes.
+`
+	return strings.TrimSpace(helpText)
+}
+
+// Run -
+func (c *EC2StateCommand) Run(args []string) int {
+	var (
+		ids      string
+		filter   string
+		tag      string
+		yes      bool
+		dryrun   bool
+		wait     bool
+		interval time.Duration
+		timeout  time.Duration
+	)
+
+	name := "ec2 " + c.Action
+	cli := &awscli.AwsCli{}
+	printer := c.printer("table")
+	printer.Columns = "ID=InstanceId,Name=Name,Previous=PreviousState,Current=CurrentState"
+	cmdFlags := flag.NewFlagSet(name, flag.ContinueOnError)
+	cmdFlags.Usage = func() { c.UI.Output(c.Help()) }
+
+	cli.SetFlags(cmdFlags)
+	printer.SetFlags(cmdFlags)
+	cmdFlags.StringVar(&cli.Region, "region", "", "AWS region, defaults to $AWS_REGION, the profile's region, the instance's region, then us-east-1. E.g. -region=us-east-1")
+	cmdFlags.StringVar(&ids, "ids", "", "-ids 'i-86424106 i-86424107'")
+	cmdFlags.StringVar(&filter, "filter", "", "-filter 'instance-state-name=running,vpc-id=vpc-1a2b'")
+	cmdFlags.StringVar(&tag, "tag", "", "-tag 'environment=dev-*|qa,role' instances must have")
+	cmdFlags.BoolVar(&yes, "yes", false, "terminate without asking")
+	cmdFlags.BoolVar(&dryrun, "dryrun", false, "only check the call would be permitted")
+	cmdFlags.BoolVar(&wait, "wait", false, "wait until the instances are running, stopped or terminated")
+	cmdFlags.DurationVar(&interval, "interval", 0, "time between polls of -wait. E.g. -interval=5s")
+	cmdFlags.DurationVar(&timeout, "timeout", 0, "time -wait gives up after. E.g. -timeout=10m")
+	cmdFlags.BoolVar(&c.verbose, "verbose", false, "be more verbose.....")
+
+	if err := cmdFlags.Parse(args); err != nil {
+		return 1
+	}
+	if len(cmdFlags.Args()) > 0 {
+		c.UI.Error(fmt.Sprintf("%s: unexpected arguments '%s'", name, strings.Join(cmdFlags.Args(), " ")))
+		return 1
+	}
+
+	condition, ok := ec2state.Actions[c.Action]
+	if !ok {
+		c.UI.Error(fmt.Sprintf("%s: unknown action, expected start, stop, reboot or terminate", name))
+		return 1
+	}
+	if wait && condition == "" {
+		c.UI.Error(fmt.Sprintf("%s: -wait: there is nothing to wait for after a %s", name, c.Action))
+		return 1
+	}
+	if err := printer.Validate(); err != nil {
+		c.UI.Error(fmt.Sprintf("%s: %s", name, err))
+		return 1
+	}
+
+	filters, err := ParseMap(filter)
+	if err != nil {
+		c.UI.Error(fmt.Sprintf("%s: -filter: %s", name, err))
+		return 1
+	}
+	tags, err := ParseMap(tag)
+	if err != nil {
+		c.UI.Error(fmt.Sprintf("%s: -tag: %s", name, err))
+		return 1
+	}
+	if ids == "" && len(filters) == 0 && len(tags) == 0 {
+		c.UI.Error(fmt.Sprintf("%s: select the instances with -ids, -filter or -tag", name))
+		return 1
+	}
+
+	c.debugf("[DEBUG]: using region: %s\n", cli.GetRegion())
+	ctx := context.Background()
+	svc := ec2.New(cli.Session(), cli.ServiceConfig(ec2.ServiceName))
+
+	instances, err := ec2state.Select(ctx, svc, ToSlice(ids), append(ec2tag.Filters(filters), ec2info.TagFilters(tags)...))
+	if err != nil {
+		c.UI.Error(fmt.Sprintf("[ERROR]: %s", err))
+		return 1
+	}
+	if len(instances) == 0 {
+		c.UI.Info(fmt.Sprintf("%s: no instances match", name))
+		return 0
+	}
+
+	c.UI.Info(fmt.Sprintf("%s %d instance(s):", c.Action, len(instances)))
+	for _, instance := range instances {
+		c.UI.Info(fmt.Sprintf("  %s\t%s\t%s", instance.InstanceID, instance.State, instance.Name))
+	}
+
+	if c.Action == "terminate" && !yes && !dryrun {
+		answer, err := c.UI.Ask(fmt.Sprintf("Type %d to terminate them:", len(instances)))
+		if err != nil || strings.TrimSpace(answer) != fmt.Sprintf("%d", len(instances)) {
+			c.UI.Error(fmt.Sprintf("%s: not confirmed, nothing was terminated", name))
+			return 1
+		}
+	}
+
+	changes, err := ec2state.Apply(ctx, svc, c.Action, instances, dryrun)
+	if err != nil {
+		c.UI.Error(fmt.Sprintf("[ERROR]: %s", err))
+		return 1
+	}
+	if dryrun {
+		c.UI.Info(fmt.Sprintf("%s: dry run, the %s would have succeeded", name, c.Action))
+		return 0
+	}
+	if err := printer.Print(changes); err != nil {
+		c.UI.Error(fmt.Sprintf("[ERROR]: %s", err))
+		return 1
+	}
+	if !wait {
+		return 0
+	}
+
+	waitIDs := make([]string, len(instances))
+	for i, instance := range instances {
+		waitIDs[i] = instance.InstanceID
+	}
+	return c.wait(ctx, svc, condition, waitIDs, interval, timeout)
+}
+
+// Synopsis -
+func (c *EC2StateCommand) Synopsis() string {
+	return strings.Title(c.Action) + " ec2 instances by id or filter"
+}
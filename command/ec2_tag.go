@@ -0,0 +1,525 @@
+package command
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/service/ec2"
+
+	"github.com/aidevops/awscli"
+	"github.com/aidevops/awscli/ec2tag"
+	"github.com/aidevops/awscli/output"
+)
+
+// ec2TagUnit - the standalone tool this command replaces
+const ec2TagUnit = "ec2_tag"
+
+// EC2TagCommand - tag ec2 resources
+type EC2TagCommand struct {
+	Meta
+}
+
+// Help -
+func (c *EC2TagCommand) Help() string {
+	helpText := `
+Usage: awscli ec2 tag [options]
+       ec2_tag [options]
+
+  Create or overwrite tags on ec2 resources, reconcile them with -sync,
+  delete them with -delete or list them with -list.
+
+Options:
+
+  -account=id        AWS account # owning the resources, defaults to the
+                     instance's account.
+
+  -resources=list    Space separated resource ids, 'i-86424106 vol-1234abcd'.
+
+  -self=true         Tag the ec2 instance we're running on, alongside any
+                     -resources.
+
+  -filter=list       Tag every resource of -resource-type (instance by
+                     default) matching the ec2 filters, alongside any
+                     -resources, 'tag:role=web,instance-state-name=running'.
+                     Separate several values of a filter with '|'.
+
+  -chunk-size=1000   Resources per CreateTags call when tagging by -filter.
+
+  -concurrency=4     CreateTags calls in flight at once when tagging by
+                     -filter. Throttled calls are retried with backoff and
+                     the outcome is reported per resource.
+
+  -tags=list         Tags to set, 'foo=bar,hello=world'.
+
+  -tags-file=path    Tags to set from a .json, .yaml or .env file, -tags
+                     override its keys. Values of both are text/templates:
+                     '{{.Env.version}}', '{{.InstanceID}}', '{{.PrivateIP}}',
+                     '{{.AvailabilityZone}}', '{{.Metadata "local-hostname"}}'.
+
+  -policy=path       Yaml or json policy the tags must follow on top of ec2's
+                     rules: 'required' keys every resource must end up with
+                     and 'allowed' regular expressions per key for values.
+                     Tags are checked before anything is changed, -delete
+                     keeping the required keys too.
+
+  -sync=true         Make the resources' tags equal -tags: print the plan of
+                     adds, updates and removes, then apply it.
+
+  -protect=list      Key patterns -sync never removes, defaults to
+                     'aws:*,Name'.
+
+  -propagate=list    Copy the tags of the instances among the resources, once
+                     tagged, to their 'volumes,enis,snapshots': attached ebs
+                     volumes, network interfaces and snapshots of those
+                     volumes. Only adds and updates, so reruns are no-ops.
+
+  -propagate-keys=list
+                     Key patterns -propagate copies, 'environment,role,cost*',
+                     defaults to every key.
+
+  -dryrun=true       Print the -sync or -propagate plan without applying it.
+
+  -delete=list       Tags to delete, 'role,consul_dc=east': a key is deleted
+                     whatever its value, key=value only where it has that
+                     value.
+
+  -list=true         List tags instead, of -resources if given, with the
+                     keys (and values) in -tags if given.
+
+  -resource-type=list
+                     Space separated resource types -list is limited to,
+                     'instance volume', or -filter looks up, 'instance' or
+                     'volume'.
+
+  -region=name       AWS region, defaults to $AWS_REGION, the profile's
+                     region, the instance's region, then us-east-1.
+
+  -profile=name      AWS shared credentials profile.
+
+  -output=json       Output format: json, text, table or yaml.
+
+  -query=expr        JMESPath expression applied to the result.
+
+  -verbose=true      Display additional information from
+                     behind the scenes.
+`
+	return strings.TrimSpace(helpText)
+}
+
+// Run -
+func (c *EC2TagCommand) Run(args []string) int {
+	var (
+		resources  string
+		self       bool
+		filter     string
+		chunkSize  int
+		workers    int
+		sync       bool
+		propagate  string
+		propKeys   string
+		protect    string
+		dryrun     bool
+		del        string
+		list       bool
+		types      string
+		tags       string
+		tagsFile   string
+		policyFile string
+		version    bool
+	)
+
+	cli := &awscli.AwsCli{}
+	printer := c.printer("json")
+	cmdFlags := flag.NewFlagSet("ec2 tag", flag.ContinueOnError)
+	cmdFlags.Usage = func() { c.UI.Output(c.Help()) }
+
+	cli.SetFlags(cmdFlags)
+	printer.SetFlags(cmdFlags)
+	cmdFlags.StringVar(&cli.Account, "account", "", "AWS account #, defaults to the instance's account. E.g. -account='1234556790123'")
+	cmdFlags.StringVar(&cli.Region, "region", "", "AWS region, defaults to $AWS_REGION, the profile's region, the instance's region, then us-east-1. E.g. -region=us-east-1")
+	cmdFlags.BoolVar(&c.verbose, "verbose", false, "be more verbose.....")
+	cmdFlags.BoolVar(&version, "version", false, "print version and exit")
+	cmdFlags.StringVar(&resources, "resources", "", "-resources 'one two three four five'")
+	cmdFlags.BoolVar(&self, "self", false, "tag the instance we're running on, found through instance metadata")
+	cmdFlags.StringVar(&filter, "filter", "", "-filter 'tag:role=web,instance-state-name=running' tags every match")
+	cmdFlags.IntVar(&chunkSize, "chunk-size", ec2tag.DefaultOptions.ChunkSize, "resources per CreateTags call with -filter")
+	cmdFlags.IntVar(&workers, "concurrency", ec2tag.DefaultOptions.Concurrency, "CreateTags calls in flight with -filter")
+	cmdFlags.StringVar(&tags, "tags", "", "-tags 'foo=bar,bar=foo,hello=world'")
+	cmdFlags.StringVar(&tagsFile, "tags-file", "", "json, yaml or .env file of tags to set")
+	cmdFlags.StringVar(&policyFile, "policy", "", "yaml or json file of required keys and allowed values")
+	cmdFlags.BoolVar(&sync, "sync", false, "make the tags equal -tags, removing the others")
+	cmdFlags.StringVar(&protect, "protect", strings.Join(ec2tag.DefaultProtected, ","), "key patterns -sync never removes")
+	cmdFlags.StringVar(&propagate, "propagate", "", "-propagate 'volumes,enis,snapshots' copies instance tags to their dependents")
+	cmdFlags.StringVar(&propKeys, "propagate-keys", strings.Join(ec2tag.DefaultPropagated, ","), "key patterns -propagate copies")
+	cmdFlags.BoolVar(&dryrun, "dryrun", false, "print the -sync or -propagate plan without applying it")
+	cmdFlags.StringVar(&del, "delete", "", "-delete 'foo,bar=foo' deletes foo and bar where it equals foo")
+	cmdFlags.BoolVar(&list, "list", false, "list tags of -resources and/or with the keys in -tags")
+	cmdFlags.StringVar(&types, "resource-type", "", "-resource-type 'instance volume' limits -list")
+
+	if err := cmdFlags.Parse(args); err != nil {
+		return 1
+	}
+
+	if version == true {
+		c.UI.Output(c.versionInfo(ec2TagUnit))
+		return 0
+	}
+
+	if err := printer.Validate(); err != nil {
+		c.UI.Error(fmt.Sprintf("ec2_tag: %s", err))
+		return 255
+	}
+
+	account := cli.GetAccount()
+	cli.Account = account
+	c.debugf("[DEBUG]: using account: %s\n", account)
+	if account == "" || len(account) < 12 {
+		c.UI.Error(fmt.Sprintf("ec2_tag: missing or invalid account length: -account='1234556790123', received: '%s'", account))
+		return 255
+	}
+
+	modes := 0
+	for _, mode := range []bool{sync, del != "", list} {
+		if mode {
+			modes++
+		}
+	}
+	if modes > 1 {
+		c.UI.Error("ec2_tag: -sync, -delete and -list are mutually exclusive")
+		return 255
+	}
+
+	if propagate != "" && (del != "" || list) {
+		c.UI.Error("ec2_tag: -propagate only goes with tagging and -sync")
+		return 255
+	}
+
+	if list && filter != "" {
+		c.UI.Error("ec2_tag: -list filters by -resources, -resource-type and -tags, drop -filter")
+		return 255
+	}
+
+	if chunkSize < 1 || chunkSize > ec2tag.MaxResources || workers < 1 {
+		c.UI.Error(fmt.Sprintf("ec2_tag: -chunk-size must be 1 to %d and -concurrency at least 1", ec2tag.MaxResources))
+		return 255
+	}
+
+	if del != "" && (tags != "" || tagsFile != "") {
+		c.UI.Error("ec2_tag: -delete takes the tags to delete itself, drop -tags")
+		return 255
+	}
+
+	filters, err := ParseMap(filter)
+	if err != nil {
+		c.UI.Error(fmt.Sprintf("ec2_tag: -filter: %s", err))
+		return 255
+	}
+	deletes, err := ParseMap(del)
+	if err != nil {
+		c.UI.Error(fmt.Sprintf("ec2_tag: -delete: %s", err))
+		return 255
+	}
+
+	t, err := c.readTags(cli, tags, tagsFile)
+	if err != nil {
+		c.UI.Error(fmt.Sprintf("ec2_tag: %s", err))
+		return 255
+	}
+
+	var policy *ec2tag.Policy
+	if policyFile != "" {
+		if policy, err = ec2tag.LoadPolicy(policyFile); err != nil {
+			c.UI.Error(fmt.Sprintf("ec2_tag: -policy: %s", err))
+			return 255
+		}
+	}
+	if !list && del == "" {
+		if err := ec2tag.Check(t, policy); err != nil {
+			c.UI.Error(fmt.Sprintf("ec2_tag: %s", err))
+			return 255
+		}
+	}
+
+	if sync && len(t) == 0 {
+		c.UI.Error("ec2_tag: -sync needs -tags, it would remove every unprotected tag otherwise")
+		return 255
+	}
+
+	if self {
+		id, err := cli.InstanceID()
+		if err != nil {
+			c.UI.Error(fmt.Sprintf("ec2_tag: -self: looking up the instance id: %s", err))
+			return 255
+		}
+		resources = strings.TrimSpace(resources + " " + id)
+	}
+
+	c.debugf("[DEBUG]: using resource(s): %s\n", resources)
+	if !list && filter == "" && (resources == "" || len(resources) < 10) {
+		c.UI.Error(fmt.Sprintf("ec2_tag: missing or invalid resource(s): -resources='i-86424106 i-864241.. i-864242..', received: '%s'", resources))
+		return 255
+	}
+
+	c.debugf("[DEBUG]: using region: %s\n", cli.GetRegion())
+	c.debugf("[DEBUG]: using profile: %s\n", cli.GetProfile())
+
+	c.debugf("[DEBUG]: raw input: %s\n", tags)
+	for k, v := range t {
+		c.debugf("[DEBUG]: mapped: Key=%s,Value=%s\n", k, v)
+	}
+	c.debugf("[DEBUG]: creating new session...\n")
+	svc := ec2.New(cli.Session(), cli.ServiceConfig(ec2.ServiceName))
+
+	if filter != "" {
+		matched, err := c.selectResources(svc, ToSlice(types), filters)
+		if err != nil {
+			c.UI.Error(fmt.Sprintf("[ERROR]: -filter: %s", err))
+			return 254
+		}
+		if len(matched) == 0 {
+			c.UI.Error(fmt.Sprintf("[ERROR]: -filter: nothing matches '%s'", filter))
+			return 254
+		}
+		resources = strings.TrimSpace(resources + " " + strings.Join(matched, " "))
+	}
+
+	switch {
+	case del != "" && policy != nil:
+		final := func(current map[string]map[string]string) map[string]map[string]string {
+			return ec2tag.Deleted(current, deletes)
+		}
+		if code := c.checkResources(svc, ToSlice(resources), final, policy); code != 0 {
+			return code
+		}
+	case !list && del == "" && len(t) > 0:
+		final := func(current map[string]map[string]string) map[string]map[string]string {
+			if sync {
+				return ec2tag.Synced(current, t, splitList(protect))
+			}
+			return ec2tag.Merged(current, t)
+		}
+		if code := c.checkResources(svc, ToSlice(resources), final, policy); code != 0 {
+			return code
+		}
+	}
+
+	var (
+		result interface{}
+		code   int
+	)
+	switch {
+	case sync:
+		result, code = c.sync(svc, ToSlice(resources), t, splitList(protect), dryrun)
+	case list:
+		return c.list(svc, printer, &ec2tag.Query{Resources: ToSlice(resources), ResourceTypes: ToSlice(types), Tags: t})
+	case del != "":
+		return c.delete(svc, printer, ToSlice(resources), deletes)
+	case filter != "":
+		opts := ec2tag.DefaultOptions
+		opts.ChunkSize, opts.Concurrency = chunkSize, workers
+		result, code = c.tagAll(svc, ToSlice(resources), t, opts)
+	case len(t) > 0 || propagate == "":
+		result, code = c.tag(svc, ToSlice(resources), t)
+	}
+	if result == nil && code != 0 {
+		return code
+	}
+
+	if propagate != "" && code == 0 {
+		var plan *ec2tag.Plan
+		if plan, code = c.propagate(svc, ToSlice(resources), splitList(propagate), splitList(propKeys), dryrun); plan == nil {
+			return code
+		}
+		result = &propagated{Result: result, Propagated: plan}
+	}
+
+	if err := printer.Print(result); err != nil {
+		c.UI.Error(fmt.Sprintf("[ERROR]: %s", err))
+		return 253
+	}
+	return code
+}
+
+// Synopsis -
+func (c *EC2TagCommand) Synopsis() string {
+	return "Tag ec2 resources (ec2_tag)"
+}
+
+// selectResources - the ids of the resources of each type matching filters,
+// instances when no type is given
+func (c *EC2TagCommand) selectResources(svc *ec2.EC2, types []string, filters map[string]string) ([]string, error) {
+	if len(types) == 0 {
+		types = []string{ec2tag.ResourceInstance}
+	}
+	var ids []string
+	for _, resourceType := range types {
+		matched, err := ec2tag.Select(context.Background(), svc, resourceType, ec2tag.Filters(filters))
+		if err != nil {
+			return nil, err
+		}
+		c.debugf("[DEBUG]: %d %s(s) match the filter\n", len(matched), resourceType)
+		ids = append(ids, matched...)
+	}
+	return ids, nil
+}
+
+// checkResources - check the tags the resources end up with, final of their
+// current ones, against ec2's limit and the policy's required keys. Not being
+// allowed to describe the current tags only skips the limit with a warning,
+// tagging may still be allowed, but fails a policy.
+func (c *EC2TagCommand) checkResources(svc *ec2.EC2, resources []string, final func(current map[string]map[string]string) map[string]map[string]string, policy *ec2tag.Policy) int {
+	current, err := ec2tag.Current(context.Background(), svc, resources)
+	if err != nil {
+		if policy != nil {
+			c.UI.Error(fmt.Sprintf("[ERROR]: can't check the tags against the policy: %s", err))
+			return 254
+		}
+		c.UI.Warn(fmt.Sprintf("[WARN]: can't check the tags per resource limit: %s", err))
+		return 0
+	}
+
+	if err := ec2tag.CheckResources(final(current), resources, policy); err != nil {
+		c.UI.Error(fmt.Sprintf("ec2_tag: %s", err))
+		return 255
+	}
+	return 0
+}
+
+// propagated - what was tagged and the plan propagating the instances' tags
+type propagated struct {
+	Result     interface{} `json:",omitempty"`
+	Propagated *ec2tag.Plan
+}
+
+// tag - tag the resources in one call
+func (c *EC2TagCommand) tag(svc *ec2.EC2, resources []string, tags map[string]string) (interface{}, int) {
+	result, err := ec2tag.Tag(context.Background(), svc, resources, tags)
+	if err != nil {
+		c.UI.Error(fmt.Sprintf("[ERROR]: failed to tag: %s", err))
+		return nil, 254
+	}
+	c.debugf("Successfully tagged instance(s) '%s'\n", strings.Join(resources, " "))
+	return result, 0
+}
+
+// tagAll - tag the resources in concurrent chunks, the outcome of each is returned
+func (c *EC2TagCommand) tagAll(svc *ec2.EC2, resources []string, tags map[string]string, opts ec2tag.Options) (interface{}, int) {
+	result, err := ec2tag.TagAll(context.Background(), svc, resources, tags, opts)
+	if err != nil {
+		c.UI.Error(fmt.Sprintf("[ERROR]: failed to tag: %s", err))
+		return nil, 254
+	}
+
+	if failed := result.Failed(); len(failed) > 0 {
+		c.UI.Error(fmt.Sprintf("[ERROR]: failed to tag %d of %d resource(s)", len(failed), len(result.Resources)))
+		return result, 254
+	}
+	c.debugf("Successfully tagged %d resource(s)\n", len(result.Resources))
+	return result, 0
+}
+
+// sync - plan making the resources' tags equal tags, then apply it; the plan is
+// returned even when applying it failed
+func (c *EC2TagCommand) sync(svc *ec2.EC2, resources []string, tags map[string]string, protect []string, dryrun bool) (interface{}, int) {
+	ctx := context.Background()
+	plan, err := ec2tag.Sync(ctx, svc, resources, tags, protect)
+	if err != nil {
+		c.UI.Error(fmt.Sprintf("[ERROR]: failed to plan: %s", err))
+		return nil, 254
+	}
+	return plan, c.apply(svc, plan, dryrun)
+}
+
+// propagate - plan copying the instances' tags to their dependents, then apply it
+func (c *EC2TagCommand) propagate(svc *ec2.EC2, resources []string, kinds []string, keys []string, dryrun bool) (*ec2tag.Plan, int) {
+	var instances []string
+	for _, resource := range resources {
+		if strings.HasPrefix(resource, "i-") {
+			instances = append(instances, resource)
+		}
+	}
+	if len(instances) == 0 {
+		c.UI.Error("ec2_tag: -propagate needs instances among the resources")
+		return nil, 255
+	}
+
+	plan, err := ec2tag.Propagate(context.Background(), svc, instances, kinds, keys)
+	if err != nil {
+		c.UI.Error(fmt.Sprintf("[ERROR]: failed to plan propagation: %s", err))
+		return nil, 254
+	}
+	return plan, c.apply(svc, plan, dryrun)
+}
+
+// apply - apply the plan unless dryrun
+func (c *EC2TagCommand) apply(svc *ec2.EC2, plan *ec2tag.Plan, dryrun bool) int {
+	if dryrun || plan.Empty() {
+		c.debugf("[DEBUG]: %d change(s) not applied\n", len(plan.Changes))
+		return 0
+	}
+	if err := ec2tag.Apply(context.Background(), svc, plan); err != nil {
+		c.UI.Error(fmt.Sprintf("[ERROR]: failed to apply: %s", err))
+		return 254
+	}
+	c.debugf("[DEBUG]: applied %d change(s)\n", len(plan.Changes))
+	return 0
+}
+
+// list - print the tags matching q
+func (c *EC2TagCommand) list(svc *ec2.EC2, printer *output.Printer, q *ec2tag.Query) int {
+	tags, err := ec2tag.Find(context.Background(), svc, q)
+	if err != nil {
+		c.UI.Error(fmt.Sprintf("[ERROR]: failed to list: %s", err))
+		return 254
+	}
+	c.debugf("[DEBUG]: found %d tag(s)\n", len(tags))
+
+	if err := printer.Print(&ec2.DescribeTagsOutput{Tags: tags}); err != nil {
+		c.UI.Error(fmt.Sprintf("[ERROR]: %s", err))
+		return 253
+	}
+	return 0
+}
+
+// delete - delete tags from the resources and print what was asked for
+func (c *EC2TagCommand) delete(svc *ec2.EC2, printer *output.Printer, resources []string, tags map[string]string) int {
+	result, err := ec2tag.Delete(context.Background(), svc, resources, tags)
+	if err != nil {
+		c.UI.Error(fmt.Sprintf("[ERROR]: failed to delete tags: %s", err))
+		return 254
+	}
+	c.debugf("Successfully deleted tags from '%s'\n", strings.Join(resources, " "))
+
+	if err := printer.Print(result); err != nil {
+		c.UI.Error(fmt.Sprintf("[ERROR]: %s", err))
+		return 253
+	}
+	return 0
+}
+
+// readTags - the tags of -tags-file overridden by -tags, with their values rendered
+func (c *EC2TagCommand) readTags(cli *awscli.AwsCli, tags, tagsFile string) (map[string]string, error) {
+	t := make(map[string]string)
+	if tagsFile != "" {
+		var err error
+		if t, err = ec2tag.ReadFile(tagsFile); err != nil {
+			return nil, fmt.Errorf("-tags-file: %s", err)
+		}
+		c.debugf("[DEBUG]: read %d tag(s) from %s\n", len(t), tagsFile)
+	}
+	pairs, err := ParseMap(tags)
+	if err != nil {
+		return nil, fmt.Errorf("-tags: %s", err)
+	}
+	for k, v := range pairs {
+		t[k] = v
+	}
+
+	var md ec2tag.Metadata
+	if !awscli.MetadataDisabled() {
+		md = cli.MetadataClient()
+	}
+	return ec2tag.Render(t, ec2tag.NewTemplateData(md))
+}
@@ -0,0 +1,141 @@
+package command
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/ec2/ec2iface"
+
+	"github.com/aidevops/awscli"
+	"github.com/aidevops/awscli/ec2wait"
+)
+
+// Exit codes of ec2 wait beyond 0 and 1 for usage and api errors
+const (
+	WaitFailed   = 2
+	WaitTimedOut = 3
+)
+
+// EC2WaitCommand - wait for ec2 resources to reach a state
+type EC2WaitCommand struct {
+	Meta
+}
+
+// Help -
+func (c *EC2WaitCommand) Help() string {
+	helpText := `
+Usage: awscli ec2 wait <condition> -ids=list [options]
+
+  Poll until every resource meets the condition, printing progress after
+  each poll. Exits 0 once they do, 2 when one reaches a state it can't get
+  there from (e.g. terminated while waiting for running), 3 on timeout and
+  1 on any other error.
+
+  Conditions: ` + strings.Join(ec2wait.Names(), ", ") + `
+
+Options:
+
+  -ids=list          Space separated ids of the resources, 'i-86424106 i-864241..'.
+
+  -interval=15s      Time between polls, defaults to the sdk waiter's delay.
+
+  -timeout=10m       Time to give up after, defaults to the sdk waiter's
+                     delay times its attempts.
+
+  -region=name       AWS region, defaults to $AWS_REGION, the profile's
+                     region, the instance's region, then us-east-1.
+
+  -profile=name      AWS shared credentials profile.
+
+  -verbose=true      Display additional information from
+                     behind the scenes.
+`
+	return strings.TrimSpace(helpText)
+}
+
+// Run -
+func (c *EC2WaitCommand) Run(args []string) int {
+	var (
+		condition string
+		ids       string
+		interval  time.Duration
+		timeout   time.Duration
+	)
+
+	cli := &awscli.AwsCli{}
+	cmdFlags := flag.NewFlagSet("ec2 wait", flag.ContinueOnError)
+	cmdFlags.Usage = func() { c.UI.Output(c.Help()) }
+
+	cli.SetFlags(cmdFlags)
+	cmdFlags.StringVar(&cli.Region, "region", "", "AWS region, defaults to $AWS_REGION, the profile's region, the instance's region, then us-east-1. E.g. -region=us-east-1")
+	cmdFlags.StringVar(&ids, "ids", "", "-ids 'i-86424106 i-86424107'")
+	cmdFlags.DurationVar(&interval, "interval", 0, "time between polls. E.g. -interval=5s")
+	cmdFlags.DurationVar(&timeout, "timeout", 0, "time to give up after. E.g. -timeout=10m")
+	cmdFlags.BoolVar(&c.verbose, "verbose", false, "be more verbose.....")
+
+	// the condition comes first, flags stop parsing at it otherwise
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		condition, args = args[0], args[1:]
+	}
+	if err := cmdFlags.Parse(args); err != nil {
+		return 1
+	}
+	if rest := cmdFlags.Args(); condition == "" && len(rest) == 1 {
+		condition = rest[0]
+	} else if len(rest) > 0 {
+		c.UI.Error(fmt.Sprintf("ec2 wait: unexpected arguments '%s'", strings.Join(rest, " ")))
+		return 1
+	}
+
+	if _, ok := ec2wait.Conditions[condition]; !ok {
+		c.UI.Error(fmt.Sprintf("ec2 wait: unknown condition '%s', expected one of: %s", condition, strings.Join(ec2wait.Names(), ", ")))
+		return 1
+	}
+	if len(ToSlice(ids)) == 0 {
+		c.UI.Error("ec2 wait: missing resource ids: -ids='i-86424106 i-864241..'")
+		return 1
+	}
+	if interval < 0 || timeout < 0 {
+		c.UI.Error("ec2 wait: -interval and -timeout can't be negative")
+		return 1
+	}
+
+	c.debugf("[DEBUG]: using region: %s\n", cli.GetRegion())
+	svc := ec2.New(cli.Session(), cli.ServiceConfig(ec2.ServiceName))
+	if code := c.wait(context.Background(), svc, condition, ToSlice(ids), interval, timeout); code != 0 {
+		return code
+	}
+	c.UI.Info(fmt.Sprintf("%s: %s", condition, ids))
+	return 0
+}
+
+// wait - wait for the condition printing progress, the exit code tells how it went
+func (m *Meta) wait(ctx context.Context, svc ec2iface.EC2API, condition string, ids []string, interval, timeout time.Duration) int {
+	opts := ec2wait.Options{
+		Interval: interval,
+		Timeout:  timeout,
+		Progress: func(a ec2wait.Attempt) { m.UI.Info(a.String()) },
+	}
+	switch err := ec2wait.Wait(ctx, svc, condition, ids, opts); err {
+	case nil:
+		return 0
+	case ec2wait.ErrFailed:
+		m.UI.Error(fmt.Sprintf("[ERROR]: %s: %s", condition, err))
+		return WaitFailed
+	case ec2wait.ErrTimeout:
+		m.UI.Error(fmt.Sprintf("[ERROR]: %s: %s", condition, err))
+		return WaitTimedOut
+	default:
+		m.UI.Error(fmt.Sprintf("[ERROR]: %s: %s", condition, err))
+		return 1
+	}
+}
+
+// Synopsis -
+func (c *EC2WaitCommand) Synopsis() string {
+	return "Wait for ec2 resources to reach a state"
+}
@@ -1,7 +1,6 @@
 package command
 
 import (
-	"encoding/base64"
 	"flag"
 	"fmt"
 	"strings"
@@ -10,6 +9,7 @@ import (
 
 	"github.com/aidevops/awscli"
 	"github.com/aidevops/awscli/logger"
+	"github.com/aidevops/awscli/output"
 )
 
 // ECRCommand -
@@ -26,6 +26,18 @@ Usage: awscli ecr [options] name
 
 Options:
   
+  -region=name   AWS region, defaults to $AWS_REGION, the profile's
+                 region, the instance's region, then us-east-1.
+
+  -profile=name  AWS shared credentials profile.
+
+  -output=json   Output format: json, text, table or yaml.
+
+  -query=expr    JMESPath expression applied to the response before
+                 printing it, e.g. -query='Repositories[].RepositoryUri'.
+
+  -columns=list  Table and text columns as 'Header=expression' pairs.
+
   -verbose=true  Display additional information from 
                  behind the scenes.
 `
@@ -36,18 +48,21 @@ Options:
 func (c *ECRCommand) Run(args []string) int {
 	var (
 		account string
-		region  string
 		format  string
 		level   string
 		logfile string
 		verbose bool
 	)
 
+	cli := &awscli.AwsCli{}
+	printer := output.New("")
 	cmdFlags := flag.NewFlagSet("ecr", flag.ContinueOnError)
 	cmdFlags.Usage = func() { c.UI.Output(c.Help()) }
 
+	cli.SetFlags(cmdFlags)
+	printer.SetFlags(cmdFlags)
 	cmdFlags.StringVar(&account, "account", "", "AWS account #.")
-	cmdFlags.StringVar(&region, "region", "", "AWS region.")
+	cmdFlags.StringVar(&cli.Region, "region", "", "AWS region.")
 	cmdFlags.StringVar(&format, "format", "text", "Format response as either json or regular text.")
 	cmdFlags.StringVar(&level, "level", "info", "logging level: error, warn, info, or debug")
 	cmdFlags.StringVar(&logfile, "log", "/tmp/cloudconfig.log", "logfile path")
@@ -57,6 +72,11 @@ func (c *ECRCommand) Run(args []string) int {
 		return 1
 	}
 
+	if err := printer.Validate(); err != nil {
+		c.UI.Error(err.Error())
+		return 1
+	}
+
 	args = cmdFlags.Args()
 	if len(args) < 1 {
 		c.UI.Error("arguments must be specified.")
@@ -72,19 +92,18 @@ func (c *ECRCommand) Run(args []string) int {
 
 	ecr := args[0]
 
-	c.UI.Output(fmt.Sprintf("Setting ecr to '%s'! Verbosity enabled: %#v",
-		ecr, verbose))
+	// stdout is kept for the result so scripts can parse it
+	if verbose {
+		c.UI.Output(fmt.Sprintf("Setting ecr to '%s'! Verbosity enabled: %#v",
+			ecr, verbose))
+	}
 
 	log := logger.NewCLILogger(level, logfile, "ecr", format, c.UI)
 
-	awscli.ECRInfo(account)
-	token, _ := awscli.ECRLogin(account)
-	decoded, err := base64.StdEncoding.DecodeString(token)
-	if err != nil {
-		fmt.Println("decode error:", err)
-		return 255
+	if err := cli.ECRInfo(account, printer); err != nil {
+		c.UI.Error(err.Error())
+		return 1
 	}
-	fmt.Println(string(decoded))
 	log.Flush()
 
 	return 0
@@ -0,0 +1,184 @@
+package command
+
+import (
+	"bufio"
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os/exec"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/service/ecr"
+
+	"github.com/aidevops/awscli"
+	"github.com/aidevops/awscli/ecrlogin"
+)
+
+// ecrLoginUnit - the standalone tool this command replaces
+const ecrLoginUnit = "ecr_login"
+
+// ECRLoginCommand - print or run the docker login for an ecr registry
+type ECRLoginCommand struct {
+	Meta
+}
+
+// Help -
+func (c *ECRLoginCommand) Help() string {
+	helpText := `
+Usage: awscli ecr login [options]
+       ecr_login [options]
+
+  Print the docker login command for an ecr registry, suitable for
+  'eval $(awscli ecr login -account=...)', or run it with -login.
+
+Options:
+
+  -account=id        AWS account # owning the registry, defaults to the
+                     instance's account.
+
+  -login=true        Run docker login instead of printing the command.
+
+  -region=name       AWS region, defaults to $AWS_REGION, the profile's
+                     region, the instance's region, then us-east-1.
+
+  -profile=name      AWS shared credentials profile.
+
+  -output=text       Output format: json, text, table or yaml.
+
+  -query=expr        JMESPath expression applied to the authorization
+                     response, printed instead of the login command.
+
+  -verbose=true      Display additional information from
+                     behind the scenes.
+`
+	return strings.TrimSpace(helpText)
+}
+
+// Run -
+func (c *ECRLoginCommand) Run(args []string) int {
+	var (
+		version bool
+		login   bool
+	)
+
+	cli := &awscli.AwsCli{}
+	// text keeps 'eval $(ecr_login ...)' working
+	printer := c.printer("text")
+	cmdFlags := flag.NewFlagSet("ecr login", flag.ContinueOnError)
+	cmdFlags.Usage = func() { c.UI.Output(c.Help()) }
+
+	cli.SetFlags(cmdFlags)
+	printer.SetFlags(cmdFlags)
+	cmdFlags.StringVar(&cli.Account, "account", "", "AWS account #, defaults to the instance's account. E.g. -account='1234556790123'")
+	cmdFlags.StringVar(&cli.Region, "region", "", "AWS region, defaults to $AWS_REGION, the profile's region, the instance's region, then us-east-1. E.g. -region=us-east-1")
+	cmdFlags.BoolVar(&c.verbose, "verbose", false, "be more verbose.....")
+	cmdFlags.BoolVar(&version, "version", false, "print version and exit")
+	cmdFlags.BoolVar(&login, "login", false, "docker login on your behalf, otherwise return login string")
+
+	if err := cmdFlags.Parse(args); err != nil {
+		return 1
+	}
+
+	if version == true {
+		c.UI.Output(c.versionInfo(ecrLoginUnit))
+		return 0
+	}
+
+	account := cli.GetAccount()
+	cli.Account = account
+	c.debugf("[DEBUG]: using account: %s\n", account)
+	c.debugf("[DEBUG]: checking length: %d\n", len(account))
+	if account == "" || len(account) < 12 {
+		c.UI.Error(fmt.Sprintf("ecr_login: missing or invalid account length: -account='1234556790123', received: '%s'", account))
+		return 255
+	}
+
+	if err := printer.Validate(); err != nil {
+		c.UI.Error(fmt.Sprintf("ecr_login: %s", err))
+		return 255
+	}
+
+	// -query selects from the authorization response instead of the login command
+	if printer.Query != "" && login {
+		c.UI.Error("ecr_login: -query and -login are mutually exclusive")
+		return 255
+	}
+
+	c.debugf("[DEBUG]: using region: %s\n", cli.GetRegion())
+	c.debugf("[DEBUG]: using profile: %s\n", cli.GetProfile())
+
+	ctx := context.Background()
+	c.debugf("[DEBUG]: creating new session...\n")
+	svc := ecr.New(cli.Session(), cli.ServiceConfig(ecr.ServiceName))
+
+	if printer.Query != "" {
+		resp, err := ecrlogin.Authorization(ctx, svc, account)
+		if err != nil {
+			c.UI.Error(fmt.Sprintf("[ERROR]: fetching authorization: %s", err))
+			return 254
+		}
+		if err := printer.Print(resp); err != nil {
+			c.UI.Error(fmt.Sprintf("[ERROR]: %s", err))
+			return 253
+		}
+		return 0
+	}
+
+	c.debugf("[DEBUG]: generating login credentials...\n")
+	creds, err := ecrlogin.Login(ctx, svc, account)
+
+	if err != nil {
+		c.UI.Error(fmt.Sprintf("[ERROR]: generating login credentials: %s", err))
+		return 254
+	}
+
+	c.debugf("[DEBUG]: credentials valid until: %s...\n", creds.ExpiresAt.String())
+	c.debugf("[DEBUG]: generating login command\n")
+	args = creds.DockerArgs()
+
+	if login == true {
+		c.debugf("[DEBUG]: executing command: 'docker %s'\n", strings.Join(args, " "))
+		cmd := exec.Command("docker", args...)
+		stdout, err := cmd.StdoutPipe()
+		if err != nil {
+			c.UI.Error(fmt.Sprintf("[ERROR]: failed to open stdout: %s", err))
+			return 252
+		}
+		stderr, err := cmd.StderrPipe()
+		if err != nil {
+			c.UI.Error(fmt.Sprintf("[ERROR]: failed to open stderr: %s", err))
+			return 251
+		}
+
+		// start the command after having set up the pipes
+		if err = cmd.Start(); err != nil {
+			c.UI.Error(fmt.Sprintf("[ERROR]: failed to start command: %s", err))
+			return 250
+		}
+
+		// collect both pipes together
+		multi := io.MultiReader(stdout, stderr)
+		// read command's stdout & stderr line by line
+		in := bufio.NewScanner(multi)
+
+		for in.Scan() {
+			c.UI.Output(in.Text())
+		}
+
+		if err = cmd.Wait(); err != nil {
+			c.UI.Error(fmt.Sprintf("[ERROR]: failed while waiting for command to complete: %s", err))
+			return 249
+		}
+	} else if err := printer.Print("docker " + strings.Join(args, " ")); err != nil {
+		c.UI.Error(fmt.Sprintf("[ERROR]: %s", err))
+		return 248
+	}
+
+	return 0
+}
+
+// Synopsis -
+func (c *ECRLoginCommand) Synopsis() string {
+	return "Docker login to an ecr registry (ecr_login)"
+}
@@ -0,0 +1,154 @@
+// Package command -
+package command
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/mitchellh/cli"
+
+	"github.com/aidevops/awscli/output"
+)
+
+// Meta - state shared by the tool commands: the ui, version info and -verbose
+type Meta struct {
+	UI                cli.Ui
+	Revision          string
+	Version           string
+	VersionPrerelease string
+
+	verbose bool
+}
+
+// debugf - print to stdout if verbose is enabled....
+func (m *Meta) debugf(format string, args ...interface{}) {
+	if m.verbose == true {
+		fmt.Printf(format, args...)
+	}
+}
+
+// versionInfo - version info printed by -version, unit is the tool's name
+func (m *Meta) versionInfo(unit string) string {
+	return fmt.Sprintf("%s v%s.%s (%s)", unit, m.Version, m.VersionPrerelease, m.Revision)
+}
+
+// printer - an output.Printer defaulting to format that writes through the ui
+func (m *Meta) printer(format string) *output.Printer {
+	p := output.New(format)
+	p.Out = &uiWriter{ui: m.UI}
+	return p
+}
+
+// uiWriter - io.Writer handing each write to the ui as one block of output
+type uiWriter struct {
+	ui cli.Ui
+}
+
+// Write -
+func (w *uiWriter) Write(b []byte) (int, error) {
+	if len(b) > 0 {
+		w.ui.Output(strings.TrimSuffix(string(b), "\n"))
+	}
+	return len(b), nil
+}
+
+// ToMap - Convert options into a go map
+func ToMap(data string) map[string]string {
+	opts := make(map[string]string)
+	for _, pair := range toPairs(data) {
+		opts[pair[0]] = pair[1]
+	}
+	return opts
+}
+
+// ParseMap - like ToMap, but an empty key or a key given twice is an error
+func ParseMap(data string) (map[string]string, error) {
+	opts := make(map[string]string)
+	for pos, pair := range toPairs(data) {
+		if strings.TrimSpace(pair[0]) == "" {
+			return nil, fmt.Errorf("pair %d of '%s' has no key", pos+1, data)
+		}
+		if _, ok := opts[pair[0]]; ok {
+			return nil, fmt.Errorf("key '%s' is given twice in '%s'", pair[0], data)
+		}
+		opts[pair[0]] = pair[1]
+	}
+	return opts, nil
+}
+
+// toPairs - split 'key=value,key2="value 2"' into unquoted key, value pairs
+func toPairs(data string) [][2]string {
+	var pairs [][2]string
+	if data == "" {
+		return pairs
+	}
+
+	sanitized, err := strconv.Unquote(data)
+	if err != nil {
+		sanitized = data
+	}
+
+	re1, err := regexp.Compile(",")
+	if err != nil {
+		return pairs
+	}
+	for _, field := range re1.Split(sanitized, -1) {
+		re2, err := regexp.Compile("=")
+		if err != nil {
+			return pairs
+		}
+		pair := re2.Split(field, 2)
+		key := pair[0]
+		var val string
+		if len(pair) == 2 {
+			cleaned, err := strconv.Unquote(pair[1])
+			if err != nil {
+				val = pair[1]
+			} else {
+				val = cleaned
+			}
+		} else {
+			val = ""
+		}
+
+		pairs = append(pairs, [2]string{key, val})
+	}
+	return pairs
+}
+
+// ToSlice - return a string of space delimited arguments as a []string slice
+func ToSlice(data string) (slice []string) {
+	if data == "" {
+		return slice
+	}
+
+	list := strings.Fields(data)
+	slice = make([]string, len(list))
+	for pos, field := range list {
+		slice[pos] = field
+	}
+	return slice
+}
+
+// splitList - split a comma separated list, dropping blanks
+func splitList(data string) []string {
+	var list []string
+	for _, item := range strings.Split(data, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+// contains -
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
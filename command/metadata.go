@@ -0,0 +1,109 @@
+package command
+
+import (
+	"flag"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/aidevops/awscli/imds"
+)
+
+// MetadataServeCommand - serve an emulated ec2 instance metadata service
+type MetadataServeCommand struct {
+	Meta
+}
+
+// Help -
+func (c *MetadataServeCommand) Help() string {
+	helpText := `
+Usage: awscli metadata serve [options]
+
+  Emulate the ec2 instance metadata service (IMDSv1 and v2) for the
+  instance described by a yaml fixture, so boot time tooling can run
+  off ec2. Point clients at it with
+  AWS_EC2_METADATA_SERVICE_ENDPOINT=http://<listen> or
+  -endpoint-url='ec2metadata=http://<listen>'.
+
+  Fixture keys: instance-id, instance-type, ami-id, account-id, region,
+  availability-zone, private-ip, hostname, user-data, http-tokens
+  (optional or required), iam (role, access-key-id, secret-access-key,
+  token, expiration) and meta-data (extra 'path: value' entries).
+
+Options:
+
+  -fixture=path      Yaml fixture, a t2.micro in us-east-1a when empty.
+
+  -listen=addr       Address to listen on, defaults to 127.0.0.1:1338.
+
+  -http-tokens=mode  Override the fixture's http-tokens, optional or
+                     required.
+
+  -verbose=true      Log every request.
+`
+	return strings.TrimSpace(helpText)
+}
+
+// Run -
+func (c *MetadataServeCommand) Run(args []string) int {
+	var (
+		fixturePath string
+		listen      string
+		httpTokens  string
+	)
+
+	cmdFlags := flag.NewFlagSet("metadata serve", flag.ContinueOnError)
+	cmdFlags.Usage = func() { c.UI.Output(c.Help()) }
+
+	cmdFlags.StringVar(&fixturePath, "fixture", "", "yaml fixture describing the instance. E.g. -fixture=imds.yaml")
+	cmdFlags.StringVar(&listen, "listen", "127.0.0.1:1338", "address to listen on. E.g. -listen=0.0.0.0:1338")
+	cmdFlags.StringVar(&httpTokens, "http-tokens", "", "optional or required, overrides the fixture")
+	cmdFlags.BoolVar(&c.verbose, "verbose", false, "log every request")
+
+	if err := cmdFlags.Parse(args); err != nil {
+		return 1
+	}
+
+	fixture := imds.DefaultFixture()
+	if fixturePath != "" {
+		var err error
+		if fixture, err = imds.LoadFixture(fixturePath); err != nil {
+			c.UI.Error(fmt.Sprintf("metadata serve: %s", err))
+			return 1
+		}
+	}
+	if httpTokens != "" {
+		fixture.HTTPTokens = httpTokens
+		if err := fixture.Validate(); err != nil {
+			c.UI.Error(fmt.Sprintf("metadata serve: -http-tokens: %s", err))
+			return 1
+		}
+	}
+
+	srv := imds.New(fixture)
+	if c.verbose {
+		srv.Log = func(method, path string, status int) {
+			c.UI.Info(fmt.Sprintf("%s %s %d", method, path, status))
+		}
+	}
+
+	listener, err := net.Listen("tcp", listen)
+	if err != nil {
+		c.UI.Error(fmt.Sprintf("metadata serve: %s", err))
+		return 1
+	}
+
+	c.UI.Output(fmt.Sprintf("serving instance metadata for %s (%s, account %s, http-tokens %s) on http://%s",
+		fixture.InstanceID, fixture.AvailabilityZone, fixture.AccountID, fixture.HTTPTokens, listener.Addr()))
+	if err := http.Serve(listener, srv); err != nil {
+		c.UI.Error(fmt.Sprintf("metadata serve: %s", err))
+		return 1
+	}
+	return 0
+}
+
+// Synopsis -
+func (c *MetadataServeCommand) Synopsis() string {
+	return "Serve an emulated ec2 instance metadata service"
+}
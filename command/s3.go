@@ -0,0 +1,194 @@
+package command
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3manager"
+
+	"github.com/aidevops/awscli"
+	"github.com/aidevops/awscli/s3util"
+)
+
+// s3Unit - the standalone tool this command replaces
+const s3Unit = "s3_util"
+
+// S3Command - put or get s3 objects. Mode is set by the 's3 put' and 's3 get'
+// subcommands, plain 's3' (and s3_util) takes -put or -get.
+type S3Command struct {
+	Meta
+	Mode string
+}
+
+// Help -
+func (c *S3Command) Help() string {
+	helpText := `
+Usage: awscli s3 put|get [options]
+       s3_util -put|-get [options]
+
+  Upload a file to, or download an object from, an s3 bucket.
+
+Options:
+
+  -bucket=name       Bucket name.
+
+  -src=path          File to upload, or key to download.
+
+  -dst=path          Destination key, or file to download to.
+
+  -region=name       AWS region, defaults to $AWS_REGION, the profile's
+                     region, the instance's region, then us-east-1.
+
+  -profile=name      AWS shared credentials profile.
+
+  -output=json       Output format: json, text, table or yaml.
+
+  -query=expr        JMESPath expression applied to the result.
+
+  -verbose=true      Display additional information from
+                     behind the scenes.
+`
+	return strings.TrimSpace(helpText)
+}
+
+// Run -
+func (c *S3Command) Run(args []string) int {
+	var (
+		bucket  string
+		retry   int64
+		src     string
+		dst     string
+		put     bool
+		get     bool
+		version bool
+	)
+
+	cli := &awscli.AwsCli{}
+	printer := c.printer("json")
+	cmdFlags := flag.NewFlagSet("s3", flag.ContinueOnError)
+	cmdFlags.Usage = func() { c.UI.Output(c.Help()) }
+
+	cli.SetFlags(cmdFlags)
+	printer.SetFlags(cmdFlags)
+	cmdFlags.StringVar(&cli.Region, "region", "", "AWS region, defaults to $AWS_REGION, the profile's region, the instance's region, then us-east-1. E.g. -region=us-east-1")
+	cmdFlags.StringVar(&bucket, "bucket", "", "mybucket-name...")
+	cmdFlags.Int64Var(&retry, "retry", 3, "number of times to attempt the operation - not implemented")
+	cmdFlags.StringVar(&src, "src", "", "/path/to/my/object")
+	cmdFlags.StringVar(&dst, "dst", "", "/path/to/my/object")
+	cmdFlags.BoolVar(&put, "put", c.Mode == "put", "put object")
+	cmdFlags.BoolVar(&get, "get", c.Mode == "get", "get object")
+	cmdFlags.BoolVar(&c.verbose, "verbose", false, "be more verbose.....")
+	cmdFlags.BoolVar(&version, "version", false, "print version and exit")
+
+	if err := cmdFlags.Parse(args); err != nil {
+		return 1
+	}
+
+	if version == true {
+		c.UI.Output(c.versionInfo(s3Unit))
+		return 0
+	}
+
+	if !put && !get {
+		c.UI.Error("s3_util: you need to specify either -put or -get")
+		return 1
+	}
+
+	if put && get {
+		c.UI.Error("s3_util: put and get are mutually exclusive")
+		return 1
+	}
+
+	c.debugf("[DEBUG]: using retry count: %d\n", retry)
+	if retry < 0 || retry > 10 {
+		c.UI.Error("s3_util: invalid count valid values 1 - 10")
+		return 255
+	}
+
+	c.debugf("[DEBUG]: using bucket name(s): %s\n", bucket)
+	if bucket == "" || len(bucket) < 3 {
+		c.UI.Error(fmt.Sprintf("s3_util: missing or invalid bucket: -bucket='some-fancy-bucket..', received: '%s'", bucket))
+		return 253
+	}
+
+	if err := printer.Validate(); err != nil {
+		c.UI.Error(fmt.Sprintf("s3_util: %s", err))
+		return 1
+	}
+
+	c.debugf("[DEBUG]: using region: %s\n", cli.GetRegion())
+	c.debugf("[DEBUG]: using profile: %s\n", cli.GetProfile())
+
+	c.debugf("[DEBUG]: creating new session...\n")
+	svc := s3.New(cli.Session(), cli.ServiceConfig(s3.ServiceName))
+
+	var result interface{}
+	var err error
+	if put {
+		result, err = c.put(svc, bucket, src)
+	}
+
+	if get {
+		result, err = c.get(svc, bucket, src, dst)
+	}
+
+	if err == nil {
+		err = printer.Print(result)
+	}
+
+	if err != nil {
+		c.UI.Error(fmt.Sprintf("[ERROR]: failed while processing request: %s", err))
+		return 253
+	}
+
+	return 0
+}
+
+// Synopsis -
+func (c *S3Command) Synopsis() string {
+	switch c.Mode {
+	case "put":
+		return "Upload a file to s3"
+	case "get":
+		return "Download an object from s3"
+	}
+	return "Upload to or download from s3 (s3_util)"
+}
+
+// put - upload the file src as the key src
+func (c *S3Command) put(svc *s3.S3, bucket, src string) (*s3manager.UploadOutput, error) {
+	file, err := os.Open(src)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open source file '%s': %s", src, err)
+	}
+	defer file.Close()
+
+	c.debugf("[DEBUG]: uploading...\n")
+	resp, err := s3util.Put(context.Background(), svc, bucket, src, file)
+	if err != nil {
+		return nil, err
+	}
+	c.debugf("[DEBUG]: Successfully placed file(s) '%s' into '%s'\n", src, resp.Location)
+	return resp, nil
+}
+
+// get - download the key src into the file dst
+func (c *S3Command) get(svc *s3.S3, bucket, src, dst string) (*s3util.GetResult, error) {
+	file, err := os.Create(dst)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to create file '%s': %s", dst, err)
+	}
+	defer file.Close()
+
+	c.debugf("[DEBUG]: downloading...\n")
+	n, err := s3util.Get(context.Background(), svc, bucket, src, file)
+	if err != nil {
+		return nil, err
+	}
+	c.debugf("[DEBUG]: Successfully retrieved file(s) '%s' from '%s%s' - %d\n", dst, bucket, src, n)
+	return &s3util.GetResult{Bucket: bucket, Key: src, File: dst, ContentLength: n}, nil
+}
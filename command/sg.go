@@ -0,0 +1,421 @@
+package command
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/service/ec2"
+
+	"github.com/aidevops/awscli"
+	"github.com/aidevops/awscli/output"
+	"github.com/aidevops/awscli/sgregister"
+)
+
+// sgUnit - the standalone tool this command replaces
+const sgUnit = "sg_register"
+
+// SGUnchanged - exit status of a register finding the rule already there, or a
+// deregister finding it already gone
+const SGUnchanged = 2
+
+// SGCommand - register or deregister security group rules, sync a group's
+// rules with a rules file, or reap expired rules. Mode is set by the
+// 'sg register', 'sg deregister', 'sg sync' and 'sg reap' subcommands, plain
+// 'sg' (and sg_register) takes -register, -deregister, -sync or -reap.
+type SGCommand struct {
+	Meta
+	Mode string
+
+	dryrun bool
+	vpcID  string
+	tags   map[string]string
+}
+
+// Help -
+func (c *SGCommand) Help() string {
+	helpText := `
+Usage: awscli sg register|deregister [options]
+       awscli sg sync -rules=file [options]
+       awscli sg reap [options]
+       sg_register -register|-deregister|-sync|-reap [options]
+
+  Authorize or revoke security group ingress, or egress, for ipv4 and ipv6
+  cidrs and other security groups, all in one call. A rule that is already
+  registered, or deregistered, isn't an error: it is reported and the exit
+  status is 2, unless -strict is given.
+
+  Registering with -ttl records the rule's expiry in its description,
+  'sg_register expires 2006-01-02T15:04:05Z'. Reap revokes the rules of
+  -sg-id or -sg-name, or of every group when neither is given, whose
+  expiry has passed and prints them, e.g. from cron:
+
+    */10 * * * * sg_register -reap -output=text
+
+  Sync makes a group's rules those of a yaml or json rules file: it
+  prints the difference, authorizes the missing rules, updates changed
+  descriptions, then revokes the rules the file doesn't have. A direction
+  the file leaves out is left alone, 'egress: []' revokes every egress rule.
+
+    ingress:
+      - protocol: tcp          # tcp, udp, icmp, icmpv6, all or a number
+        ports: 443             # tcp and udp: 443, 8000-8100 or all
+        cidrs: [10.0.0.0/8, "2001:db8::/32"]
+        groups: [lb, sg-1a2b, 123456789012/sg-3c4d]
+        description: https
+      - protocol: icmp
+        type: 8                # icmp type and code, any when left out
+        cidrs: [10.0.0.0/8]
+    egress:
+      - protocol: all
+        cidrs: [0.0.0.0/0]
+        prefix_lists: [pl-1a2b]
+
+  Group names are looked up in the group's vpc, groups of other accounts
+  are given by id prefixed with the account id.
+
+Options:
+
+  -sg-id=id          Security group id.
+
+  -sg-name=name      Security group name, looked up when -sg-id is not set.
+                     It must match one group, several are listed.
+
+  -vpc-id=id         Look -sg-name up in this vpc.
+
+  -sg-tag=list       Tags the -sg-name group has, 'environment=prod,role'.
+
+  -ip=list           Comma separated ipv4 and ipv6 cidrs to register,
+                     defaults to 0.0.0.0/0 without -source-group.
+
+  -source-group=list Comma separated security groups to register: names
+                     in the group's vpc, ids, or account/sg-1a2b ids of
+                     groups in another account.
+
+  -egress=true       Register egress rather than ingress.
+
+  -protocol=tcp      Protocol: tcp, udp, icmp, icmpv6, all (-1) or a
+                     protocol number.
+
+  -from-port=443     Start of the tcp or udp port range, or the icmp type,
+                     any type when not set. Other protocols have no ports.
+
+  -to-port=n         End of the tcp or udp port range, defaults to
+                     -from-port, or the icmp code, any code when not set.
+
+  -ttl=2h            Register the rule for this long, for reap to revoke.
+
+  -strict=true       Fail, exit status 253, when the rule is already
+                     registered or deregistered.
+
+  -rules=file        The rules file to sync the group with.
+
+  -dryrun=true       Perform a dry run, sync only prints the difference and
+                     reap the expired rules.
+
+  -region=name       AWS region, defaults to $AWS_REGION, the profile's
+                     region, the instance's region, then us-east-1.
+
+  -profile=name      AWS shared credentials profile.
+
+  -output=json       Output format: json, text, table or yaml.
+
+  -query=expr        JMESPath expression applied to the result.
+
+  -verbose=true      Display additional information from
+                     behind the scenes.
+`
+	return strings.TrimSpace(helpText)
+}
+
+// Run -
+func (c *SGCommand) Run(args []string) int {
+	var (
+		ip         string
+		groups     string
+		egress     bool
+		protocol   string
+		fromPort   int64
+		toPort     int64
+		sid        string
+		name       string
+		register   bool
+		deregister bool
+		sync       bool
+		rules      string
+		reap       bool
+		ttl        time.Duration
+		strict     bool
+		sgTag      string
+		version    bool
+	)
+
+	cli := &awscli.AwsCli{}
+	printer := c.printer("json")
+	cmdFlags := flag.NewFlagSet("sg", flag.ContinueOnError)
+	cmdFlags.Usage = func() { c.UI.Output(c.Help()) }
+
+	cli.SetFlags(cmdFlags)
+	printer.SetFlags(cmdFlags)
+	cmdFlags.StringVar(&ip, "ip", "", "ipv4 and ipv6 cidrs to register, defaults to 0.0.0.0/0 without -source-group")
+	cmdFlags.StringVar(&groups, "source-group", "", "security group names or ids to register")
+	cmdFlags.BoolVar(&egress, "egress", false, "register egress rather than ingress")
+	cmdFlags.StringVar(&protocol, "protocol", "tcp", "protocol to register 'tcp','udp','icmp','icmpv6','all'")
+	cmdFlags.Int64Var(&fromPort, "from-port", 443, "start port range to register access to...")
+	cmdFlags.Int64Var(&toPort, "to-port", -1, "end port range to register access to...")
+	cmdFlags.StringVar(&sid, "sg-id", "", "security group id to work against (mutually exclusive to name - not implemented)")
+	cmdFlags.StringVar(&name, "sg-name", "", "security group name to work against (mutually exclusive to sg-id)")
+	cmdFlags.StringVar(&c.vpcID, "vpc-id", "", "vpc to look -sg-name up in")
+	cmdFlags.StringVar(&sgTag, "sg-tag", "", "-sg-tag 'environment=prod,role' tags the -sg-name group has")
+	cmdFlags.StringVar(&cli.Region, "region", "", "region sg lives in, defaults to $AWS_REGION, the profile's region, the instance's region, then us-east-1...")
+	cmdFlags.BoolVar(&register, "register", c.Mode == "register", "register with security group ingress.....")
+	cmdFlags.BoolVar(&deregister, "deregister", c.Mode == "deregister", "deregister with security group ingress.....")
+	cmdFlags.BoolVar(&sync, "sync", c.Mode == "sync", "sync the security group's rules with -rules")
+	cmdFlags.StringVar(&rules, "rules", "", "yaml or json rules file to sync with")
+	cmdFlags.BoolVar(&reap, "reap", c.Mode == "reap", "revoke rules whose -ttl has passed")
+	cmdFlags.DurationVar(&ttl, "ttl", 0, "register the rule for this long, e.g. -ttl=2h")
+	cmdFlags.BoolVar(&strict, "strict", false, "fail when the rule is already registered or deregistered")
+	cmdFlags.BoolVar(&c.verbose, "verbose", false, "be more verbose.....")
+	cmdFlags.BoolVar(&version, "version", false, "print version and exit")
+	cmdFlags.BoolVar(&c.dryrun, "dryrun", false, "perform dryrun and exit")
+
+	if err := cmdFlags.Parse(args); err != nil {
+		return 1
+	}
+
+	if version == true {
+		c.UI.Output(c.versionInfo(sgUnit))
+		return 0
+	}
+
+	if err := printer.Validate(); err != nil {
+		c.UI.Error(fmt.Sprintf("sg_register: %s", err))
+		return 1
+	}
+
+	var err error
+	if c.tags, err = ParseMap(sgTag); err != nil {
+		c.UI.Error(fmt.Sprintf("sg_register: -sg-tag: %s", err))
+		return 1
+	}
+	if (c.vpcID != "" || len(c.tags) > 0) && name == "" {
+		c.UI.Error("sg_register: -vpc-id and -sg-tag narrow down the -sg-name lookup")
+		return 1
+	}
+
+	if reap {
+		if register || deregister || sync {
+			c.UI.Error("sg_register: reap is mutually exclusive with register, deregister and sync")
+			return 1
+		}
+		return c.reap(cli, printer, sid, name)
+	}
+
+	if len(sid) <= 0 && len(name) <= 0 {
+		c.UI.Error("sg_register: you need to specify either -sg-id or -name")
+		return 1
+	}
+
+	if sync {
+		if register || deregister {
+			c.UI.Error("sg_register: sync is mutually exclusive with register and deregister")
+			return 1
+		}
+		return c.sync(cli, sid, name, rules)
+	}
+
+	if !register && !deregister {
+		c.UI.Error("sg_register: you need to specify either -register, -deregister, -sync or -reap")
+		return 1
+	}
+
+	if register && deregister {
+		c.UI.Error("sg_register: register and deregister are mutually exclusive")
+		return 1
+	}
+
+	if ttl < 0 || ttl > 0 && !register {
+		c.UI.Error("sg_register: -ttl needs a positive duration and -register")
+		return 1
+	}
+
+	set := make(map[string]bool)
+	cmdFlags.Visit(func(f *flag.Flag) { set[f.Name] = true })
+	switch strings.ToLower(protocol) {
+	case "tcp", "udp", "6", "17":
+		if toPort == -1 {
+			toPort = fromPort
+		}
+	default:
+		if !set["from-port"] {
+			fromPort = -1
+		}
+	}
+	if ip == "" && groups == "" {
+		ip = "0.0.0.0/0"
+	}
+	cidrs := splitList(ip)
+
+	c.debugf("[DEBUG]: using ip address(s): %s\n", ip)
+	// source groups are looked up later, any id stands in for them
+	peers := cidrs
+	if len(peers) == 0 {
+		peers = []string{"sg-0"}
+	}
+	for _, peer := range peers {
+		if _, err := sgregister.NewPermission(egress, protocol, fromPort, toPort, peer, ""); err != nil {
+			c.UI.Error(fmt.Sprintf("sg_register: %s", err))
+			return 1
+		}
+	}
+
+	c.debugf("[DEBUG]: using region: %s\n", cli.GetRegion())
+	c.debugf("[DEBUG]: using profile: %s\n", cli.GetProfile())
+
+	c.debugf("[DEBUG]: creating new session...\n")
+	svc := ec2.New(cli.Session(), cli.ServiceConfig(ec2.ServiceName))
+	rule := sgregister.Rule{GroupID: sid, GroupName: name, VpcID: c.vpcID, GroupTags: c.tags, Egress: egress, CidrIPs: cidrs, Groups: splitList(groups), IPProtocol: protocol, FromPort: fromPort, ToPort: toPort}
+	if ttl > 0 {
+		expires := time.Now().Add(ttl).Truncate(time.Second)
+		rule.Expires = &expires
+	}
+
+	var result *sgregister.Result
+	if register {
+		c.debugf("[DEBUG]: registering...\n")
+		result, err = sgregister.Register(context.Background(), svc, rule, c.dryrun)
+	}
+
+	if deregister {
+		c.debugf("[DEBUG]: deregistering...\n")
+		result, err = sgregister.Deregister(context.Background(), svc, rule, c.dryrun)
+	}
+
+	if err != nil {
+		c.UI.Error(fmt.Sprintf("[ERROR]: failed while processing request: %s", err))
+		return 253
+	}
+
+	group := sid
+	if group == "" {
+		group = name
+	}
+	state := "already has"
+	if deregister {
+		state = "doesn't have"
+	}
+	if result.Unchanged && strict {
+		c.UI.Error(fmt.Sprintf("[ERROR]: failed while processing request: %s %s %s", group, state, rule))
+		return 253
+	}
+
+	if err := printer.Print(result); err != nil {
+		c.UI.Error(fmt.Sprintf("[ERROR]: %s", err))
+		return 252
+	}
+	if result.Unchanged {
+		c.UI.Info(fmt.Sprintf("%s %s %s, nothing to %s", group, state, rule, result.Action))
+		return SGUnchanged
+	}
+	return 0
+}
+
+// sync - converge the group's rules on the rules file
+func (c *SGCommand) sync(cli *awscli.AwsCli, sid, name, path string) int {
+	if path == "" {
+		c.UI.Error("sg_register: sync needs a -rules file")
+		return 1
+	}
+	rules, err := sgregister.LoadRules(path)
+	if err != nil {
+		c.UI.Error(fmt.Sprintf("sg_register: %s", err))
+		return 1
+	}
+
+	ctx := context.Background()
+	svc := ec2.New(cli.Session(), cli.ServiceConfig(ec2.ServiceName))
+	if sid == "" {
+		c.debugf("[DEBUG]: looking up %s...\n", name)
+		if sid, err = sgregister.LookupSGID(ctx, svc, name, sgregister.GroupFilters(c.vpcID, c.tags)); err != nil {
+			c.UI.Error(fmt.Sprintf("[ERROR]: failed to lookup sg '%s' by name: %s", name, err))
+			return 253
+		}
+	}
+
+	plan, err := sgregister.PlanSync(ctx, svc, sid, rules)
+	if err != nil {
+		c.UI.Error(fmt.Sprintf("[ERROR]: failed while processing request: %s", err))
+		return 253
+	}
+	if plan.Empty() {
+		c.UI.Info(fmt.Sprintf("%s is in sync with %s", plan.GroupID, path))
+		return 0
+	}
+	for _, line := range plan.Diff() {
+		c.UI.Output(line)
+	}
+	if c.dryrun {
+		c.UI.Info("dry run, nothing changed")
+		return 0
+	}
+
+	c.debugf("[DEBUG]: syncing %s...\n", plan.GroupID)
+	if err := sgregister.Apply(ctx, svc, plan); err != nil {
+		c.UI.Error(fmt.Sprintf("[ERROR]: failed while processing request: %s", err))
+		return 253
+	}
+	c.UI.Info(fmt.Sprintf("%s: %d authorized, %d updated, %d revoked", plan.GroupID, len(plan.Authorize), len(plan.Describe), len(plan.Revoke)))
+	return 0
+}
+
+// reap - revoke the expired rules of the group, or every group
+func (c *SGCommand) reap(cli *awscli.AwsCli, printer *output.Printer, sid, name string) int {
+	ctx := context.Background()
+	svc := ec2.New(cli.Session(), cli.ServiceConfig(ec2.ServiceName))
+
+	var ids []string
+	switch {
+	case sid != "":
+		ids = []string{sid}
+	case name != "":
+		c.debugf("[DEBUG]: looking up %s...\n", name)
+		id, err := sgregister.LookupSGID(ctx, svc, name, sgregister.GroupFilters(c.vpcID, c.tags))
+		if err != nil {
+			c.UI.Error(fmt.Sprintf("[ERROR]: failed to lookup sg '%s' by name: %s", name, err))
+			return 253
+		}
+		ids = []string{id}
+	}
+
+	c.debugf("[DEBUG]: reaping expired rules...\n")
+	reaped, err := sgregister.Reap(ctx, svc, ids, time.Now(), c.dryrun)
+	if perr := printer.Print(reaped); perr != nil {
+		c.UI.Error(fmt.Sprintf("[ERROR]: %s", perr))
+		return 252
+	}
+	if err != nil {
+		c.UI.Error(fmt.Sprintf("[ERROR]: failed while processing request: %s", err))
+		return 253
+	}
+	if c.dryrun && len(reaped) > 0 {
+		c.UI.Info("dry run, nothing revoked")
+	}
+	return 0
+}
+
+// Synopsis -
+func (c *SGCommand) Synopsis() string {
+	switch c.Mode {
+	case "register":
+		return "Authorize security group ingress"
+	case "deregister":
+		return "Revoke security group ingress"
+	case "sync":
+		return "Sync a security group's rules with a rules file"
+	case "reap":
+		return "Revoke security group rules whose -ttl has passed"
+	}
+	return "Authorize or revoke security group ingress (sg_register)"
+}
@@ -0,0 +1,213 @@
+package command
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/sqs"
+
+	"github.com/aidevops/awscli"
+	"github.com/aidevops/awscli/sqsutil"
+)
+
+// sqsUnit - the standalone tool this command replaces
+const sqsUnit = "sqs_util"
+
+// SQSCommand - send or receive sqs messages. Mode is set by the 'sqs send' and
+// 'sqs recv' subcommands, plain 'sqs' (and sqs_util) takes -send or -recv.
+type SQSCommand struct {
+	Meta
+	Mode string
+}
+
+// Help -
+func (c *SQSCommand) Help() string {
+	helpText := `
+Usage: awscli sqs send|recv [options]
+       sqs_util -send|-recv [options]
+
+  Send a message to, or receive messages from, an sqs queue.
+
+Options:
+
+  -account=id        AWS account # owning the queue, defaults to the
+                     instance's account.
+
+  -queue=name        Queue name.
+
+  -message=text      Message body to send.
+
+  -attributes=list   Message attributes to send, 'foo=bar,hello=world'.
+
+  -count=n           Number of messages to receive, 1 - 10.
+
+  -build=true        Build the queue url instead of looking it up.
+
+  -url=true          Print the queue url and exit.
+
+  -region=name       AWS region, defaults to $AWS_REGION, the profile's
+                     region, the instance's region, then us-east-1.
+
+  -profile=name      AWS shared credentials profile.
+
+  -output=json       Output format: json, text, table or yaml.
+
+  -query=expr        JMESPath expression applied to the response.
+
+  -verbose=true      Display additional information from
+                     behind the scenes.
+`
+	return strings.TrimSpace(helpText)
+}
+
+// Run -
+func (c *SQSCommand) Run(args []string) int {
+	var (
+		attributes string
+		build      bool
+		count      int64
+		queue      string
+		message    string
+		send       bool
+		recv       bool
+		url        bool
+		version    bool
+	)
+
+	cli := &awscli.AwsCli{}
+	printer := c.printer("json")
+	cmdFlags := flag.NewFlagSet("sqs", flag.ContinueOnError)
+	cmdFlags.Usage = func() { c.UI.Output(c.Help()) }
+
+	cli.SetFlags(cmdFlags)
+	printer.SetFlags(cmdFlags)
+	cmdFlags.StringVar(&cli.Account, "account", "", "AWS account #, defaults to the instance's account. E.g. -account='1234556790123'")
+	cmdFlags.StringVar(&attributes, "attributes", "", "-attributes 'foo=bar,bar=foo,hello=world'")
+	cmdFlags.BoolVar(&build, "build", false, "build the url instead of looking it up against aws (less permission required)")
+	cmdFlags.Int64Var(&count, "count", 1, "number of messages to retrieve from queue")
+	cmdFlags.StringVar(&message, "message", "", "-message 'hello world'")
+	cmdFlags.StringVar(&queue, "queue", "", "vault-registration, consul-registration, serviceN-registration...")
+	cmdFlags.StringVar(&cli.Region, "region", "", "AWS region, defaults to $AWS_REGION, the profile's region, the instance's region, then us-east-1. E.g. -region=us-east-1")
+	cmdFlags.BoolVar(&send, "send", c.Mode == "send", "send message")
+	cmdFlags.BoolVar(&recv, "recv", c.Mode == "recv", "receive messages")
+	cmdFlags.BoolVar(&c.verbose, "verbose", false, "be more verbose.....")
+	cmdFlags.BoolVar(&version, "version", false, "print version and exit")
+	cmdFlags.BoolVar(&url, "url", false, "lookup the url for -queue='...' and exit")
+
+	if err := cmdFlags.Parse(args); err != nil {
+		return 1
+	}
+
+	if version == true {
+		c.UI.Output(c.versionInfo(sqsUnit))
+		return 0
+	}
+
+	if !send && !recv {
+		c.UI.Error("sqs_util: you need to specify either -send or -recv")
+		return 1
+	}
+
+	if send && recv {
+		c.UI.Error("sqs_util: send and recv are mutually exclusive")
+		return 1
+	}
+
+	c.debugf("[DEBUG]: using count: %d\n", count)
+	if count < 1 || count > 10 {
+		c.UI.Error(fmt.Sprintf("sqs_util: invalid count valid values 1 - 10, received: %d", count))
+		return 255
+	}
+
+	account := cli.GetAccount()
+	cli.Account = account
+	c.debugf("[DEBUG]: using account: %s\n", account)
+	if account == "" || len(account) < 12 {
+		c.UI.Error(fmt.Sprintf("sqs_util: missing or invalid account length: -account='1234556790123', received: '%s'", account))
+		return 254
+	}
+
+	c.debugf("[DEBUG]: using queue name(s): %s\n", queue)
+	if queue == "" || len(queue) < 3 {
+		c.UI.Error(fmt.Sprintf("sqs_util: missing or invalid queue(s): -queue='some-fancy-queue..', received: '%s'", queue))
+		return 253
+	}
+
+	if err := printer.Validate(); err != nil {
+		c.UI.Error(fmt.Sprintf("sqs_util: %s", err))
+		return 1
+	}
+
+	c.debugf("[DEBUG]: using region: %s\n", cli.GetRegion())
+	c.debugf("[DEBUG]: using profile: %s\n", cli.GetProfile())
+
+	ctx := context.Background()
+	c.debugf("[DEBUG]: creating new session...\n")
+	svc := sqs.New(cli.Session(), cli.ServiceConfig(sqs.ServiceName))
+
+	var queueURL string
+	if build {
+		queueURL = sqsutil.BuildQueueURL(cli.Endpoint(sqs.ServiceName), cli.Account, cli.GetRegion(), queue)
+	} else {
+		var err error
+		if queueURL, err = sqsutil.QueueURL(ctx, svc, cli.Account, queue); err != nil {
+			c.UI.Error(fmt.Sprintf("[ERROR]: failed while processing request: [ERROR] lookup queue url for queue '%s': %s", queue, err))
+			return 253
+		}
+		c.debugf("[DEBUG]: found url: '%s' for queue '%s'\n", queueURL, queue)
+	}
+
+	var resp interface{}
+	var err error
+	switch {
+	case url:
+		resp = &sqs.GetQueueUrlOutput{QueueUrl: aws.String(queueURL)}
+	case send:
+		c.debugf("[DEBUG]: sending message '%s'...\n", message)
+		resp, err = sqsutil.Send(ctx, svc, queueURL, message, ToMap(attributes))
+	case recv:
+		c.debugf("[DEBUG]: receiving up to %d message(s)...\n", count)
+		var out *sqs.ReceiveMessageOutput
+		if out, err = sqsutil.Receive(ctx, svc, queueURL, count); err == nil {
+			c.debugReceived(out)
+			resp = out
+		}
+	}
+
+	if err != nil {
+		c.UI.Error(fmt.Sprintf("[ERROR]: failed while processing request: %s", err))
+		return 253
+	}
+
+	if err := printer.Print(resp); err != nil {
+		c.UI.Error(fmt.Sprintf("[ERROR]: failed while processing request: %s", err))
+		return 253
+	}
+	return 0
+}
+
+// Synopsis -
+func (c *SQSCommand) Synopsis() string {
+	switch c.Mode {
+	case "send":
+		return "Send a message to an sqs queue"
+	case "recv":
+		return "Receive messages from an sqs queue"
+	}
+	return "Send or receive sqs messages (sqs_util)"
+}
+
+// debugReceived - print the received messages' bodies and attributes with -verbose
+func (c *SQSCommand) debugReceived(resp *sqs.ReceiveMessageOutput) {
+	total := len(resp.Messages)
+	for pos, msg := range resp.Messages {
+		c.debugf("[DEBUG]: [%d of %d] body: %s\n", pos+1, total, aws.StringValue(msg.Body))
+		for k, v := range msg.MessageAttributes {
+			c.debugf("[DEBUG]: %s=%s\n", k, aws.StringValue(v.StringValue))
+		}
+	}
+	c.debugf("[DEBUG]: Successfully received %d message(s)\n", total)
+}
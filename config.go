@@ -0,0 +1,56 @@
+// Package awscli -
+package awscli
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/go-ini/ini"
+)
+
+// ConfigFilename - return the shared config file path: $AWS_CONFIG_FILE or ~/.aws/config
+func ConfigFilename() string {
+	if filename := os.Getenv("AWS_CONFIG_FILE"); filename != "" {
+		return filename
+	}
+
+	homeDir := os.Getenv("HOME") // *nix
+	if homeDir == "" {           // Windows
+		homeDir = os.Getenv("USERPROFILE")
+	}
+	if homeDir == "" {
+		return ""
+	}
+	return filepath.Join(homeDir, ".aws", "config")
+}
+
+// ProfileValue - lookup key in the profile's section of the shared config file,
+// returns an empty string when the file, profile or key is missing.
+//
+// The config file names sections '[default]' and '[profile name]'.
+func ProfileValue(profile, key string) string {
+	filename := ConfigFilename()
+	if filename == "" {
+		return ""
+	}
+
+	config, err := ini.Load(filename)
+	if err != nil {
+		return ""
+	}
+
+	names := []string{"profile " + profile}
+	if profile == "default" {
+		names = append(names, "default")
+	}
+	for _, name := range names {
+		section, err := config.GetSection(name)
+		if err != nil {
+			continue
+		}
+		if section.HasKey(key) {
+			return section.Key(key).String()
+		}
+	}
+	return ""
+}
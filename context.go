@@ -0,0 +1,42 @@
+// Package awscli -
+package awscli
+
+import (
+	"context"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+)
+
+// ErrCodeCanceled - aws error code of requests stopped by their context
+const ErrCodeCanceled = "RequestCanceled"
+
+// Send - send req, stopping when ctx is done: every attempt's http request carries
+// ctx, retries and the delay between them are cut short, the error returned for a
+// canceled request has the ErrCodeCanceled code
+func Send(ctx context.Context, req *request.Request) error {
+	if ctx == nil {
+		return req.Send()
+	}
+
+	canceled := func(r *request.Request) {
+		if err := ctx.Err(); err != nil {
+			r.Error = awserr.New(ErrCodeCanceled, "request context canceled", err)
+			r.Retryable = aws.Bool(false)
+		}
+	}
+	req.Handlers.Validate.PushBack(canceled)
+	req.Handlers.Send.PushFront(func(r *request.Request) {
+		r.HTTPRequest = r.HTTPRequest.WithContext(ctx)
+	})
+	req.Handlers.Retry.PushBack(canceled)
+	req.Config.SleepDelay = func(delay time.Duration) {
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+		}
+	}
+	return req.Send()
+}
@@ -0,0 +1,55 @@
+package awscli_test
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/ec2"
+
+	"github.com/aidevops/awscli"
+	"github.com/aidevops/awscli/fakeaws"
+)
+
+var _ = Describe("Send", func() {
+
+	var (
+		srv *fakeaws.Server
+		svc *ec2.EC2
+		in  *ec2.CreateTagsInput
+	)
+
+	BeforeEach(func() {
+		srv = fakeaws.New()
+		cli := srv.AwsCli()
+		svc = ec2.New(cli.Session(), cli.ServiceConfig(ec2.ServiceName))
+		in = &ec2.CreateTagsInput{
+			Resources: []*string{aws.String("i-1")},
+			Tags:      []*ec2.Tag{{Key: aws.String("Name"), Value: aws.String("web")}},
+		}
+	})
+
+	AfterEach(func() {
+		srv.Close()
+	})
+
+	It("Sends the request", func() {
+		req, _ := svc.CreateTagsRequest(in)
+		Expect(awscli.Send(context.Background(), req)).To(Succeed())
+		Expect(srv.Tags("i-1")).To(HaveKeyWithValue("Name", "web"))
+	})
+
+	It("Stops requests whose context is done", func() {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		req, _ := svc.CreateTagsRequest(in)
+		err := awscli.Send(ctx, req)
+		Expect(err).To(HaveOccurred())
+		Expect(err.(awserr.Error).Code()).To(Equal(awscli.ErrCodeCanceled))
+		Expect(srv.CallCount("CreateTags")).To(Equal(0))
+	})
+})
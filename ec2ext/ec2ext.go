@@ -0,0 +1,155 @@
+// Package ec2ext - ec2 security group shapes and operations the vendored sdk
+// predates: ipv6 ranges, rule descriptions and updating them.
+//
+// The sdk marshals ec2 query requests and unmarshals their responses from the
+// struct tags alone, so these shapes go through an *ec2.EC2's own handlers,
+// signing and retries included. They mirror the newer sdk's, field for field,
+// and go with its APIVersion.
+package ec2ext
+
+import (
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/private/protocol"
+	"github.com/aws/aws-sdk-go/private/protocol/ec2query"
+	"github.com/aws/aws-sdk-go/service/ec2"
+)
+
+// APIVersion - the ec2 api version of the requests, the first with ipv6 ranges,
+// rule descriptions and their updates. The vendored sdk's predates them, ec2
+// would drop the fields and not know the updates.
+const APIVersion = "2016-11-15"
+
+// The security group rule operations RulesRequest takes
+const (
+	AuthorizeIngress          = "AuthorizeSecurityGroupIngress"
+	AuthorizeEgress           = "AuthorizeSecurityGroupEgress"
+	RevokeIngress             = "RevokeSecurityGroupIngress"
+	RevokeEgress              = "RevokeSecurityGroupEgress"
+	UpdateIngressDescriptions = "UpdateSecurityGroupRuleDescriptionsIngress"
+	UpdateEgressDescriptions  = "UpdateSecurityGroupRuleDescriptionsEgress"
+)
+
+// Client - what the operations need of an *ec2.EC2
+type Client interface {
+	NewRequest(operation *request.Operation, params interface{}, data interface{}) *request.Request
+}
+
+// IpPermission - ec2.IpPermission with Ipv6Ranges and rule descriptions
+type IpPermission struct {
+	_ struct{} `type:"structure"`
+
+	FromPort         *int64             `locationName:"fromPort" type:"integer"`
+	IpProtocol       *string            `locationName:"ipProtocol" type:"string"`
+	IpRanges         []*IpRange         `locationName:"ipRanges" locationNameList:"item" type:"list"`
+	Ipv6Ranges       []*Ipv6Range       `locationName:"ipv6Ranges" locationNameList:"item" type:"list"`
+	PrefixListIds    []*PrefixListId    `locationName:"prefixListIds" locationNameList:"item" type:"list"`
+	ToPort           *int64             `locationName:"toPort" type:"integer"`
+	UserIdGroupPairs []*UserIdGroupPair `locationName:"groups" locationNameList:"item" type:"list"`
+}
+
+// IpRange - ec2.IpRange with a Description
+type IpRange struct {
+	_ struct{} `type:"structure"`
+
+	CidrIp      *string `locationName:"cidrIp" type:"string"`
+	Description *string `locationName:"description" type:"string"`
+}
+
+// Ipv6Range -
+type Ipv6Range struct {
+	_ struct{} `type:"structure"`
+
+	CidrIpv6    *string `locationName:"cidrIpv6" type:"string"`
+	Description *string `locationName:"description" type:"string"`
+}
+
+// PrefixListId - ec2.PrefixListId with a Description
+type PrefixListId struct {
+	_ struct{} `type:"structure"`
+
+	Description  *string `locationName:"description" type:"string"`
+	PrefixListId *string `locationName:"prefixListId" type:"string"`
+}
+
+// UserIdGroupPair - ec2.UserIdGroupPair with a Description
+type UserIdGroupPair struct {
+	_ struct{} `type:"structure"`
+
+	Description            *string `locationName:"description" type:"string"`
+	GroupId                *string `locationName:"groupId" type:"string"`
+	GroupName              *string `locationName:"groupName" type:"string"`
+	PeeringStatus          *string `locationName:"peeringStatus" type:"string"`
+	UserId                 *string `locationName:"userId" type:"string"`
+	VpcId                  *string `locationName:"vpcId" type:"string"`
+	VpcPeeringConnectionId *string `locationName:"vpcPeeringConnectionId" type:"string"`
+}
+
+// SecurityGroup - ec2.SecurityGroup with the rules above
+type SecurityGroup struct {
+	_ struct{} `type:"structure"`
+
+	Description         *string         `locationName:"groupDescription" type:"string"`
+	GroupId             *string         `locationName:"groupId" type:"string"`
+	GroupName           *string         `locationName:"groupName" type:"string"`
+	IpPermissions       []*IpPermission `locationName:"ipPermissions" locationNameList:"item" type:"list"`
+	IpPermissionsEgress []*IpPermission `locationName:"ipPermissionsEgress" locationNameList:"item" type:"list"`
+	OwnerId             *string         `locationName:"ownerId" type:"string"`
+	Tags                []*ec2.Tag      `locationName:"tagSet" locationNameList:"item" type:"list"`
+	VpcId               *string         `locationName:"vpcId" type:"string"`
+}
+
+// DescribeSecurityGroupsOutput -
+type DescribeSecurityGroupsOutput struct {
+	_ struct{} `type:"structure"`
+
+	SecurityGroups []*SecurityGroup `locationName:"securityGroupInfo" locationNameList:"item" type:"list"`
+}
+
+// RulesInput - the input of every rule operation. Egress and the description
+// updates only take GroupId and IpPermissions, the flat CidrIp to
+// SourceSecurityGroupOwnerId fields are ingress' older form of one permission.
+type RulesInput struct {
+	_ struct{} `type:"structure"`
+
+	CidrIp                     *string         `type:"string"`
+	DryRun                     *bool           `locationName:"dryRun" type:"boolean"`
+	FromPort                   *int64          `type:"integer"`
+	GroupId                    *string         `type:"string"`
+	GroupName                  *string         `type:"string"`
+	IpPermissions              []*IpPermission `locationNameList:"item" type:"list"`
+	IpProtocol                 *string         `type:"string"`
+	SourceSecurityGroupName    *string         `type:"string"`
+	SourceSecurityGroupOwnerId *string         `type:"string"`
+	ToPort                     *int64          `type:"integer"`
+}
+
+// RulesOutput - the rule operations return nothing worth reading
+type RulesOutput struct {
+	_ struct{} `type:"structure"`
+}
+
+// DescribeSecurityGroupsRequest - ec2.DescribeSecurityGroupsRequest returning
+// the rules with their ipv6 ranges and descriptions
+func DescribeSecurityGroupsRequest(c Client, input *ec2.DescribeSecurityGroupsInput) (*request.Request, *DescribeSecurityGroupsOutput) {
+	if input == nil {
+		input = &ec2.DescribeSecurityGroupsInput{}
+	}
+	output := &DescribeSecurityGroupsOutput{}
+	op := &request.Operation{Name: "DescribeSecurityGroups", HTTPMethod: "POST", HTTPPath: "/"}
+	req := c.NewRequest(op, input, output)
+	req.ClientInfo.APIVersion = APIVersion
+	return req, output
+}
+
+// RulesRequest - the named rule operation, one of the constants above
+func RulesRequest(c Client, operation string, input *RulesInput) *request.Request {
+	if input == nil {
+		input = &RulesInput{}
+	}
+	op := &request.Operation{Name: operation, HTTPMethod: "POST", HTTPPath: "/"}
+	req := c.NewRequest(op, input, &RulesOutput{})
+	req.ClientInfo.APIVersion = APIVersion
+	req.Handlers.Unmarshal.Remove(ec2query.UnmarshalHandler)
+	req.Handlers.Unmarshal.PushBackNamed(protocol.UnmarshalDiscardBodyHandler)
+	return req
+}
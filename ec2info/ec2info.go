@@ -0,0 +1,194 @@
+// Package ec2info - describe ec2 instances across regions
+package ec2info
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/ec2/ec2iface"
+
+	"github.com/aidevops/awscli"
+)
+
+// Columns - the -columns instances are printed with by default
+const Columns = "ID=InstanceId,Name=Name,State=State,Type=InstanceType,PrivateIP=PrivateIpAddress," +
+	"PublicIP=PublicIpAddress,AZ=AvailabilityZone,Launched=LaunchTime"
+
+// Instance - the summary of an instance the inventory prints
+type Instance struct {
+	InstanceID       string `json:"InstanceId"`
+	Name             string `json:",omitempty"`
+	State            string
+	InstanceType     string
+	PrivateIPAddress string `json:"PrivateIpAddress,omitempty"`
+	PublicIPAddress  string `json:"PublicIpAddress,omitempty"`
+	AvailabilityZone string
+	LaunchTime       time.Time
+	Region           string
+	Tags             map[string]string `json:",omitempty"`
+}
+
+// RegionError - the regions that could not be described, by region
+type RegionError map[string]error
+
+// Error -
+func (e RegionError) Error() string {
+	var regions []string
+	for region := range e {
+		regions = append(regions, region)
+	}
+	sort.Strings(regions)
+
+	var msgs []string
+	for _, region := range regions {
+		msgs = append(msgs, fmt.Sprintf("%s: %s", region, e[region]))
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// Describe - the instances in describes, every page of them
+func Describe(ctx context.Context, svc ec2iface.EC2API, in *ec2.DescribeInstancesInput) ([]*ec2.Instance, error) {
+	reservations, err := Reservations(ctx, svc, in)
+	if err != nil {
+		return nil, err
+	}
+	var instances []*ec2.Instance
+	for _, reservation := range reservations {
+		instances = append(instances, reservation.Instances...)
+	}
+	return instances, nil
+}
+
+// Reservations - the reservations in describes, every page of them
+func Reservations(ctx context.Context, svc ec2iface.EC2API, in *ec2.DescribeInstancesInput) ([]*ec2.Reservation, error) {
+	reservations := []*ec2.Reservation{}
+	input := *in
+	for {
+		req, out := svc.DescribeInstancesRequest(&input)
+		if err := awscli.Send(ctx, req); err != nil {
+			return nil, fmt.Errorf("Could not describe instances: %s", err)
+		}
+		reservations = append(reservations, out.Reservations...)
+		if aws.StringValue(out.NextToken) == "" {
+			return reservations, nil
+		}
+		input.NextToken = out.NextToken
+	}
+}
+
+// Regions - the names of the regions enabled for the account
+func Regions(ctx context.Context, svc ec2iface.EC2API) ([]string, error) {
+	req, out := svc.DescribeRegionsRequest(&ec2.DescribeRegionsInput{})
+	if err := awscli.Send(ctx, req); err != nil {
+		return nil, fmt.Errorf("Could not describe regions: %s", err)
+	}
+	var regions []string
+	for _, region := range out.Regions {
+		regions = append(regions, aws.StringValue(region.RegionName))
+	}
+	sort.Strings(regions)
+	return regions, nil
+}
+
+// Summarize - the summary of an instance described in region
+func Summarize(instance *ec2.Instance, region string) Instance {
+	summary := Instance{
+		InstanceID:       aws.StringValue(instance.InstanceId),
+		InstanceType:     aws.StringValue(instance.InstanceType),
+		PrivateIPAddress: aws.StringValue(instance.PrivateIpAddress),
+		PublicIPAddress:  aws.StringValue(instance.PublicIpAddress),
+		LaunchTime:       aws.TimeValue(instance.LaunchTime),
+		Region:           region,
+	}
+	if instance.State != nil {
+		summary.State = aws.StringValue(instance.State.Name)
+	}
+	if instance.Placement != nil {
+		summary.AvailabilityZone = aws.StringValue(instance.Placement.AvailabilityZone)
+	}
+	if len(instance.Tags) > 0 {
+		summary.Tags = make(map[string]string, len(instance.Tags))
+		for _, tag := range instance.Tags {
+			summary.Tags[aws.StringValue(tag.Key)] = aws.StringValue(tag.Value)
+		}
+		summary.Name = summary.Tags["Name"]
+	}
+	return summary
+}
+
+// List - the instances matching filters in every region of clients, described
+// concurrently and sorted by region, then launch time and id. The instances of
+// the regions that could be described are returned along with a RegionError
+// for the others.
+func List(ctx context.Context, clients map[string]ec2iface.EC2API, filters []*ec2.Filter) ([]Instance, error) {
+	var (
+		mu        sync.Mutex
+		wg        sync.WaitGroup
+		instances []Instance
+		failed    = make(RegionError)
+	)
+	for region, svc := range clients {
+		wg.Add(1)
+		go func(region string, svc ec2iface.EC2API) {
+			defer wg.Done()
+			described, err := Describe(ctx, svc, &ec2.DescribeInstancesInput{Filters: filters})
+
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				failed[region] = err
+				return
+			}
+			for _, instance := range described {
+				instances = append(instances, Summarize(instance, region))
+			}
+		}(region, svc)
+	}
+	wg.Wait()
+
+	sort.Sort(byRegion(instances))
+	if len(failed) > 0 {
+		return instances, failed
+	}
+	return instances, nil
+}
+
+// TagFilters - ec2 filters from 'key=value' pairs, a bare key matches any value
+func TagFilters(tags map[string]string) []*ec2.Filter {
+	var keys []string
+	for key := range tags {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var filters []*ec2.Filter
+	for _, key := range keys {
+		if tags[key] == "" {
+			filters = append(filters, &ec2.Filter{Name: aws.String("tag-key"), Values: aws.StringSlice([]string{key})})
+			continue
+		}
+		filters = append(filters, &ec2.Filter{Name: aws.String("tag:" + key), Values: aws.StringSlice(strings.Split(tags[key], "|"))})
+	}
+	return filters
+}
+
+// byRegion -
+type byRegion []Instance
+
+func (a byRegion) Len() int      { return len(a) }
+func (a byRegion) Swap(i, j int) { a[i], a[j] = a[j], a[i] }
+func (a byRegion) Less(i, j int) bool {
+	switch {
+	case a[i].Region != a[j].Region:
+		return a[i].Region < a[j].Region
+	case !a[i].LaunchTime.Equal(a[j].LaunchTime):
+		return a[i].LaunchTime.Before(a[j].LaunchTime)
+	}
+	return a[i].InstanceID < a[j].InstanceID
+}
@@ -0,0 +1,106 @@
+package ec2info_test
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/ec2/ec2iface"
+
+	"github.com/aidevops/awscli/ec2info"
+	"github.com/aidevops/awscli/fakeaws"
+)
+
+var _ = Describe("EC2Info", func() {
+
+	var (
+		srv *fakeaws.Server
+		svc *ec2.EC2
+		ctx = context.Background()
+		web string
+		db  string
+	)
+
+	BeforeEach(func() {
+		srv = fakeaws.New()
+		srv.PageSize = 1
+		cli := srv.AwsCli()
+		svc = ec2.New(cli.Session(), cli.ServiceConfig(ec2.ServiceName))
+
+		web = srv.AddInstance(&ec2.Instance{
+			PrivateIpAddress: aws.String("10.0.0.10"),
+			PublicIpAddress:  aws.String("54.0.0.10"),
+			LaunchTime:       aws.Time(time.Date(2016, 7, 1, 0, 0, 0, 0, time.UTC)),
+			Tags:             []*ec2.Tag{{Key: aws.String("Name"), Value: aws.String("web-1")}, {Key: aws.String("role"), Value: aws.String("web")}},
+		})
+		db = srv.AddInstance(&ec2.Instance{
+			State: &ec2.InstanceState{Code: aws.Int64(80), Name: aws.String(ec2.InstanceStateNameStopped)},
+			Tags:  []*ec2.Tag{{Key: aws.String("role"), Value: aws.String("db")}},
+		})
+	})
+
+	AfterEach(func() {
+		srv.Close()
+	})
+
+	It("Describes every page and summarizes instances", func() {
+		instances, err := ec2info.Describe(ctx, svc, &ec2.DescribeInstancesInput{})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(instances).To(HaveLen(2))
+
+		summary := ec2info.Summarize(instances[0], "us-east-1")
+		Expect(summary).To(Equal(ec2info.Instance{
+			InstanceID:       web,
+			Name:             "web-1",
+			State:            "running",
+			InstanceType:     "t2.micro",
+			PrivateIPAddress: "10.0.0.10",
+			PublicIPAddress:  "54.0.0.10",
+			AvailabilityZone: "us-east-1a",
+			LaunchTime:       time.Date(2016, 7, 1, 0, 0, 0, 0, time.UTC),
+			Region:           "us-east-1",
+			Tags:             map[string]string{"Name": "web-1", "role": "web"},
+		}))
+	})
+
+	It("Lists regions concurrently, sorted by region and launch time", func() {
+		clients := map[string]ec2iface.EC2API{"us-west-2": svc, "us-east-1": svc}
+		instances, err := ec2info.List(ctx, clients, nil)
+		Expect(err).NotTo(HaveOccurred())
+		var rows []string
+		for _, instance := range instances {
+			rows = append(rows, instance.Region+" "+instance.InstanceID)
+		}
+		Expect(rows).To(Equal([]string{"us-east-1 " + db, "us-east-1 " + web, "us-west-2 " + db, "us-west-2 " + web}))
+	})
+
+	It("Filters by tags, a bare key matching any value", func() {
+		instances, err := ec2info.Describe(ctx, svc, &ec2.DescribeInstancesInput{Filters: ec2info.TagFilters(map[string]string{"role": "db|api"})})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(instances).To(HaveLen(1))
+		Expect(aws.StringValue(instances[0].InstanceId)).To(Equal(db))
+
+		instances, err = ec2info.Describe(ctx, svc, &ec2.DescribeInstancesInput{Filters: ec2info.TagFilters(map[string]string{"Name": ""})})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(instances).To(HaveLen(1))
+	})
+
+	It("Returns the regions that could be listed alongside the failures", func() {
+		srv.Fail("DescribeInstances", "UnauthorizedOperation", "You are not authorized to perform this operation.")
+		clients := map[string]ec2iface.EC2API{"us-east-1": svc}
+		instances, err := ec2info.List(ctx, clients, nil)
+		Expect(err).To(MatchError(ContainSubstring("us-east-1: Could not describe instances: UnauthorizedOperation")))
+		Expect(instances).To(BeEmpty())
+	})
+
+	It("Lists the account's regions", func() {
+		srv.Regions = []string{"us-west-2", "eu-west-1"}
+		regions, err := ec2info.Regions(ctx, svc)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(regions).To(Equal([]string{"eu-west-1", "us-west-2"}))
+	})
+})
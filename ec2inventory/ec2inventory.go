@@ -0,0 +1,201 @@
+// Package ec2inventory - render ec2 instances as an ansible inventory, ssh
+// config or hosts file
+package ec2inventory
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/aidevops/awscli/ec2info"
+)
+
+// Formats - the formats Render takes
+var Formats = []string{"ansible", "ssh-config", "hosts"}
+
+// Addresses - the Options.Address values: the public ip falling back to the
+// private one, or only either
+var Addresses = []string{"auto", "private", "public"}
+
+// Options - how hosts are named, reached and grouped
+type Options struct {
+	// GroupBy - tag keys the ansible groups are made of, tag_<key>_<value>
+	GroupBy []string
+	// Address - auto, private or public
+	Address string
+	// User - the ssh user, none when empty
+	User string
+	// Bastion - host the hosts reached on their private ip jump through
+	Bastion string
+}
+
+// Host - an instance as inventories name and reach it
+type Host struct {
+	Alias    string
+	Address  string
+	Private  bool
+	Instance ec2info.Instance
+}
+
+// Characters ssh aliases and ansible group and variable names can't take
+var (
+	aliasUnsafe    = regexp.MustCompile(`[^A-Za-z0-9_.-]+`)
+	variableUnsafe = regexp.MustCompile(`[^A-Za-z0-9_]`)
+)
+
+// Hosts - the instances that can be reached at opts.Address, aliased by their
+// Name tag, or id when they have none. Aliases several instances share get
+// the id appended.
+func Hosts(instances []ec2info.Instance, opts Options) []Host {
+	var hosts []Host
+	count := make(map[string]int)
+	for _, instance := range instances {
+		host := Host{Instance: instance, Alias: strings.Trim(aliasUnsafe.ReplaceAllString(instance.Name, "-"), "-")}
+		if host.Alias == "" {
+			host.Alias = instance.InstanceID
+		}
+		switch {
+		case opts.Address != "private" && instance.PublicIPAddress != "":
+			host.Address = instance.PublicIPAddress
+		case opts.Address != "public" && instance.PrivateIPAddress != "":
+			host.Address, host.Private = instance.PrivateIPAddress, true
+		default:
+			continue
+		}
+		count[host.Alias]++
+		hosts = append(hosts, host)
+	}
+	for i := range hosts {
+		if count[hosts[i].Alias] > 1 {
+			hosts[i].Alias += "-" + hosts[i].Instance.InstanceID
+		}
+	}
+	sort.Sort(byAlias(hosts))
+	return hosts
+}
+
+// Render - hosts in format, one of Formats
+func Render(format string, hosts []Host, opts Options) ([]byte, error) {
+	switch format {
+	case "ansible":
+		return Ansible(hosts, opts)
+	case "ssh-config":
+		return SSHConfig(hosts, opts), nil
+	case "hosts":
+		return HostsFile(hosts), nil
+	}
+	return nil, fmt.Errorf("unknown format '%s', expected one of: %s", format, strings.Join(Formats, ", "))
+}
+
+// Ansible - a dynamic inventory: the group all, a tag_<key>_<value> group for
+// every opts.GroupBy tag, and ec2_ and ec2_tag_ host vars
+func Ansible(hosts []Host, opts Options) ([]byte, error) {
+	groups := map[string][]string{"all": {}}
+	hostvars := make(map[string]map[string]string, len(hosts))
+	for _, host := range hosts {
+		groups["all"] = append(groups["all"], host.Alias)
+		for _, key := range opts.GroupBy {
+			if value, ok := host.Instance.Tags[key]; ok {
+				group := variable("tag_" + key + "_" + value)
+				groups[group] = append(groups[group], host.Alias)
+			}
+		}
+
+		instance := host.Instance
+		vars := map[string]string{
+			"ansible_host":           host.Address,
+			"ec2_id":                 instance.InstanceID,
+			"ec2_state":              instance.State,
+			"ec2_instance_type":      instance.InstanceType,
+			"ec2_placement":          instance.AvailabilityZone,
+			"ec2_region":             instance.Region,
+			"ec2_private_ip_address": instance.PrivateIPAddress,
+			"ec2_ip_address":         instance.PublicIPAddress,
+		}
+		for key, value := range instance.Tags {
+			vars[variable("ec2_tag_"+key)] = value
+		}
+		if opts.User != "" {
+			vars["ansible_user"] = opts.User
+		}
+		if jump(host, opts) {
+			vars["ansible_ssh_common_args"] = "-o ProxyJump=" + opts.Bastion
+		}
+		for key, value := range vars {
+			if value == "" {
+				delete(vars, key)
+			}
+		}
+		hostvars[host.Alias] = vars
+	}
+
+	inventory := map[string]interface{}{"_meta": map[string]interface{}{"hostvars": hostvars}}
+	for group, members := range groups {
+		inventory[group] = map[string][]string{"hosts": members}
+	}
+	b, err := json.MarshalIndent(inventory, "", "    ")
+	if err != nil {
+		return nil, err
+	}
+	return append(b, '\n'), nil
+}
+
+// SSHConfig - a Host entry for every host, jumping through opts.Bastion when
+// reached on the private ip
+func SSHConfig(hosts []Host, opts Options) []byte {
+	var b bytes.Buffer
+	for i, host := range hosts {
+		if i > 0 {
+			b.WriteString("\n")
+		}
+		fmt.Fprintf(&b, "# %s %s\n", host.Instance.InstanceID, host.Instance.Region)
+		fmt.Fprintf(&b, "Host %s\n", host.Alias)
+		fmt.Fprintf(&b, "    HostName %s\n", host.Address)
+		if opts.User != "" {
+			fmt.Fprintf(&b, "    User %s\n", opts.User)
+		}
+		if jump(host, opts) {
+			fmt.Fprintf(&b, "    ProxyJump %s\n", opts.Bastion)
+		}
+	}
+	return b.Bytes()
+}
+
+// HostsFile - /etc/hosts lines: the address, alias and id
+func HostsFile(hosts []Host) []byte {
+	var b bytes.Buffer
+	for _, host := range hosts {
+		fmt.Fprintf(&b, "%s\t%s %s\n", host.Address, host.Alias, host.Instance.InstanceID)
+	}
+	return b.Bytes()
+}
+
+// jump - whether host is reached through the bastion, never the bastion itself
+func jump(host Host, opts Options) bool {
+	if opts.Bastion == "" || !host.Private {
+		return false
+	}
+	bastion := opts.Bastion
+	if i := strings.LastIndex(bastion, "@"); i >= 0 {
+		bastion = bastion[i+1:]
+	}
+	if i := strings.LastIndex(bastion, ":"); i >= 0 {
+		bastion = bastion[:i]
+	}
+	return bastion != host.Alias && bastion != host.Address && bastion != host.Instance.InstanceID
+}
+
+// variable - name made a valid ansible group or variable name
+func variable(name string) string {
+	return variableUnsafe.ReplaceAllString(name, "_")
+}
+
+// byAlias -
+type byAlias []Host
+
+func (a byAlias) Len() int           { return len(a) }
+func (a byAlias) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
+func (a byAlias) Less(i, j int) bool { return a[i].Alias < a[j].Alias }
@@ -0,0 +1,20 @@
+package ec2inventory_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/onsi/ginkgo/reporters"
+
+	"os"
+	"testing"
+)
+
+func TestEc2inventory(t *testing.T) {
+	RegisterFailHandler(Fail)
+	if os.Getenv("TEAMCITY") == "true" {
+		RunSpecsWithCustomReporters(t, "EC2Inventory Test Suite", []Reporter{reporters.NewTeamCityReporter(os.Stdout)})
+	} else {
+		RunSpecs(t, "EC2Inventory Test Suite")
+	}
+}
@@ -0,0 +1,100 @@
+package ec2inventory_test
+
+import (
+	"encoding/json"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/aidevops/awscli/ec2info"
+	"github.com/aidevops/awscli/ec2inventory"
+)
+
+var _ = Describe("EC2Inventory", func() {
+
+	var (
+		instances []ec2info.Instance
+		opts      ec2inventory.Options
+	)
+
+	BeforeEach(func() {
+		instances = []ec2info.Instance{
+			{InstanceID: "i-00000001", Name: "bastion", PrivateIPAddress: "10.0.0.5", PublicIPAddress: "54.0.0.5", Region: "us-east-1",
+				Tags: map[string]string{"Name": "bastion", "role": "bastion"}},
+			{InstanceID: "i-00000002", Name: "web 1", PrivateIPAddress: "10.0.1.10", Region: "us-east-1",
+				Tags: map[string]string{"Name": "web 1", "role": "web", "consul-dc": "dc1"}},
+			{InstanceID: "i-00000003", Name: "db", PrivateIPAddress: "10.0.2.10", Region: "us-east-1"},
+			{InstanceID: "i-00000004", Name: "db", PrivateIPAddress: "10.0.2.11", Region: "us-east-1"},
+			{InstanceID: "i-00000005", Region: "us-east-1"},
+		}
+		opts = ec2inventory.Options{Address: "auto", User: "ec2-user", Bastion: "bastion", GroupBy: []string{"role", "consul-dc"}}
+	})
+
+	It("Names hosts after their Name tag, unique, skipping the unreachable", func() {
+		var aliases []string
+		for _, host := range ec2inventory.Hosts(instances, opts) {
+			aliases = append(aliases, host.Alias+" "+host.Address)
+		}
+		Expect(aliases).To(Equal([]string{"bastion 54.0.0.5", "db-i-00000003 10.0.2.10", "db-i-00000004 10.0.2.11", "web-1 10.0.1.10"}))
+
+		opts.Address = "public"
+		Expect(ec2inventory.Hosts(instances, opts)).To(HaveLen(1))
+	})
+
+	It("Writes ssh config jumping through the bastion to private hosts", func() {
+		out, err := ec2inventory.Render("ssh-config", ec2inventory.Hosts(instances[:2], opts), opts)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(out)).To(Equal(`# i-00000001 us-east-1
+Host bastion
+    HostName 54.0.0.5
+    User ec2-user
+
+# i-00000002 us-east-1
+Host web-1
+    HostName 10.0.1.10
+    User ec2-user
+    ProxyJump bastion
+`))
+	})
+
+	It("Writes an ansible inventory grouped by tags with host vars", func() {
+		out, err := ec2inventory.Render("ansible", ec2inventory.Hosts(instances, opts), opts)
+		Expect(err).NotTo(HaveOccurred())
+
+		var inventory struct {
+			Meta struct {
+				Hostvars map[string]map[string]string
+			} `json:"_meta"`
+			All      struct{ Hosts []string } `json:"all"`
+			Web      struct{ Hosts []string } `json:"tag_role_web"`
+			DC       struct{ Hosts []string } `json:"tag_consul_dc_dc1"`
+			Bastions struct{ Hosts []string } `json:"tag_role_bastion"`
+		}
+		Expect(json.Unmarshal(out, &inventory)).To(Succeed())
+		Expect(inventory.All.Hosts).To(HaveLen(4))
+		Expect(inventory.Web.Hosts).To(Equal([]string{"web-1"}))
+		Expect(inventory.DC.Hosts).To(Equal([]string{"web-1"}))
+		Expect(inventory.Bastions.Hosts).To(Equal([]string{"bastion"}))
+		Expect(inventory.Meta.Hostvars["web-1"]).To(Equal(map[string]string{
+			"ansible_host":            "10.0.1.10",
+			"ansible_user":            "ec2-user",
+			"ansible_ssh_common_args": "-o ProxyJump=bastion",
+			"ec2_id":                  "i-00000002",
+			"ec2_region":              "us-east-1",
+			"ec2_private_ip_address":  "10.0.1.10",
+			"ec2_tag_Name":            "web 1",
+			"ec2_tag_role":            "web",
+			"ec2_tag_consul_dc":       "dc1",
+		}))
+		Expect(inventory.Meta.Hostvars["bastion"]).NotTo(HaveKey("ansible_ssh_common_args"))
+	})
+
+	It("Writes hosts file lines and refuses unknown formats", func() {
+		out, err := ec2inventory.Render("hosts", ec2inventory.Hosts(instances[:2], opts), opts)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(out)).To(Equal("54.0.0.5\tbastion i-00000001\n10.0.1.10\tweb-1 i-00000002\n"))
+
+		_, err = ec2inventory.Render("csv", nil, opts)
+		Expect(err).To(MatchError(ContainSubstring("unknown format 'csv'")))
+	})
+})
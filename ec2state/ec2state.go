@@ -0,0 +1,152 @@
+// Package ec2state - start, stop, reboot and terminate ec2 instances
+package ec2state
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/ec2/ec2iface"
+
+	"github.com/aidevops/awscli"
+	"github.com/aidevops/awscli/ec2info"
+)
+
+// Actions - the actions by name and the ec2wait condition each one settles
+// in, reboot has none
+var Actions = map[string]string{
+	"start":     "instance-running",
+	"stop":      "instance-stopped",
+	"reboot":    "",
+	"terminate": "instance-terminated",
+}
+
+// Change - an instance's state before and after an action
+type Change struct {
+	InstanceID    string `json:"InstanceId"`
+	Name          string `json:",omitempty"`
+	PreviousState string
+	CurrentState  string
+}
+
+// Select - the instances ids, or those matching filters, sorted by id. Every
+// id must exist, terminated instances are only selected by id.
+func Select(ctx context.Context, svc ec2iface.EC2API, ids []string, filters []*ec2.Filter) ([]ec2info.Instance, error) {
+	if len(ids) > 0 {
+		filters = append(filters, &ec2.Filter{Name: aws.String("instance-id"), Values: aws.StringSlice(ids)})
+	}
+	described, err := ec2info.Describe(ctx, svc, &ec2.DescribeInstancesInput{Filters: filters})
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		instances []ec2info.Instance
+		found     = make(map[string]bool, len(described))
+	)
+	for _, instance := range described {
+		summary := ec2info.Summarize(instance, "")
+		found[summary.InstanceID] = true
+		if len(ids) == 0 && summary.State == ec2.InstanceStateNameTerminated {
+			continue
+		}
+		instances = append(instances, summary)
+	}
+
+	var missing []string
+	for _, id := range ids {
+		if !found[id] {
+			missing = append(missing, id)
+		}
+	}
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("no such instance(s): %s", strings.Join(missing, ", "))
+	}
+	sort.Sort(byInstanceID(instances))
+	return instances, nil
+}
+
+// Apply - run the named action on instances. With dryrun ec2 only checks the
+// call would be permitted and no changes are returned.
+func Apply(ctx context.Context, svc ec2iface.EC2API, action string, instances []ec2info.Instance, dryrun bool) ([]Change, error) {
+	if _, ok := Actions[action]; !ok {
+		return nil, fmt.Errorf("unknown action '%s', expected start, stop, reboot or terminate", action)
+	}
+	if len(instances) == 0 {
+		return nil, nil
+	}
+
+	ids := make([]*string, len(instances))
+	for i, instance := range instances {
+		ids[i] = aws.String(instance.InstanceID)
+	}
+
+	var (
+		req   *request.Request
+		start *ec2.StartInstancesOutput
+		stop  *ec2.StopInstancesOutput
+		term  *ec2.TerminateInstancesOutput
+	)
+	switch action {
+	case "start":
+		req, start = svc.StartInstancesRequest(&ec2.StartInstancesInput{InstanceIds: ids, DryRun: aws.Bool(dryrun)})
+	case "stop":
+		req, stop = svc.StopInstancesRequest(&ec2.StopInstancesInput{InstanceIds: ids, DryRun: aws.Bool(dryrun)})
+	case "reboot":
+		req, _ = svc.RebootInstancesRequest(&ec2.RebootInstancesInput{InstanceIds: ids, DryRun: aws.Bool(dryrun)})
+	case "terminate":
+		req, term = svc.TerminateInstancesRequest(&ec2.TerminateInstancesInput{InstanceIds: ids, DryRun: aws.Bool(dryrun)})
+	}
+	if err := awscli.Send(ctx, req); err != nil {
+		if aerr, ok := err.(awserr.Error); ok && dryrun && aerr.Code() == "DryRunOperation" {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("Could not %s instances: %s", action, err)
+	}
+
+	var transitions []*ec2.InstanceStateChange
+	switch {
+	case start != nil:
+		transitions = start.StartingInstances
+	case stop != nil:
+		transitions = stop.StoppingInstances
+	case term != nil:
+		transitions = term.TerminatingInstances
+	}
+	return changes(instances, transitions), nil
+}
+
+// changes - the state changes of instances ec2 reported, a reboot reports none
+// and leaves the state as it was
+func changes(instances []ec2info.Instance, transitions []*ec2.InstanceStateChange) []Change {
+	reported := make(map[string]*ec2.InstanceStateChange, len(transitions))
+	for _, transition := range transitions {
+		reported[aws.StringValue(transition.InstanceId)] = transition
+	}
+
+	changes := make([]Change, len(instances))
+	for i, instance := range instances {
+		changes[i] = Change{InstanceID: instance.InstanceID, Name: instance.Name, PreviousState: instance.State, CurrentState: instance.State}
+		if transition, ok := reported[instance.InstanceID]; ok {
+			if transition.PreviousState != nil {
+				changes[i].PreviousState = aws.StringValue(transition.PreviousState.Name)
+			}
+			if transition.CurrentState != nil {
+				changes[i].CurrentState = aws.StringValue(transition.CurrentState.Name)
+			}
+		}
+	}
+	return changes
+}
+
+// byInstanceID -
+type byInstanceID []ec2info.Instance
+
+func (a byInstanceID) Len() int           { return len(a) }
+func (a byInstanceID) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
+func (a byInstanceID) Less(i, j int) bool { return a[i].InstanceID < a[j].InstanceID }
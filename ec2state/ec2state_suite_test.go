@@ -0,0 +1,20 @@
+package ec2state_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/onsi/ginkgo/reporters"
+
+	"os"
+	"testing"
+)
+
+func TestEc2state(t *testing.T) {
+	RegisterFailHandler(Fail)
+	if os.Getenv("TEAMCITY") == "true" {
+		RunSpecsWithCustomReporters(t, "EC2State Test Suite", []Reporter{reporters.NewTeamCityReporter(os.Stdout)})
+	} else {
+		RunSpecs(t, "EC2State Test Suite")
+	}
+}
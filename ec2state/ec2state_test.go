@@ -0,0 +1,107 @@
+package ec2state_test
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+
+	"github.com/aidevops/awscli/ec2info"
+	"github.com/aidevops/awscli/ec2state"
+	"github.com/aidevops/awscli/fakeaws"
+)
+
+var _ = Describe("EC2State", func() {
+
+	var (
+		srv  *fakeaws.Server
+		svc  *ec2.EC2
+		ctx  = context.Background()
+		dev1 string
+		dev2 string
+		gone string
+	)
+
+	devTag := func(env string) []*ec2.Tag {
+		return []*ec2.Tag{{Key: aws.String("environment"), Value: aws.String(env)}}
+	}
+
+	BeforeEach(func() {
+		srv = fakeaws.New()
+		cli := srv.AwsCli()
+		svc = ec2.New(cli.Session(), cli.ServiceConfig(ec2.ServiceName))
+
+		dev1 = srv.AddInstance(&ec2.Instance{Tags: devTag("dev-a")})
+		dev2 = srv.AddInstance(&ec2.Instance{Tags: devTag("dev-b")})
+		srv.AddInstance(&ec2.Instance{Tags: devTag("prod")})
+		gone = srv.AddInstance(&ec2.Instance{Tags: devTag("dev-c")})
+		srv.SetInstanceState(gone, ec2.InstanceStateNameTerminated)
+	})
+
+	AfterEach(func() {
+		srv.Close()
+	})
+
+	It("Selects by filter, leaving out terminated instances", func() {
+		instances, err := ec2state.Select(ctx, svc, nil, ec2info.TagFilters(map[string]string{"environment": "dev-*"}))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(instances).To(HaveLen(2))
+		Expect(instances[0].InstanceID).To(Equal(dev1))
+		Expect(instances[1].InstanceID).To(Equal(dev2))
+	})
+
+	It("Selects by id, failing on the ones that don't exist", func() {
+		instances, err := ec2state.Select(ctx, svc, []string{gone}, nil)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(instances).To(HaveLen(1))
+
+		_, err = ec2state.Select(ctx, svc, []string{dev1, "i-00000000"}, nil)
+		Expect(err).To(MatchError("no such instance(s): i-00000000"))
+	})
+
+	It("Stops and starts the instances, returning the state changes", func() {
+		instances, err := ec2state.Select(ctx, svc, []string{dev1, dev2}, nil)
+		Expect(err).NotTo(HaveOccurred())
+
+		changes, err := ec2state.Apply(ctx, svc, "stop", instances, false)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(changes).To(Equal([]ec2state.Change{
+			{InstanceID: dev1, PreviousState: "running", CurrentState: "stopping"},
+			{InstanceID: dev2, PreviousState: "running", CurrentState: "stopping"},
+		}))
+		Expect(aws.StringValue(srv.Instance(dev1).State.Name)).To(Equal("stopped"))
+
+		instances, _ = ec2state.Select(ctx, svc, []string{dev1}, nil)
+		changes, err = ec2state.Apply(ctx, svc, "start", instances, false)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(changes[0].CurrentState).To(Equal("pending"))
+	})
+
+	It("Reboots without changing state", func() {
+		instances, _ := ec2state.Select(ctx, svc, []string{dev1}, nil)
+		changes, err := ec2state.Apply(ctx, svc, "reboot", instances, false)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(changes).To(Equal([]ec2state.Change{{InstanceID: dev1, PreviousState: "running", CurrentState: "running"}}))
+		Expect(srv.CallCount("RebootInstances")).To(Equal(1))
+	})
+
+	It("Changes nothing on a dry run", func() {
+		instances, _ := ec2state.Select(ctx, svc, []string{dev1}, nil)
+		changes, err := ec2state.Apply(ctx, svc, "terminate", instances, true)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(changes).To(BeNil())
+		Expect(aws.StringValue(srv.Instance(dev1).State.Name)).To(Equal("running"))
+	})
+
+	It("Returns the errors of ec2 and refuses unknown actions", func() {
+		instances, _ := ec2state.Select(ctx, svc, []string{gone}, nil)
+		_, err := ec2state.Apply(ctx, svc, "start", instances, false)
+		Expect(err).To(MatchError(ContainSubstring("Could not start instances: IncorrectInstanceState")))
+
+		_, err = ec2state.Apply(ctx, svc, "hibernate", instances, false)
+		Expect(err).To(MatchError(ContainSubstring("unknown action 'hibernate'")))
+	})
+})
@@ -0,0 +1,221 @@
+package ec2tag
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/ec2/ec2iface"
+
+	"github.com/aidevops/awscli"
+	"github.com/aidevops/awscli/ec2info"
+)
+
+// MaxResources - most resource ids a single CreateTags call accepts
+const MaxResources = 1000
+
+// Resource types Select resolves filters against
+const (
+	ResourceInstance = "instance"
+	ResourceVolume   = "volume"
+)
+
+// Options - how TagAll splits up its CreateTags calls
+type Options struct {
+	// ChunkSize - resources per call, MaxResources when zero
+	ChunkSize int
+	// Concurrency - calls in flight at once, 1 when zero
+	Concurrency int
+	// Retries - further attempts of a throttled call, on top of the sdk's own
+	Retries int
+	// Backoff - delay before the first retry, doubled for each one after
+	Backoff time.Duration
+}
+
+// DefaultOptions - 1000 resources per call, 4 calls at a time, 5 retries from 1s
+var DefaultOptions = Options{ChunkSize: MaxResources, Concurrency: 4, Retries: 5, Backoff: time.Second}
+
+// Outcome - how tagging one resource went
+type Outcome struct {
+	Resource string
+	OK       bool
+	Error    string `json:",omitempty"`
+}
+
+// BulkResult - the tags set and the outcome for every resource, in input order
+type BulkResult struct {
+	Tags      map[string]string
+	Resources []Outcome
+}
+
+// Failed - the resources that could not be tagged
+func (r *BulkResult) Failed() []Outcome {
+	var failed []Outcome
+	for _, outcome := range r.Resources {
+		if !outcome.OK {
+			failed = append(failed, outcome)
+		}
+	}
+	return failed
+}
+
+// Filters - ec2 filters from 'name=value' pairs, several values of one filter
+// separated by '|', e.g. {"tag:role": "web|db", "instance-state-name": "running"}
+func Filters(pairs map[string]string) []*ec2.Filter {
+	var filters []*ec2.Filter
+	for _, name := range sortedKeys(pairs) {
+		filters = append(filters, &ec2.Filter{
+			Name:   aws.String(name),
+			Values: aws.StringSlice(strings.Split(pairs[name], "|")),
+		})
+	}
+	return filters
+}
+
+// Select - the ids of the resources of resourceType (ResourceInstance or
+// ResourceVolume) matching filters, every page of them
+func Select(ctx context.Context, svc ec2iface.EC2API, resourceType string, filters []*ec2.Filter) ([]string, error) {
+	var ids []string
+	switch resourceType {
+	case ResourceInstance:
+		instances, err := ec2info.Describe(ctx, svc, &ec2.DescribeInstancesInput{Filters: filters})
+		if err != nil {
+			return nil, err
+		}
+		for _, instance := range instances {
+			ids = append(ids, aws.StringValue(instance.InstanceId))
+		}
+		return ids, nil
+	case ResourceVolume:
+		input := &ec2.DescribeVolumesInput{Filters: filters}
+		for {
+			req, out := svc.DescribeVolumesRequest(input)
+			if err := awscli.Send(ctx, req); err != nil {
+				return nil, fmt.Errorf("Could not describe volumes: %s", err)
+			}
+			for _, volume := range out.Volumes {
+				ids = append(ids, aws.StringValue(volume.VolumeId))
+			}
+			if aws.StringValue(out.NextToken) == "" {
+				return ids, nil
+			}
+			input.NextToken = out.NextToken
+		}
+	}
+	return nil, fmt.Errorf("unsupported resource type '%s', valid types %s and %s", resourceType, ResourceInstance, ResourceVolume)
+}
+
+// TagAll - create or overwrite tags on any number of resources, chunked and run
+// concurrently as opts says. Throttled calls are retried with backoff; a chunk
+// rejected for one of its ids is split until the offending ids are found, so the
+// others still get tagged. Only a canceled ctx is returned as an error, every
+// other failure is reported per resource.
+func TagAll(ctx context.Context, svc ec2iface.EC2API, resources []string, tags map[string]string, opts Options) (*BulkResult, error) {
+	if len(resources) == 0 {
+		return nil, fmt.Errorf("no resources to tag")
+	}
+	if opts.ChunkSize <= 0 || opts.ChunkSize > MaxResources {
+		opts.ChunkSize = MaxResources
+	}
+	if opts.Concurrency <= 0 {
+		opts.Concurrency = 1
+	}
+
+	var chunks [][]string
+	for start := 0; start < len(resources); start += opts.ChunkSize {
+		end := start + opts.ChunkSize
+		if end > len(resources) {
+			end = len(resources)
+		}
+		chunks = append(chunks, resources[start:end])
+	}
+
+	ec2Tags := Tags(tags)
+	outcomes := make([][]Outcome, len(chunks))
+	work := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < opts.Concurrency; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for pos := range work {
+				outcomes[pos] = tagChunk(ctx, svc, chunks[pos], ec2Tags, opts)
+			}
+		}()
+	}
+	for pos := range chunks {
+		work <- pos
+	}
+	close(work)
+	wg.Wait()
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	result := &BulkResult{Tags: tags}
+	for _, chunk := range outcomes {
+		result.Resources = append(result.Resources, chunk...)
+	}
+	return result, nil
+}
+
+// tagChunk - tag one chunk, splitting it when an id in it is rejected
+func tagChunk(ctx context.Context, svc ec2iface.EC2API, chunk []string, tags []*ec2.Tag, opts Options) []Outcome {
+	err := createTags(ctx, svc, chunk, tags, opts)
+	if err != nil && len(chunk) > 1 && invalidID(err) {
+		half := len(chunk) / 2
+		return append(tagChunk(ctx, svc, chunk[:half], tags, opts), tagChunk(ctx, svc, chunk[half:], tags, opts)...)
+	}
+
+	outcomes := make([]Outcome, len(chunk))
+	for pos, resource := range chunk {
+		outcomes[pos] = Outcome{Resource: resource, OK: err == nil}
+		if err != nil {
+			outcomes[pos].Error = err.Error()
+		}
+	}
+	return outcomes
+}
+
+// createTags - one CreateTags call, retried with backoff while throttled
+func createTags(ctx context.Context, svc ec2iface.EC2API, resources []string, tags []*ec2.Tag, opts Options) error {
+	delay := opts.Backoff
+	for attempt := 0; ; attempt++ {
+		req, _ := svc.CreateTagsRequest(&ec2.CreateTagsInput{Resources: aws.StringSlice(resources), Tags: tags})
+		err := awscli.Send(ctx, req)
+		if err == nil || !throttled(err) || attempt >= opts.Retries {
+			return err
+		}
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		delay *= 2
+	}
+}
+
+// throttled - true for the errors ec2 answers with when calls come too fast
+func throttled(err error) bool {
+	if aerr, ok := err.(awserr.Error); ok {
+		switch aerr.Code() {
+		case "RequestLimitExceeded", "Throttling", "ThrottlingException":
+			return true
+		}
+	}
+	return false
+}
+
+// invalidID - true for the errors naming a resource id the call can't take
+func invalidID(err error) bool {
+	if aerr, ok := err.(awserr.Error); ok {
+		code := aerr.Code()
+		return strings.HasSuffix(code, ".NotFound") || strings.HasSuffix(code, ".Malformed") || code == "InvalidID"
+	}
+	return false
+}
@@ -0,0 +1,50 @@
+// Package ec2tag - tag ec2 resources
+package ec2tag
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/ec2/ec2iface"
+
+	"github.com/aidevops/awscli"
+)
+
+// Result - the resources and the tags set on them
+type Result struct {
+	Resources []string
+	Tags      map[string]string
+}
+
+// Tags - tags as ec2.Tag, sorted by key
+func Tags(tags map[string]string) []*ec2.Tag {
+	keys := sortedKeys(tags)
+	ec2Tags := make([]*ec2.Tag, len(keys))
+	for pos, key := range keys {
+		ec2Tags[pos] = &ec2.Tag{
+			Key:   aws.String(key),
+			Value: aws.String(tags[key]),
+		}
+	}
+	return ec2Tags
+}
+
+// Tag - create or overwrite tags on ec2 resources
+func Tag(ctx context.Context, svc ec2iface.EC2API, resources []string, tags map[string]string) (*Result, error) {
+	if len(resources) == 0 {
+		return nil, fmt.Errorf("no resources to tag")
+	}
+
+	req, _ := svc.CreateTagsRequest(&ec2.CreateTagsInput{
+		Resources: aws.StringSlice(resources),
+		Tags:      Tags(tags),
+	})
+	if err := awscli.Send(ctx, req); err != nil {
+		return nil, fmt.Errorf("Could not create tags for instance(s): '%s': %s", strings.Join(resources, " "), err)
+	}
+
+	return &Result{Resources: resources, Tags: tags}, nil
+}
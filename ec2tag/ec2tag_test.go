@@ -0,0 +1,482 @@
+package ec2tag_test
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/ec2metadata"
+	"github.com/aws/aws-sdk-go/service/ec2"
+
+	"github.com/aidevops/awscli/ec2tag"
+	"github.com/aidevops/awscli/fakeaws"
+)
+
+var _ = Describe("Tag", func() {
+
+	var (
+		srv *fakeaws.Server
+		svc *ec2.EC2
+	)
+
+	BeforeEach(func() {
+		srv = fakeaws.New()
+		cli := srv.AwsCli()
+		svc = ec2.New(cli.Session(), cli.ServiceConfig(ec2.ServiceName))
+	})
+
+	AfterEach(func() {
+		srv.Close()
+	})
+
+	It("Tags every resource in one call", func() {
+		result, err := ec2tag.Tag(context.Background(), svc, []string{"i-1", "vol-1"}, map[string]string{"Name": "web", "env": "prod"})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result.Resources).To(Equal([]string{"i-1", "vol-1"}))
+		Expect(srv.Tags("i-1")).To(Equal(map[string]string{"Name": "web", "env": "prod"}))
+		Expect(srv.Tags("vol-1")).To(Equal(map[string]string{"Name": "web", "env": "prod"}))
+		Expect(srv.CallCount("CreateTags")).To(Equal(1))
+	})
+
+	It("Returns the api error", func() {
+		srv.Fail("CreateTags", "InvalidInstanceID.NotFound", "The instance ID 'i-1' does not exist")
+		_, err := ec2tag.Tag(context.Background(), svc, []string{"i-1"}, map[string]string{"Name": "web"})
+		Expect(err).To(MatchError(ContainSubstring("InvalidInstanceID.NotFound")))
+	})
+
+	It("Sorts tags by key", func() {
+		tags := ec2tag.Tags(map[string]string{"b": "2", "a": "1"})
+		Expect(aws.StringValue(tags[0].Key)).To(Equal("a"))
+		Expect(aws.StringValue(tags[1].Value)).To(Equal("2"))
+	})
+})
+
+var _ = Describe("Sync", func() {
+
+	var (
+		srv *fakeaws.Server
+		svc *ec2.EC2
+		ctx = context.Background()
+	)
+
+	BeforeEach(func() {
+		srv = fakeaws.New()
+		cli := srv.AwsCli()
+		svc = ec2.New(cli.Session(), cli.ServiceConfig(ec2.ServiceName))
+	})
+
+	AfterEach(func() {
+		srv.Close()
+	})
+
+	It("Plans adds, updates and removes, leaving protected keys alone", func() {
+		srv.SetTags("i-1", map[string]string{"Name": "web-1", "aws:cloudformation:stack-name": "web", "role": "db", "consul_dc": "east", "env": "prod"})
+		plan, err := ec2tag.Sync(ctx, svc, []string{"i-1"}, map[string]string{"role": "web", "env": "prod", "team": "ops"}, ec2tag.DefaultProtected)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(plan.Changes).To(Equal([]ec2tag.Change{
+			{Resource: "i-1", Action: ec2tag.ActionUpdate, Key: "role", Old: "db", New: "web"},
+			{Resource: "i-1", Action: ec2tag.ActionAdd, Key: "team", New: "ops"},
+			{Resource: "i-1", Action: ec2tag.ActionRemove, Key: "consul_dc", Old: "east"},
+		}))
+
+		Expect(ec2tag.Apply(ctx, svc, plan)).To(Succeed())
+		Expect(srv.Tags("i-1")).To(Equal(map[string]string{"Name": "web-1", "aws:cloudformation:stack-name": "web", "role": "web", "env": "prod", "team": "ops"}))
+	})
+
+	It("Plans nothing for resources already in sync", func() {
+		srv.SetTags("i-1", map[string]string{"role": "web"})
+		plan, err := ec2tag.Sync(ctx, svc, []string{"i-1"}, map[string]string{"role": "web"}, nil)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(plan.Empty()).To(BeTrue())
+		Expect(ec2tag.Apply(ctx, svc, plan)).To(Succeed())
+		Expect(srv.CallCount("CreateTags") + srv.CallCount("DeleteTags")).To(Equal(0))
+	})
+
+	It("Reads every page of tags", func() {
+		srv.PageSize = 2
+		srv.SetTags("i-1", map[string]string{"a": "1", "b": "2", "c": "3"})
+		srv.SetTags("i-2", map[string]string{"a": "1"})
+		current, err := ec2tag.Current(ctx, svc, []string{"i-1", "i-2"})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(current["i-1"]).To(HaveLen(3))
+		Expect(current["i-2"]).To(HaveLen(1))
+		Expect(srv.CallCount("DescribeTags")).To(Equal(2))
+	})
+
+	It("Matches protected key patterns", func() {
+		Expect(ec2tag.Protected("aws:autoscaling:groupName", ec2tag.DefaultProtected)).To(BeTrue())
+		Expect(ec2tag.Protected("Name", ec2tag.DefaultProtected)).To(BeTrue())
+		Expect(ec2tag.Protected("name", ec2tag.DefaultProtected)).To(BeFalse())
+	})
+})
+
+var _ = Describe("Delete and Find", func() {
+
+	var (
+		srv *fakeaws.Server
+		svc *ec2.EC2
+		ctx = context.Background()
+	)
+
+	BeforeEach(func() {
+		srv = fakeaws.New()
+		cli := srv.AwsCli()
+		svc = ec2.New(cli.Session(), cli.ServiceConfig(ec2.ServiceName))
+		srv.SetTags("i-1", map[string]string{"role": "web", "env": "prod", "consul_dc": "east"})
+		srv.SetTags("i-2", map[string]string{"role": "db", "env": "prod", "consul_dc": "west"})
+		srv.SetTags("vol-1", map[string]string{"role": "web"})
+	})
+
+	AfterEach(func() {
+		srv.Close()
+	})
+
+	It("Deletes keys, and key=value pairs only where the value matches", func() {
+		_, err := ec2tag.Delete(ctx, svc, []string{"i-1", "i-2"}, map[string]string{"env": "", "consul_dc": "east"})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(srv.Tags("i-1")).To(Equal(map[string]string{"role": "web"}))
+		Expect(srv.Tags("i-2")).To(Equal(map[string]string{"role": "db", "consul_dc": "west"}))
+	})
+
+	It("Finds tags by resource type and exact key=value pairs across pages", func() {
+		srv.PageSize = 1
+		tags, err := ec2tag.Find(ctx, svc, &ec2tag.Query{
+			ResourceTypes: []string{"instance"},
+			Tags:          map[string]string{"role": "web", "consul_dc": "west"},
+		})
+		Expect(err).NotTo(HaveOccurred())
+		var found []string
+		for _, tag := range tags {
+			found = append(found, aws.StringValue(tag.ResourceId)+":"+aws.StringValue(tag.Key))
+		}
+		Expect(found).To(Equal([]string{"i-1:role", "i-2:consul_dc"}))
+		Expect(srv.CallCount("DescribeTags")).To(BeNumerically(">", 1))
+	})
+
+	It("Finds every value of a bare key", func() {
+		tags, err := ec2tag.Find(ctx, svc, &ec2tag.Query{Resources: []string{"i-2", "vol-1"}, Tags: map[string]string{"role": ""}})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(tags).To(HaveLen(2))
+	})
+})
+
+var _ = Describe("Bulk tagging", func() {
+
+	var (
+		srv  *fakeaws.Server
+		svc  *ec2.EC2
+		ctx  = context.Background()
+		opts = ec2tag.Options{ChunkSize: 2, Concurrency: 2, Retries: 2, Backoff: time.Millisecond}
+		web  []string
+	)
+
+	BeforeEach(func() {
+		srv = fakeaws.New()
+		srv.PageSize = 2
+		cli := srv.AwsCli()
+		// without the sdk's own retries, so only TagAll's are counted
+		svc = ec2.New(cli.Session(), cli.ServiceConfig(ec2.ServiceName).WithMaxRetries(0))
+
+		web = nil
+		for i := 0; i < 5; i++ {
+			web = append(web, srv.AddInstance(&ec2.Instance{Tags: ec2tag.Tags(map[string]string{"role": "web"})}))
+		}
+		srv.AddInstance(&ec2.Instance{Tags: ec2tag.Tags(map[string]string{"role": "db"})})
+		srv.AddInstance(&ec2.Instance{
+			State: &ec2.InstanceState{Code: aws.Int64(80), Name: aws.String(ec2.InstanceStateNameStopped)},
+			Tags:  ec2tag.Tags(map[string]string{"role": "web"}),
+		})
+		srv.AddVolume(&ec2.Volume{Tags: ec2tag.Tags(map[string]string{"role": "web"})})
+	})
+
+	AfterEach(func() {
+		srv.Close()
+	})
+
+	It("Selects instances and volumes by filter across pages", func() {
+		filters := ec2tag.Filters(map[string]string{"tag:role": "web", "instance-state-name": "running"})
+		ids, err := ec2tag.Select(ctx, svc, ec2tag.ResourceInstance, filters)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(ids).To(Equal(web))
+
+		ids, err = ec2tag.Select(ctx, svc, ec2tag.ResourceVolume, ec2tag.Filters(map[string]string{"tag:role": "web|db"}))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(ids).To(HaveLen(1))
+
+		_, err = ec2tag.Select(ctx, svc, "snapshot", nil)
+		Expect(err).To(MatchError(ContainSubstring("unsupported resource type")))
+	})
+
+	It("Tags in chunks, retrying throttled calls", func() {
+		srv.Fail("CreateTags", "RequestLimitExceeded", "Request limit exceeded.")
+		result, err := ec2tag.TagAll(ctx, svc, web, map[string]string{"env": "prod"}, opts)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result.Failed()).To(BeEmpty())
+		Expect(result.Resources).To(HaveLen(5))
+		for pos, id := range web {
+			Expect(result.Resources[pos].Resource).To(Equal(id))
+			Expect(srv.Tags(id)).To(HaveKeyWithValue("env", "prod"))
+		}
+		for _, call := range srv.Calls("CreateTags") {
+			Expect(len(call.Input.(*ec2.CreateTagsInput).Resources)).To(BeNumerically("<=", 2))
+		}
+	})
+
+	It("Isolates the resources a chunk was rejected for", func() {
+		resources := append([]string{"i-0badbad0"}, web...)
+		result, err := ec2tag.TagAll(ctx, svc, resources, map[string]string{"env": "prod"}, opts)
+		Expect(err).NotTo(HaveOccurred())
+		failed := result.Failed()
+		Expect(failed).To(HaveLen(1))
+		Expect(failed[0].Resource).To(Equal("i-0badbad0"))
+		Expect(failed[0].Error).To(ContainSubstring("InvalidInstanceID.NotFound"))
+		for _, id := range web {
+			Expect(srv.Tags(id)).To(HaveKeyWithValue("env", "prod"))
+		}
+	})
+
+	It("Gives up on calls still throttled after the retries", func() {
+		for i := 0; i < 3; i++ {
+			srv.Fail("CreateTags", "RequestLimitExceeded", "Request limit exceeded.")
+		}
+		result, err := ec2tag.TagAll(ctx, svc, web[:2], map[string]string{"env": "prod"}, opts)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result.Failed()).To(HaveLen(2))
+		Expect(srv.CallCount("CreateTags")).To(Equal(3))
+	})
+})
+
+var _ = Describe("Propagate", func() {
+
+	var (
+		srv      *fakeaws.Server
+		svc      *ec2.EC2
+		ctx      = context.Background()
+		instance string
+		root     string
+		data     string
+		snap     string
+	)
+
+	BeforeEach(func() {
+		srv = fakeaws.New()
+		cli := srv.AwsCli()
+		svc = ec2.New(cli.Session(), cli.ServiceConfig(ec2.ServiceName))
+
+		root = srv.AddVolume(&ec2.Volume{})
+		data = srv.AddVolume(&ec2.Volume{Tags: ec2tag.Tags(map[string]string{"backup": "daily", "role": "old"})})
+		snap = srv.AddSnapshot(&ec2.Snapshot{VolumeId: aws.String(data)})
+		srv.AddSnapshot(&ec2.Snapshot{VolumeId: aws.String("vol-0other00")})
+		instance = srv.AddInstance(&ec2.Instance{
+			BlockDeviceMappings: []*ec2.InstanceBlockDeviceMapping{
+				{DeviceName: aws.String("/dev/xvda"), Ebs: &ec2.EbsInstanceBlockDevice{VolumeId: aws.String(root)}},
+				{DeviceName: aws.String("/dev/xvdf"), Ebs: &ec2.EbsInstanceBlockDevice{VolumeId: aws.String(data)}},
+			},
+			NetworkInterfaces: []*ec2.InstanceNetworkInterface{{NetworkInterfaceId: aws.String("eni-0000000a")}},
+			Tags:              ec2tag.Tags(map[string]string{"environment": "prod", "role": "web", "Name": "web-1", "aws:autoscaling:groupName": "web"}),
+		})
+	})
+
+	AfterEach(func() {
+		srv.Close()
+	})
+
+	It("Plans the instance's tags onto its volumes, enis and snapshots, keeping theirs", func() {
+		plan, err := ec2tag.Propagate(ctx, svc, []string{instance}, []string{"volumes", "enis", "snapshots"}, []string{"environment", "role"})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(plan.Resources).To(Equal([]string{root, data, "eni-0000000a", snap}))
+		Expect(ec2tag.Apply(ctx, svc, plan)).To(Succeed())
+
+		for _, id := range plan.Resources {
+			Expect(srv.Tags(id)).To(HaveKeyWithValue("environment", "prod"))
+			Expect(srv.Tags(id)).To(HaveKeyWithValue("role", "web"))
+			Expect(srv.Tags(id)).NotTo(HaveKey("Name"))
+		}
+		Expect(srv.Tags(data)).To(HaveKeyWithValue("backup", "daily"))
+
+		plan, err = ec2tag.Propagate(ctx, svc, []string{instance}, []string{"volumes", "enis", "snapshots"}, []string{"environment", "role"})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(plan.Empty()).To(BeTrue())
+	})
+
+	It("Follows only the dependents asked for and never copies aws: keys", func() {
+		plan, err := ec2tag.Propagate(ctx, svc, []string{instance}, []string{"enis"}, ec2tag.DefaultPropagated)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(plan.Resources).To(Equal([]string{"eni-0000000a"}))
+		Expect(plan.Changes).To(HaveLen(3))
+
+		_, err = ec2tag.Propagate(ctx, svc, []string{instance}, []string{"amis"}, nil)
+		Expect(err).To(MatchError(ContainSubstring("can't propagate to 'amis'")))
+	})
+})
+
+var _ = Describe("Validation", func() {
+
+	violations := func(err error) []string {
+		Expect(err).To(BeAssignableToTypeOf(&ec2tag.ValidationError{}))
+		var list []string
+		for _, v := range err.(*ec2tag.ValidationError).Violations {
+			list = append(list, v.String())
+		}
+		return list
+	}
+
+	It("Checks keys and values against ec2's rules", func() {
+		Expect(ec2tag.Check(map[string]string{"build": "web-1.0,us-east-1", "owner": "ops@example.com", "empty": ""}, nil)).To(Succeed())
+
+		long := strings.Repeat("k", 129)
+		err := ec2tag.Check(map[string]string{
+			"":         "x",
+			"aws:cost": "1",
+			"AWS:cost": "1",
+			"bell\a":   "x",
+			"value":    "\x00",
+			"big":      strings.Repeat("v", 257),
+			long:       "x",
+		}, nil)
+		Expect(violations(err)).To(ConsistOf(
+			"tag '': keys can't be empty",
+			"tag 'AWS:cost': the aws: prefix is reserved for aws",
+			"tag 'aws:cost': the aws: prefix is reserved for aws",
+			"tag 'bell\a': key has control characters or isn't utf-8",
+			`tag 'value': value "\x00" has control characters or isn't utf-8`,
+			"tag 'big': value is 257 characters, at most 256",
+			"tag '"+long+"': key is 129 characters, at most 128",
+		))
+	})
+
+	It("Checks values and characters against a policy", func() {
+		policy, err := ec2tag.ParsePolicy([]byte("required: [environment, role]\nallowed:\n  environment: ^(dev|prod)$\nportable: true\n"))
+		Expect(err).NotTo(HaveOccurred())
+		err = ec2tag.Check(map[string]string{"environment": "qa", "build": "web,1"}, policy)
+		Expect(violations(err)).To(Equal([]string{
+			"tag 'build': value 'web,1' has characters other than letters, digits, spaces and + - = . _ : / @",
+			"tag 'environment': value 'qa' doesn't match the policy's ^(dev|prod)$",
+		}))
+
+		_, err = ec2tag.ParsePolicy([]byte("allowed:\n  role: '('\n"))
+		Expect(err).To(MatchError(ContainSubstring("allowed values of 'role'")))
+	})
+
+	It("Checks what each resource ends up with against the limit and required keys", func() {
+		policy, err := ec2tag.ParsePolicy([]byte(`{"required": ["environment", "role"]}`))
+		Expect(err).NotTo(HaveOccurred())
+
+		full := make(map[string]string)
+		for i := 0; i < ec2tag.MaxTagsPerResource; i++ {
+			full[fmt.Sprintf("key%d", i)] = "x"
+		}
+		full["environment"] = "prod"
+		current := map[string]map[string]string{"i-1": {"environment": "prod"}, "i-2": full}
+
+		err = ec2tag.CheckResources(ec2tag.Merged(current, map[string]string{"role": "web"}), []string{"i-1", "i-2"}, policy)
+		Expect(violations(err)).To(Equal([]string{"i-2: would have 52 tags, at most 50"}))
+
+		err = ec2tag.CheckResources(ec2tag.Synced(current, map[string]string{"role": "web"}, []string{"Name"}), []string{"i-1", "i-2"}, policy)
+		Expect(violations(err)).To(Equal([]string{"i-1: tag 'environment': required by the policy", "i-2: tag 'environment': required by the policy"}))
+
+		current["i-1"]["role"] = "web"
+		err = ec2tag.CheckResources(ec2tag.Deleted(current, map[string]string{"environment": "dev", "role": ""}), []string{"i-1"}, policy)
+		Expect(violations(err)).To(Equal([]string{"i-1: tag 'role': required by the policy"}))
+	})
+
+	It("Leaves aws: tags out of the limit", func() {
+		full := map[string]string{"aws:cloudformation:stack-name": "web", "aws:autoscaling:groupName": "web"}
+		for i := 0; i < ec2tag.MaxTagsPerResource; i++ {
+			full[fmt.Sprintf("key%d", i)] = "x"
+		}
+		Expect(ec2tag.CheckResources(map[string]map[string]string{"i-1": full}, []string{"i-1"}, nil)).To(Succeed())
+
+		full["key50"] = "x"
+		err := ec2tag.CheckResources(map[string]map[string]string{"i-1": full}, []string{"i-1"}, nil)
+		Expect(violations(err)).To(Equal([]string{"i-1: would have 51 tags, at most 50"}))
+	})
+})
+
+// stubMetadata - canned instance metadata for templates
+type stubMetadata struct {
+	calls int
+}
+
+func (m *stubMetadata) GetMetadata(path string) (string, error) {
+	m.calls++
+	if path == "local-hostname" {
+		return "ip-10-0-0-10.ec2.internal", nil
+	}
+	return "", fmt.Errorf("no metadata at %s", path)
+}
+
+func (m *stubMetadata) GetInstanceIdentityDocument() (ec2metadata.EC2InstanceIdentityDocument, error) {
+	m.calls++
+	return ec2metadata.EC2InstanceIdentityDocument{InstanceID: "i-1", AvailabilityZone: "us-east-1a", PrivateIP: "10.0.0.10"}, nil
+}
+
+var _ = Describe("Tag files", func() {
+
+	It("Reads json objects and ec2 style lists", func() {
+		Expect(ec2tag.ParseJSON([]byte(`{"role": "web", "size": 3, "url": "http://a,b=c"}`))).To(Equal(map[string]string{"role": "web", "size": "3", "url": "http://a,b=c"}))
+		Expect(ec2tag.ParseJSON([]byte(`[{"Key": "role", "Value": "web"}]`))).To(Equal(map[string]string{"role": "web"}))
+		_, err := ec2tag.ParseJSON([]byte(`{"role": {"nested": true}}`))
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("Reads yaml mappings", func() {
+		Expect(ec2tag.ParseYAML([]byte("role: web\nenabled: true\nempty:\n"))).To(Equal(map[string]string{"role": "web", "enabled": "true", "empty": ""}))
+	})
+
+	It("Reads .env files", func() {
+		tags, err := ec2tag.ParseEnv([]byte("# tags\nexport role=web\n\nconsul_url=\"http://consul:8500/?a=b,c\"\nquoted='x y'\n"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(tags).To(Equal(map[string]string{"role": "web", "consul_url": "http://consul:8500/?a=b,c", "quoted": "x y"}))
+
+		_, err = ec2tag.ParseEnv([]byte("role=web\nrole=db\n"))
+		Expect(err).To(MatchError(ContainSubstring("line 2: duplicate key 'role'")))
+	})
+
+	It("Picks the format by extension", func() {
+		dir, err := ioutil.TempDir("", "ec2tag")
+		Expect(err).NotTo(HaveOccurred())
+		defer os.RemoveAll(dir)
+		path := filepath.Join(dir, "tags.yml")
+		Expect(ioutil.WriteFile(path, []byte("role: web\n"), 0600)).To(Succeed())
+		Expect(ec2tag.ReadFile(path)).To(Equal(map[string]string{"role": "web"}))
+	})
+})
+
+var _ = Describe("Render", func() {
+
+	It("Renders env vars and instance metadata into values", func() {
+		os.Setenv("EC2TAG_TEST_VERSION", "1.2.3")
+		defer os.Unsetenv("EC2TAG_TEST_VERSION")
+		md := &stubMetadata{}
+		tags, err := ec2tag.Render(map[string]string{
+			"build": "web-{{.Env.EC2TAG_TEST_VERSION}}@{{.AvailabilityZone}}",
+			"host":  `{{.Metadata "local-hostname"}} {{.PrivateIP}} {{.InstanceID}}`,
+			"dc":    `{{env "EC2TAG_TEST_UNSET" | default "east"}}`,
+			"plain": "a=b,c",
+		}, ec2tag.NewTemplateData(md))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(tags).To(Equal(map[string]string{
+			"build": "web-1.2.3@us-east-1a",
+			"host":  "ip-10-0-0-10.ec2.internal 10.0.0.10 i-1",
+			"dc":    "east",
+			"plain": "a=b,c",
+		}))
+		Expect(md.calls).To(Equal(2))
+	})
+
+	It("Fails on missing env vars and metadata naming the tag", func() {
+		_, err := ec2tag.Render(map[string]string{"build": "{{.Env.EC2TAG_TEST_UNSET}}"}, ec2tag.NewTemplateData(nil))
+		Expect(err).To(MatchError(ContainSubstring("tag 'build'")))
+		_, err = ec2tag.Render(map[string]string{"id": "{{.InstanceID}}"}, ec2tag.NewTemplateData(nil))
+		Expect(err).To(MatchError(ContainSubstring("no instance metadata")))
+	})
+})
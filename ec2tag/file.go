@@ -0,0 +1,135 @@
+package ec2tag
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// ReadFile - read tags from a json, yaml or .env file, the format is picked by the
+// file's extension (.json, .yaml/.yml, anything else is read as .env)
+func ReadFile(path string) (map[string]string, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var tags map[string]string
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		tags, err = ParseJSON(b)
+	case ".yaml", ".yml":
+		tags, err = ParseYAML(b)
+	default:
+		tags, err = ParseEnv(b)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", path, err)
+	}
+	return tags, nil
+}
+
+// ParseJSON - tags from a json object, {"role": "web"}, or a list of ec2 style
+// tags, [{"Key": "role", "Value": "web"}]
+func ParseJSON(b []byte) (map[string]string, error) {
+	b = bytes.TrimSpace(b)
+	if len(b) > 0 && b[0] == '[' {
+		var list []struct {
+			Key   *string
+			Value string
+		}
+		if err := json.Unmarshal(b, &list); err != nil {
+			return nil, err
+		}
+		tags := make(map[string]string)
+		for pos, tag := range list {
+			if tag.Key == nil {
+				return nil, fmt.Errorf("tag %d has no Key", pos+1)
+			}
+			if _, ok := tags[*tag.Key]; ok {
+				return nil, fmt.Errorf("duplicate key '%s'", *tag.Key)
+			}
+			tags[*tag.Key] = tag.Value
+		}
+		return tags, nil
+	}
+
+	var raw map[string]interface{}
+	if err := json.Unmarshal(b, &raw); err != nil {
+		return nil, err
+	}
+	return scalars(raw)
+}
+
+// ParseYAML - tags from a yaml mapping of keys to scalar values
+func ParseYAML(b []byte) (map[string]string, error) {
+	var raw map[string]interface{}
+	if err := yaml.Unmarshal(b, &raw); err != nil {
+		return nil, err
+	}
+	return scalars(raw)
+}
+
+// ParseEnv - tags from KEY=value lines, blank lines and # comments are skipped, an
+// 'export ' prefix is dropped and quoted values are unquoted
+func ParseEnv(b []byte) (map[string]string, error) {
+	tags := make(map[string]string)
+	scanner := bufio.NewScanner(bytes.NewReader(b))
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.TrimPrefix(line, "export ")
+
+		pair := strings.SplitN(line, "=", 2)
+		if len(pair) != 2 {
+			return nil, fmt.Errorf("line %d: expected key=value, got '%s'", n, line)
+		}
+		key, value := strings.TrimSpace(pair[0]), strings.TrimSpace(pair[1])
+		if strings.HasPrefix(value, `"`) || strings.HasPrefix(value, "'") {
+			if len(value) < 2 || value[len(value)-1] != value[0] {
+				return nil, fmt.Errorf("line %d: unterminated quote", n)
+			}
+			if value[0] == '"' {
+				unquoted, err := strconv.Unquote(value)
+				if err != nil {
+					return nil, fmt.Errorf("line %d: %s", n, err)
+				}
+				value = unquoted
+			} else {
+				value = value[1 : len(value)-1]
+			}
+		}
+		if _, ok := tags[key]; ok {
+			return nil, fmt.Errorf("line %d: duplicate key '%s'", n, key)
+		}
+		tags[key] = value
+	}
+	return tags, scanner.Err()
+}
+
+// scalars - stringify the values of a decoded mapping, refusing nested ones
+func scalars(raw map[string]interface{}) (map[string]string, error) {
+	tags := make(map[string]string, len(raw))
+	for key, value := range raw {
+		switch v := value.(type) {
+		case nil:
+			tags[key] = ""
+		case string:
+			tags[key] = v
+		case bool, int, int64, uint64, float64:
+			tags[key] = fmt.Sprint(v)
+		default:
+			return nil, fmt.Errorf("value of '%s' isn't a string", key)
+		}
+	}
+	return tags, nil
+}
@@ -0,0 +1,101 @@
+package ec2tag
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/ec2/ec2iface"
+
+	"github.com/aidevops/awscli"
+)
+
+// Query - the tags Find looks for, empty fields match everything
+type Query struct {
+	Resources     []string
+	ResourceTypes []string
+	// Tags - keys and the value each must have, an empty value matches any value
+	Tags map[string]string
+}
+
+// Filters - DescribeTags filters narrowing the tags down to the query's. Several
+// key=value pairs can't be expressed exactly, Match finishes the job.
+func (q *Query) Filters() []*ec2.Filter {
+	var filters []*ec2.Filter
+	if len(q.Resources) > 0 {
+		filters = append(filters, &ec2.Filter{Name: aws.String("resource-id"), Values: aws.StringSlice(q.Resources)})
+	}
+	if len(q.ResourceTypes) > 0 {
+		filters = append(filters, &ec2.Filter{Name: aws.String("resource-type"), Values: aws.StringSlice(q.ResourceTypes)})
+	}
+	if len(q.Tags) > 0 {
+		keys := sortedKeys(q.Tags)
+		filters = append(filters, &ec2.Filter{Name: aws.String("key"), Values: aws.StringSlice(keys)})
+
+		var values []string
+		for _, key := range keys {
+			if q.Tags[key] == "" {
+				values = nil
+				break
+			}
+			values = append(values, q.Tags[key])
+		}
+		if len(values) > 0 {
+			filters = append(filters, &ec2.Filter{Name: aws.String("value"), Values: aws.StringSlice(values)})
+		}
+	}
+	return filters
+}
+
+// Match - true when tag's key, and value if one is asked for, are in the query
+func (q *Query) Match(tag *ec2.TagDescription) bool {
+	if len(q.Tags) == 0 {
+		return true
+	}
+	value, ok := q.Tags[aws.StringValue(tag.Key)]
+	return ok && (value == "" || value == aws.StringValue(tag.Value))
+}
+
+// Find - describe the tags matching q, every page of them
+func Find(ctx context.Context, svc ec2iface.EC2API, q *Query) ([]*ec2.TagDescription, error) {
+	tags, err := List(ctx, svc, q.Filters())
+	if err != nil {
+		return nil, err
+	}
+	found := []*ec2.TagDescription{}
+	for _, tag := range tags {
+		if q.Match(tag) {
+			found = append(found, tag)
+		}
+	}
+	return found, nil
+}
+
+// Delete - delete tags from ec2 resources. A key with an empty value is deleted
+// whatever its value, one with a value only where it still has that value.
+func Delete(ctx context.Context, svc ec2iface.EC2API, resources []string, tags map[string]string) (*Result, error) {
+	if len(resources) == 0 {
+		return nil, fmt.Errorf("no resources to delete tags from")
+	}
+	if len(tags) == 0 {
+		return nil, fmt.Errorf("no tags to delete")
+	}
+
+	ec2Tags := Tags(tags)
+	for _, tag := range ec2Tags {
+		if aws.StringValue(tag.Value) == "" {
+			tag.Value = nil
+		}
+	}
+	req, _ := svc.DeleteTagsRequest(&ec2.DeleteTagsInput{
+		Resources: aws.StringSlice(resources),
+		Tags:      ec2Tags,
+	})
+	if err := awscli.Send(ctx, req); err != nil {
+		return nil, fmt.Errorf("Could not delete tags from instance(s): '%s': %s", strings.Join(resources, " "), err)
+	}
+
+	return &Result{Resources: resources, Tags: tags}, nil
+}
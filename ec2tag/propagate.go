@@ -0,0 +1,147 @@
+package ec2tag
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/ec2/ec2iface"
+
+	"github.com/aidevops/awscli"
+	"github.com/aidevops/awscli/ec2info"
+)
+
+// Dependents Propagate follows from an instance
+const (
+	DependentVolumes   = "volumes"
+	DependentENIs      = "enis"
+	DependentSnapshots = "snapshots"
+)
+
+// DefaultPropagated - key patterns Propagate copies, every key; the reserved aws:
+// ones are never copied as they can't be written
+var DefaultPropagated = []string{"*"}
+
+// Propagate - plan copying the tags of instances matching keys (path.Match
+// patterns) to their dependents of kinds: the attached ebs volumes, the network
+// interfaces and the snapshots of those volumes. Only adds and updates are
+// planned, tags the dependents have of their own are left alone, so applying the
+// plan twice changes nothing.
+func Propagate(ctx context.Context, svc ec2iface.EC2API, instances []string, kinds []string, keys []string) (*Plan, error) {
+	follow := make(map[string]bool)
+	for _, kind := range kinds {
+		switch kind {
+		case DependentVolumes, DependentENIs, DependentSnapshots:
+			follow[kind] = true
+		default:
+			return nil, fmt.Errorf("can't propagate to '%s', valid dependents %s, %s and %s", kind, DependentVolumes, DependentENIs, DependentSnapshots)
+		}
+	}
+	if len(instances) == 0 {
+		return nil, fmt.Errorf("no instances to propagate from")
+	}
+
+	described, err := describeInstances(ctx, svc, instances)
+	if err != nil {
+		return nil, err
+	}
+
+	desired := make(map[string]map[string]string)
+	var dependents []string
+	for _, instance := range described {
+		tags := make(map[string]string)
+		for _, tag := range instance.Tags {
+			key := aws.StringValue(tag.Key)
+			if Protected(key, keys) && !strings.HasPrefix(key, "aws:") {
+				tags[key] = aws.StringValue(tag.Value)
+			}
+		}
+
+		var volumes, deps []string
+		for _, mapping := range instance.BlockDeviceMappings {
+			if mapping.Ebs != nil && mapping.Ebs.VolumeId != nil {
+				volumes = append(volumes, *mapping.Ebs.VolumeId)
+			}
+		}
+		if follow[DependentVolumes] {
+			deps = append(deps, volumes...)
+		}
+		if follow[DependentENIs] {
+			for _, eni := range instance.NetworkInterfaces {
+				deps = append(deps, aws.StringValue(eni.NetworkInterfaceId))
+			}
+		}
+		if follow[DependentSnapshots] && len(volumes) > 0 {
+			snapshots, err := snapshotsOf(ctx, svc, volumes)
+			if err != nil {
+				return nil, err
+			}
+			deps = append(deps, snapshots...)
+		}
+
+		for _, dep := range deps {
+			if desired[dep] == nil {
+				desired[dep] = make(map[string]string)
+				dependents = append(dependents, dep)
+			}
+			for key, value := range tags {
+				desired[dep][key] = value
+			}
+		}
+	}
+
+	current, err := Current(ctx, svc, dependents)
+	if err != nil {
+		return nil, err
+	}
+	plan := &Plan{Resources: dependents, Changes: []Change{}}
+	for _, dep := range dependents {
+		// every key protected, nothing of the dependent's own is removed
+		plan.Changes = append(plan.Changes, Diff(current, []string{dep}, desired[dep], []string{"*"}).Changes...)
+	}
+	return plan, nil
+}
+
+// describeInstances - the instances, every page of them, sorted by id
+func describeInstances(ctx context.Context, svc ec2iface.EC2API, ids []string) ([]*ec2.Instance, error) {
+	instances, err := ec2info.Describe(ctx, svc, &ec2.DescribeInstancesInput{InstanceIds: aws.StringSlice(ids)})
+	if err != nil {
+		return nil, err
+	}
+	sort.Sort(byInstanceID(instances))
+	return instances, nil
+}
+
+// snapshotsOf - the ids of the account's snapshots of volumes
+func snapshotsOf(ctx context.Context, svc ec2iface.EC2API, volumes []string) ([]string, error) {
+	var ids []string
+	input := &ec2.DescribeSnapshotsInput{
+		OwnerIds: aws.StringSlice([]string{"self"}),
+		Filters:  []*ec2.Filter{{Name: aws.String("volume-id"), Values: aws.StringSlice(volumes)}},
+	}
+	for {
+		req, out := svc.DescribeSnapshotsRequest(input)
+		if err := awscli.Send(ctx, req); err != nil {
+			return nil, fmt.Errorf("Could not describe snapshots: %s", err)
+		}
+		for _, snapshot := range out.Snapshots {
+			ids = append(ids, aws.StringValue(snapshot.SnapshotId))
+		}
+		if aws.StringValue(out.NextToken) == "" {
+			return ids, nil
+		}
+		input.NextToken = out.NextToken
+	}
+}
+
+// byInstanceID -
+type byInstanceID []*ec2.Instance
+
+func (a byInstanceID) Len() int      { return len(a) }
+func (a byInstanceID) Swap(i, j int) { a[i], a[j] = a[j], a[i] }
+func (a byInstanceID) Less(i, j int) bool {
+	return aws.StringValue(a[i].InstanceId) < aws.StringValue(a[j].InstanceId)
+}
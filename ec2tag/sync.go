@@ -0,0 +1,184 @@
+package ec2tag
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"sort"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/ec2/ec2iface"
+
+	"github.com/aidevops/awscli"
+)
+
+// Actions of a Change
+const (
+	ActionAdd    = "add"
+	ActionUpdate = "update"
+	ActionRemove = "remove"
+)
+
+// maxFilterValues - most values Current puts in one filter
+const maxFilterValues = 200
+
+// DefaultProtected - key patterns Diff never removes: the reserved aws: keys, which
+// can't be written anyway, and Name, usually owned by whoever launched the instance
+var DefaultProtected = []string{"aws:*", "Name"}
+
+// Change - one tag change on one resource
+type Change struct {
+	Resource string
+	Action   string
+	Key      string
+	Old      string `json:",omitempty"`
+	New      string `json:",omitempty"`
+}
+
+// Plan - the changes bringing resources' tags in line with the desired ones
+type Plan struct {
+	Resources []string
+	Changes   []Change
+}
+
+// Empty - true when nothing needs to change
+func (p *Plan) Empty() bool {
+	return len(p.Changes) == 0
+}
+
+// Protected - true when key matches one of the patterns
+func Protected(key string, patterns []string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, key); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// List - describe the tags matching filters, every page of them
+func List(ctx context.Context, svc ec2iface.EC2API, filters []*ec2.Filter) ([]*ec2.TagDescription, error) {
+	var tags []*ec2.TagDescription
+	input := &ec2.DescribeTagsInput{Filters: filters}
+	for {
+		req, out := svc.DescribeTagsRequest(input)
+		if err := awscli.Send(ctx, req); err != nil {
+			return nil, fmt.Errorf("Could not describe tags: %s", err)
+		}
+		tags = append(tags, out.Tags...)
+		if aws.StringValue(out.NextToken) == "" {
+			return tags, nil
+		}
+		input.NextToken = out.NextToken
+	}
+}
+
+// Current - the tags on each of resources, keyed by resource id, described
+// maxFilterValues resources at a time
+func Current(ctx context.Context, svc ec2iface.EC2API, resources []string) (map[string]map[string]string, error) {
+	current := make(map[string]map[string]string)
+	for _, resource := range resources {
+		current[resource] = make(map[string]string)
+	}
+	if len(resources) == 0 {
+		return current, nil
+	}
+
+	for start := 0; start < len(resources); start += maxFilterValues {
+		end := start + maxFilterValues
+		if end > len(resources) {
+			end = len(resources)
+		}
+		tags, err := List(ctx, svc, []*ec2.Filter{{Name: aws.String("resource-id"), Values: aws.StringSlice(resources[start:end])}})
+		if err != nil {
+			return nil, err
+		}
+		for _, tag := range tags {
+			if tags, ok := current[aws.StringValue(tag.ResourceId)]; ok {
+				tags[aws.StringValue(tag.Key)] = aws.StringValue(tag.Value)
+			}
+		}
+	}
+	return current, nil
+}
+
+// Diff - plan the changes making each resource's tags equal desired: missing keys
+// are added, differing values updated and other keys removed, unless they match
+// one of the protected patterns
+func Diff(current map[string]map[string]string, resources []string, desired map[string]string, protected []string) *Plan {
+	plan := &Plan{Resources: resources, Changes: []Change{}}
+	for _, resource := range resources {
+		tags := current[resource]
+		for _, key := range sortedKeys(desired) {
+			old, ok := tags[key]
+			switch {
+			case !ok:
+				plan.Changes = append(plan.Changes, Change{Resource: resource, Action: ActionAdd, Key: key, New: desired[key]})
+			case old != desired[key]:
+				plan.Changes = append(plan.Changes, Change{Resource: resource, Action: ActionUpdate, Key: key, Old: old, New: desired[key]})
+			}
+		}
+		for _, key := range sortedKeys(tags) {
+			if _, ok := desired[key]; ok || Protected(key, protected) {
+				continue
+			}
+			plan.Changes = append(plan.Changes, Change{Resource: resource, Action: ActionRemove, Key: key, Old: tags[key]})
+		}
+	}
+	return plan
+}
+
+// Apply - make the plan's changes, one CreateTags and one DeleteTags call per
+// resource at most. Removals name the value planned against so a tag changed
+// since is left alone.
+func Apply(ctx context.Context, svc ec2iface.EC2API, plan *Plan) error {
+	set := make(map[string][]*ec2.Tag)
+	remove := make(map[string][]*ec2.Tag)
+	for _, change := range plan.Changes {
+		switch change.Action {
+		case ActionAdd, ActionUpdate:
+			set[change.Resource] = append(set[change.Resource], &ec2.Tag{Key: aws.String(change.Key), Value: aws.String(change.New)})
+		case ActionRemove:
+			remove[change.Resource] = append(remove[change.Resource], &ec2.Tag{Key: aws.String(change.Key), Value: aws.String(change.Old)})
+		}
+	}
+
+	for _, resource := range plan.Resources {
+		if tags := set[resource]; len(tags) > 0 {
+			req, _ := svc.CreateTagsRequest(&ec2.CreateTagsInput{Resources: aws.StringSlice([]string{resource}), Tags: tags})
+			if err := awscli.Send(ctx, req); err != nil {
+				return fmt.Errorf("Could not create tags for '%s': %s", resource, err)
+			}
+		}
+		if tags := remove[resource]; len(tags) > 0 {
+			req, _ := svc.DeleteTagsRequest(&ec2.DeleteTagsInput{Resources: aws.StringSlice([]string{resource}), Tags: tags})
+			if err := awscli.Send(ctx, req); err != nil {
+				return fmt.Errorf("Could not delete tags from '%s': %s", resource, err)
+			}
+		}
+	}
+	return nil
+}
+
+// Sync - plan the changes making resources' tags equal desired, see Diff
+func Sync(ctx context.Context, svc ec2iface.EC2API, resources []string, desired map[string]string, protected []string) (*Plan, error) {
+	if len(resources) == 0 {
+		return nil, fmt.Errorf("no resources to sync")
+	}
+	current, err := Current(ctx, svc, resources)
+	if err != nil {
+		return nil, err
+	}
+	return Diff(current, resources, desired, protected), nil
+}
+
+// sortedKeys -
+func sortedKeys(tags map[string]string) []string {
+	keys := make([]string, 0, len(tags))
+	for key := range tags {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
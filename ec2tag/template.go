@@ -0,0 +1,134 @@
+package ec2tag
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"text/template"
+
+	"github.com/aws/aws-sdk-go/aws/ec2metadata"
+)
+
+// Metadata - the instance metadata templates read, an *ec2metadata.EC2Metadata
+type Metadata interface {
+	GetMetadata(path string) (string, error)
+	GetInstanceIdentityDocument() (ec2metadata.EC2InstanceIdentityDocument, error)
+}
+
+// TemplateData - what tag value templates see: .Env.NAME, the instance's identity
+// (.InstanceID, .AvailabilityZone, .Region, .AccountID, .PrivateIP, .InstanceType)
+// and any metadata path through .Metadata "local-hostname". Metadata is only asked
+// for when a template uses it.
+type TemplateData struct {
+	Env map[string]string
+
+	md   Metadata
+	once sync.Once
+	doc  ec2metadata.EC2InstanceIdentityDocument
+	err  error
+}
+
+// NewTemplateData - template data with the process' environment, md may be nil
+// when no metadata is to be had
+func NewTemplateData(md Metadata) *TemplateData {
+	env := make(map[string]string)
+	for _, kv := range os.Environ() {
+		pair := strings.SplitN(kv, "=", 2)
+		if len(pair) == 2 {
+			env[pair[0]] = pair[1]
+		}
+	}
+	return &TemplateData{Env: env, md: md}
+}
+
+// identity - the instance identity document, fetched once
+func (d *TemplateData) identity() (ec2metadata.EC2InstanceIdentityDocument, error) {
+	d.once.Do(func() {
+		if d.md == nil {
+			d.err = fmt.Errorf("no instance metadata available")
+			return
+		}
+		d.doc, d.err = d.md.GetInstanceIdentityDocument()
+	})
+	return d.doc, d.err
+}
+
+// InstanceID -
+func (d *TemplateData) InstanceID() (string, error) {
+	doc, err := d.identity()
+	return doc.InstanceID, err
+}
+
+// AvailabilityZone -
+func (d *TemplateData) AvailabilityZone() (string, error) {
+	doc, err := d.identity()
+	return doc.AvailabilityZone, err
+}
+
+// Region -
+func (d *TemplateData) Region() (string, error) {
+	doc, err := d.identity()
+	return doc.Region, err
+}
+
+// AccountID -
+func (d *TemplateData) AccountID() (string, error) {
+	doc, err := d.identity()
+	return doc.AccountID, err
+}
+
+// PrivateIP -
+func (d *TemplateData) PrivateIP() (string, error) {
+	doc, err := d.identity()
+	return doc.PrivateIP, err
+}
+
+// InstanceType -
+func (d *TemplateData) InstanceType() (string, error) {
+	doc, err := d.identity()
+	return doc.InstanceType, err
+}
+
+// Metadata - the meta-data at path, e.g. "local-hostname" or "tags/instance/Name"
+func (d *TemplateData) Metadata(path string) (string, error) {
+	if d.md == nil {
+		return "", fmt.Errorf("no instance metadata available")
+	}
+	return d.md.GetMetadata(path)
+}
+
+// Render - render every value of tags as a text/template against data. Values
+// without '{{' are kept as they are; a missing .Env entry is an error, use
+// '{{env "NAME"}}' for an optional one.
+func Render(tags map[string]string, data *TemplateData) (map[string]string, error) {
+	funcs := template.FuncMap{
+		"env": os.Getenv,
+		"default": func(def, value string) string {
+			if value == "" {
+				return def
+			}
+			return value
+		},
+	}
+
+	rendered := make(map[string]string, len(tags))
+	for _, key := range sortedKeys(tags) {
+		value := tags[key]
+		if !strings.Contains(value, "{{") {
+			rendered[key] = value
+			continue
+		}
+		tmpl, err := template.New(key).Funcs(funcs).Option("missingkey=error").Parse(value)
+		if err != nil {
+			return nil, fmt.Errorf("tag '%s': %s", key, err)
+		}
+		var buf bytes.Buffer
+		if err := tmpl.Execute(&buf, data); err != nil {
+			return nil, fmt.Errorf("tag '%s': %s", key, err)
+		}
+		rendered[key] = buf.String()
+	}
+	return rendered, nil
+}
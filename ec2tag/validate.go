@@ -0,0 +1,242 @@
+package ec2tag
+
+import (
+	"fmt"
+	"io/ioutil"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"gopkg.in/yaml.v2"
+)
+
+// Limits ec2 puts on tags
+const (
+	MaxTagsPerResource = 50
+	MaxKeyLength       = 128
+	MaxValueLength     = 256
+	ReservedPrefix     = "aws:"
+)
+
+// portableChars - what tags may hold to be accepted by every aws service: letters,
+// digits and spaces in any script, and + - = . _ : / @. ec2 itself takes anything
+// but control characters.
+var portableChars = regexp.MustCompile(`^[\pL\pZ\pN+\-=._:/@]*$`)
+
+// controlChars -
+var controlChars = regexp.MustCompile(`\pC`)
+
+// Violation - one tag rule broken, by a key or by a resource's tags as a whole
+type Violation struct {
+	Resource string `json:",omitempty"`
+	Key      string `json:",omitempty"`
+	Message  string
+}
+
+// String -
+func (v Violation) String() string {
+	switch {
+	case v.Resource != "" && v.Key != "":
+		return fmt.Sprintf("%s: tag '%s': %s", v.Resource, v.Key, v.Message)
+	case v.Resource != "":
+		return fmt.Sprintf("%s: %s", v.Resource, v.Message)
+	}
+	return fmt.Sprintf("tag '%s': %s", v.Key, v.Message)
+}
+
+// ValidationError - every violation found, one per line
+type ValidationError struct {
+	Violations []Violation
+}
+
+// Error -
+func (e *ValidationError) Error() string {
+	lines := []string{fmt.Sprintf("%d tag problem(s):", len(e.Violations))}
+	for _, v := range e.Violations {
+		lines = append(lines, "  "+v.String())
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Policy - an organisation's rules on top of ec2's: keys every resource must end
+// up with, the regular expressions values of a key must match and whether tags
+// must stick to the characters every aws service accepts, read from yaml or json:
+//
+//	required: [environment, role]
+//	allowed:
+//	  environment: ^(dev|staging|prod)$
+//	portable: true
+type Policy struct {
+	Required []string          `yaml:"required"`
+	Allowed  map[string]string `yaml:"allowed"`
+	Portable bool              `yaml:"portable"`
+
+	patterns map[string]*regexp.Regexp
+}
+
+// LoadPolicy - read a policy file, see Policy
+func LoadPolicy(path string) (*Policy, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	policy, err := ParsePolicy(b)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", path, err)
+	}
+	return policy, nil
+}
+
+// ParsePolicy - parse a yaml or json policy, compiling its patterns
+func ParsePolicy(b []byte) (*Policy, error) {
+	policy := &Policy{}
+	if err := yaml.Unmarshal(b, policy); err != nil {
+		return nil, err
+	}
+	policy.patterns = make(map[string]*regexp.Regexp, len(policy.Allowed))
+	for _, key := range sortedKeys(policy.Allowed) {
+		re, err := regexp.Compile(policy.Allowed[key])
+		if err != nil {
+			return nil, fmt.Errorf("allowed values of '%s': %s", key, err)
+		}
+		policy.patterns[key] = re
+	}
+	return policy, nil
+}
+
+// Check - the tags about to be set against ec2's key and value rules, and the
+// values and characters policy allows, policy may be nil. A *ValidationError lists every
+// violation.
+func Check(tags map[string]string, policy *Policy) error {
+	var violations []Violation
+	add := func(key, format string, args ...interface{}) {
+		violations = append(violations, Violation{Key: key, Message: fmt.Sprintf(format, args...)})
+	}
+
+	for _, key := range sortedKeys(tags) {
+		value := tags[key]
+		switch {
+		case strings.TrimSpace(key) == "":
+			add(key, "keys can't be empty")
+			continue
+		case utf8.RuneCountInString(key) > MaxKeyLength:
+			add(key, "key is %d characters, at most %d", utf8.RuneCountInString(key), MaxKeyLength)
+		case strings.HasPrefix(strings.ToLower(key), ReservedPrefix):
+			add(key, "the %s prefix is reserved for aws", ReservedPrefix)
+		case !utf8.ValidString(key) || controlChars.MatchString(key):
+			add(key, "key has control characters or isn't utf-8")
+		case policy.portable() && !portableChars.MatchString(key):
+			add(key, "key has characters other than letters, digits, spaces and + - = . _ : / @")
+		}
+		switch {
+		case utf8.RuneCountInString(value) > MaxValueLength:
+			add(key, "value is %d characters, at most %d", utf8.RuneCountInString(value), MaxValueLength)
+		case !utf8.ValidString(value) || controlChars.MatchString(value):
+			add(key, "value %q has control characters or isn't utf-8", value)
+		case policy.portable() && !portableChars.MatchString(value):
+			add(key, "value '%s' has characters other than letters, digits, spaces and + - = . _ : / @", value)
+		}
+		if re, ok := policy.pattern(key); ok && !re.MatchString(value) {
+			add(key, "value '%s' doesn't match the policy's %s", value, re)
+		}
+	}
+
+	if len(violations) > 0 {
+		return &ValidationError{Violations: violations}
+	}
+	return nil
+}
+
+// CheckResources - the tags each resource will end up with against ec2's limit of
+// tags per resource, which aws: tags don't count towards, and the keys policy
+// requires, policy may be nil. A *ValidationError lists every violation.
+func CheckResources(final map[string]map[string]string, resources []string, policy *Policy) error {
+	var violations []Violation
+	for _, resource := range resources {
+		tags := final[resource]
+		count := 0
+		for key := range tags {
+			if !strings.HasPrefix(strings.ToLower(key), ReservedPrefix) {
+				count++
+			}
+		}
+		if count > MaxTagsPerResource {
+			violations = append(violations, Violation{
+				Resource: resource,
+				Message:  fmt.Sprintf("would have %d tags, at most %d", count, MaxTagsPerResource),
+			})
+		}
+		if policy == nil {
+			continue
+		}
+		for _, key := range policy.Required {
+			if _, ok := tags[key]; !ok {
+				violations = append(violations, Violation{Resource: resource, Key: key, Message: "required by the policy"})
+			}
+		}
+	}
+
+	if len(violations) > 0 {
+		return &ValidationError{Violations: violations}
+	}
+	return nil
+}
+
+// Merged - the tags resources end up with once tags are set on them
+func Merged(current map[string]map[string]string, tags map[string]string) map[string]map[string]string {
+	final := make(map[string]map[string]string, len(current))
+	for resource, existing := range current {
+		final[resource] = make(map[string]string, len(existing)+len(tags))
+		for key, value := range existing {
+			final[resource][key] = value
+		}
+		for key, value := range tags {
+			final[resource][key] = value
+		}
+	}
+	return final
+}
+
+// Synced - the tags resources end up with once synced to tags, keeping their
+// protected keys, see Diff
+func Synced(current map[string]map[string]string, tags map[string]string, protected []string) map[string]map[string]string {
+	kept := make(map[string]map[string]string, len(current))
+	for resource, existing := range current {
+		kept[resource] = make(map[string]string)
+		for key, value := range existing {
+			if Protected(key, protected) {
+				kept[resource][key] = value
+			}
+		}
+	}
+	return Merged(kept, tags)
+}
+
+// Deleted - the tags resources end up with once tags are deleted: a key with an
+// empty value whatever its value, others only where they have that value
+func Deleted(current map[string]map[string]string, tags map[string]string) map[string]map[string]string {
+	final := make(map[string]map[string]string, len(current))
+	for resource, existing := range current {
+		final[resource] = make(map[string]string, len(existing))
+		for key, value := range existing {
+			if deleted, ok := tags[key]; !ok || deleted != "" && deleted != value {
+				final[resource][key] = value
+			}
+		}
+	}
+	return final
+}
+
+// pattern - the compiled allowed values of key, nil policies allow anything
+func (p *Policy) pattern(key string) (*regexp.Regexp, bool) {
+	if p == nil {
+		return nil, false
+	}
+	re, ok := p.patterns[key]
+	return re, ok
+}
+
+// portable - true when the policy limits tags to portableChars
+func (p *Policy) portable() bool {
+	return p != nil && p.Portable
+}
@@ -0,0 +1,356 @@
+// Package ec2wait - wait for ec2 resources to reach a state
+package ec2wait
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/awsutil"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/private/waiter"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/ec2/ec2iface"
+
+	"github.com/aidevops/awscli"
+)
+
+// Errors Wait returns when the resources don't get there
+var (
+	ErrTimeout = errors.New("timed out")
+	ErrFailed  = errors.New("reached a state it can't recover from")
+)
+
+// Condition - what to poll for a wait and when it's done. Operation, Delay,
+// MaxAttempts and Acceptors are those of the sdk's WaitUntil function of the
+// same name in service/ec2/waiters.go, which takes neither a timeout nor a
+// poll interval. They were taken from aws-sdk-go 1.1.35, the tests compare
+// them with the vendored sdk's.
+type Condition struct {
+	Operation   string
+	Delay       time.Duration
+	MaxAttempts int
+	Acceptors   []waiter.WaitAcceptor
+	// Input - the operation's input for the resource ids
+	Input func(ids []string) interface{}
+	// States - path of the states shown in progress reports
+	States string
+}
+
+// Attempt - progress report of one poll
+type Attempt struct {
+	Condition string
+	Number    int
+	Elapsed   time.Duration
+	Timeout   time.Duration
+	States    []string
+	Err       error
+}
+
+// String -
+func (a Attempt) String() string {
+	status := strings.Join(a.States, ", ")
+	if a.Err != nil {
+		status = a.Err.Error()
+	}
+	return fmt.Sprintf("waiting for %s, poll %d, %s of %s: %s", a.Condition, a.Number,
+		a.Elapsed/time.Second*time.Second, a.Timeout, status)
+}
+
+// Options - how often and how long to poll, the condition's own delay and
+// delay times attempts when zero
+type Options struct {
+	Interval time.Duration
+	Timeout  time.Duration
+	// Progress - called after every poll
+	Progress func(Attempt)
+}
+
+// Conditions - the waits by name, as 'aws ec2 wait' calls them
+var Conditions = map[string]Condition{
+	"instance-exists": {
+		Operation: "DescribeInstances", Delay: 5 * time.Second, MaxAttempts: 40,
+		Acceptors: []waiter.WaitAcceptor{
+			{State: "success", Matcher: "path", Argument: "length(Reservations[]) > `0`", Expected: true},
+			{State: "retry", Matcher: "error", Expected: "InvalidInstanceID.NotFound"},
+		},
+		Input:  instances,
+		States: "Reservations[].Instances[].State.Name",
+	},
+	"instance-running": {
+		Operation: "DescribeInstances", Delay: 15 * time.Second, MaxAttempts: 40,
+		Acceptors: []waiter.WaitAcceptor{
+			{State: "success", Matcher: "pathAll", Argument: "Reservations[].Instances[].State.Name", Expected: "running"},
+			{State: "failure", Matcher: "pathAny", Argument: "Reservations[].Instances[].State.Name", Expected: "shutting-down"},
+			{State: "failure", Matcher: "pathAny", Argument: "Reservations[].Instances[].State.Name", Expected: "terminated"},
+			{State: "failure", Matcher: "pathAny", Argument: "Reservations[].Instances[].State.Name", Expected: "stopping"},
+			{State: "retry", Matcher: "error", Expected: "InvalidInstanceID.NotFound"},
+		},
+		Input:  instances,
+		States: "Reservations[].Instances[].State.Name",
+	},
+	"instance-stopped": {
+		Operation: "DescribeInstances", Delay: 15 * time.Second, MaxAttempts: 40,
+		Acceptors: []waiter.WaitAcceptor{
+			{State: "success", Matcher: "pathAll", Argument: "Reservations[].Instances[].State.Name", Expected: "stopped"},
+			{State: "failure", Matcher: "pathAny", Argument: "Reservations[].Instances[].State.Name", Expected: "pending"},
+			{State: "failure", Matcher: "pathAny", Argument: "Reservations[].Instances[].State.Name", Expected: "terminated"},
+		},
+		Input:  instances,
+		States: "Reservations[].Instances[].State.Name",
+	},
+	"instance-terminated": {
+		Operation: "DescribeInstances", Delay: 15 * time.Second, MaxAttempts: 40,
+		Acceptors: []waiter.WaitAcceptor{
+			{State: "success", Matcher: "pathAll", Argument: "Reservations[].Instances[].State.Name", Expected: "terminated"},
+			{State: "failure", Matcher: "pathAny", Argument: "Reservations[].Instances[].State.Name", Expected: "pending"},
+			{State: "failure", Matcher: "pathAny", Argument: "Reservations[].Instances[].State.Name", Expected: "stopping"},
+		},
+		Input:  instances,
+		States: "Reservations[].Instances[].State.Name",
+	},
+	"instance-status-ok": {
+		Operation: "DescribeInstanceStatus", Delay: 15 * time.Second, MaxAttempts: 40,
+		Acceptors: []waiter.WaitAcceptor{
+			{State: "success", Matcher: "pathAll", Argument: "InstanceStatuses[].InstanceStatus.Status", Expected: "ok"},
+			{State: "retry", Matcher: "error", Expected: "InvalidInstanceID.NotFound"},
+		},
+		Input: func(ids []string) interface{} {
+			return &ec2.DescribeInstanceStatusInput{InstanceIds: aws.StringSlice(ids)}
+		},
+		States: "InstanceStatuses[].InstanceStatus.Status",
+	},
+	"system-status-ok": {
+		Operation: "DescribeInstanceStatus", Delay: 15 * time.Second, MaxAttempts: 40,
+		Acceptors: []waiter.WaitAcceptor{
+			{State: "success", Matcher: "pathAll", Argument: "InstanceStatuses[].SystemStatus.Status", Expected: "ok"},
+		},
+		Input: func(ids []string) interface{} {
+			return &ec2.DescribeInstanceStatusInput{InstanceIds: aws.StringSlice(ids)}
+		},
+		States: "InstanceStatuses[].SystemStatus.Status",
+	},
+	"image-available": {
+		Operation: "DescribeImages", Delay: 15 * time.Second, MaxAttempts: 40,
+		Acceptors: []waiter.WaitAcceptor{
+			{State: "success", Matcher: "pathAll", Argument: "Images[].State", Expected: "available"},
+			{State: "failure", Matcher: "pathAny", Argument: "Images[].State", Expected: "failed"},
+		},
+		Input:  images,
+		States: "Images[].State",
+	},
+	"image-exists": {
+		Operation: "DescribeImages", Delay: 15 * time.Second, MaxAttempts: 40,
+		Acceptors: []waiter.WaitAcceptor{
+			{State: "success", Matcher: "path", Argument: "length(Images[]) > `0`", Expected: true},
+			{State: "retry", Matcher: "error", Expected: "InvalidAMIID.NotFound"},
+		},
+		Input:  images,
+		States: "Images[].State",
+	},
+	"snapshot-completed": {
+		Operation: "DescribeSnapshots", Delay: 15 * time.Second, MaxAttempts: 40,
+		Acceptors: []waiter.WaitAcceptor{
+			{State: "success", Matcher: "pathAll", Argument: "Snapshots[].State", Expected: "completed"},
+		},
+		Input: func(ids []string) interface{} {
+			return &ec2.DescribeSnapshotsInput{SnapshotIds: aws.StringSlice(ids)}
+		},
+		States: "Snapshots[].State",
+	},
+	"volume-available": {
+		Operation: "DescribeVolumes", Delay: 15 * time.Second, MaxAttempts: 40,
+		Acceptors: []waiter.WaitAcceptor{
+			{State: "success", Matcher: "pathAll", Argument: "Volumes[].State", Expected: "available"},
+			{State: "failure", Matcher: "pathAny", Argument: "Volumes[].State", Expected: "deleted"},
+		},
+		Input:  volumes,
+		States: "Volumes[].State",
+	},
+	"volume-in-use": {
+		Operation: "DescribeVolumes", Delay: 15 * time.Second, MaxAttempts: 40,
+		Acceptors: []waiter.WaitAcceptor{
+			{State: "success", Matcher: "pathAll", Argument: "Volumes[].State", Expected: "in-use"},
+			{State: "failure", Matcher: "pathAny", Argument: "Volumes[].State", Expected: "deleted"},
+		},
+		Input:  volumes,
+		States: "Volumes[].State",
+	},
+	"volume-deleted": {
+		Operation: "DescribeVolumes", Delay: 15 * time.Second, MaxAttempts: 40,
+		Acceptors: []waiter.WaitAcceptor{
+			{State: "success", Matcher: "pathAll", Argument: "Volumes[].State", Expected: "deleted"},
+			{State: "success", Matcher: "error", Expected: "InvalidVolume.NotFound"},
+		},
+		Input:  volumes,
+		States: "Volumes[].State",
+	},
+	"subnet-available": {
+		Operation: "DescribeSubnets", Delay: 15 * time.Second, MaxAttempts: 40,
+		Acceptors: []waiter.WaitAcceptor{
+			{State: "success", Matcher: "pathAll", Argument: "Subnets[].State", Expected: "available"},
+		},
+		Input: func(ids []string) interface{} {
+			return &ec2.DescribeSubnetsInput{SubnetIds: aws.StringSlice(ids)}
+		},
+		States: "Subnets[].State",
+	},
+	"vpc-available": {
+		Operation: "DescribeVpcs", Delay: 15 * time.Second, MaxAttempts: 40,
+		Acceptors: []waiter.WaitAcceptor{
+			{State: "success", Matcher: "pathAll", Argument: "Vpcs[].State", Expected: "available"},
+		},
+		Input: func(ids []string) interface{} {
+			return &ec2.DescribeVpcsInput{VpcIds: aws.StringSlice(ids)}
+		},
+		States: "Vpcs[].State",
+	},
+	"network-interface-available": {
+		Operation: "DescribeNetworkInterfaces", Delay: 20 * time.Second, MaxAttempts: 10,
+		Acceptors: []waiter.WaitAcceptor{
+			{State: "success", Matcher: "pathAll", Argument: "NetworkInterfaces[].Status", Expected: "available"},
+			{State: "failure", Matcher: "error", Expected: "InvalidNetworkInterfaceID.NotFound"},
+		},
+		Input: func(ids []string) interface{} {
+			return &ec2.DescribeNetworkInterfacesInput{NetworkInterfaceIds: aws.StringSlice(ids)}
+		},
+		States: "NetworkInterfaces[].Status",
+	},
+}
+
+// Names - the condition names, sorted
+func Names() []string {
+	var names []string
+	for name := range Conditions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Wait - poll every opts.Interval until the resources ids meet the named
+// condition. ErrFailed is returned when they reach a state they can't get there
+// from, ErrTimeout when opts.Timeout passes first and ctx's error when it's done.
+func Wait(ctx context.Context, svc ec2iface.EC2API, name string, ids []string, opts Options) error {
+	cond, ok := Conditions[name]
+	if !ok {
+		return fmt.Errorf("unknown condition '%s', expected one of: %s", name, strings.Join(Names(), ", "))
+	}
+	if len(ids) == 0 {
+		return fmt.Errorf("no ids to wait for")
+	}
+	if opts.Interval <= 0 {
+		opts.Interval = cond.Delay
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = cond.Delay * time.Duration(cond.MaxAttempts)
+	}
+
+	operation := reflect.ValueOf(svc).MethodByName(cond.Operation + "Request")
+	if !operation.IsValid() {
+		return fmt.Errorf("%s: the client has no %sRequest", name, cond.Operation)
+	}
+	start := time.Now()
+	deadline := start.Add(opts.Timeout)
+	for number := 1; ; number++ {
+		req := operation.Call([]reflect.Value{reflect.ValueOf(cond.Input(ids))})[0].Interface().(*request.Request)
+		err := awscli.Send(ctx, req)
+		if opts.Progress != nil {
+			attempt := Attempt{Condition: name, Number: number, Elapsed: time.Since(start), Timeout: opts.Timeout, Err: err}
+			if err == nil {
+				values, _ := awsutil.ValuesAtPath(req.Data, cond.States)
+				for _, value := range values {
+					if state, ok := value.(*string); ok {
+						attempt.States = append(attempt.States, aws.StringValue(state))
+					}
+				}
+			}
+			opts.Progress(attempt)
+		}
+
+		switch state, err := cond.match(req, err); {
+		case err != nil:
+			return err
+		case state == "success":
+			return nil
+		case state == "failure":
+			return ErrFailed
+		}
+
+		if time.Now().Add(opts.Interval).After(deadline) {
+			return ErrTimeout
+		}
+		timer := time.NewTimer(opts.Interval)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// match - the state of the first acceptor matching a poll, as the sdk's waiter
+// has it: none keeps polling, an error no retry acceptor matches is returned
+func (c Condition) match(req *request.Request, err error) (string, error) {
+	for _, a := range c.Acceptors {
+		result := false
+		switch a.Matcher {
+		case "path", "pathAll":
+			values, _ := awsutil.ValuesAtPath(req.Data, a.Argument)
+			result = len(values) > 0
+			for _, value := range values {
+				if !awsutil.DeepEqual(value, a.Expected) {
+					result = false
+					break
+				}
+			}
+		case "pathAny":
+			values, _ := awsutil.ValuesAtPath(req.Data, a.Argument)
+			for _, value := range values {
+				if awsutil.DeepEqual(value, a.Expected) {
+					result = true
+					break
+				}
+			}
+		case "status":
+			result = req.HTTPResponse != nil && req.HTTPResponse.StatusCode == a.Expected.(int)
+		case "error":
+			if aerr, ok := err.(awserr.Error); ok {
+				result = aerr.Code() == a.Expected.(string)
+			}
+		}
+		if !result {
+			continue
+		}
+
+		switch a.State {
+		case "success", "failure":
+			return a.State, nil
+		case "retry":
+			err = nil
+		}
+	}
+	return "", err
+}
+
+// instances -
+func instances(ids []string) interface{} {
+	return &ec2.DescribeInstancesInput{InstanceIds: aws.StringSlice(ids)}
+}
+
+// images -
+func images(ids []string) interface{} {
+	return &ec2.DescribeImagesInput{ImageIds: aws.StringSlice(ids)}
+}
+
+// volumes -
+func volumes(ids []string) interface{} {
+	return &ec2.DescribeVolumesInput{VolumeIds: aws.StringSlice(ids)}
+}
@@ -0,0 +1,190 @@
+package ec2wait_test
+
+import (
+	"context"
+	"go/ast"
+	"go/build"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/private/waiter"
+	"github.com/aws/aws-sdk-go/service/ec2"
+
+	"github.com/aidevops/awscli/ec2wait"
+	"github.com/aidevops/awscli/fakeaws"
+)
+
+// sdkWaiters - the waiter configs of the sdk's ec2 WaitUntil functions by
+// function name, read from the source of the sdk the build uses
+func sdkWaiters() map[string]waiter.Config {
+	wd, err := os.Getwd()
+	Expect(err).NotTo(HaveOccurred())
+	pkg, err := build.Import("github.com/aws/aws-sdk-go/service/ec2", wd, build.FindOnly)
+	Expect(err).NotTo(HaveOccurred())
+	file, err := parser.ParseFile(token.NewFileSet(), filepath.Join(pkg.Dir, "waiters.go"), nil, 0)
+	Expect(err).NotTo(HaveOccurred())
+
+	value := func(expr ast.Expr) interface{} {
+		switch e := expr.(type) {
+		case *ast.BasicLit:
+			if e.Kind == token.INT {
+				n, err := strconv.Atoi(e.Value)
+				Expect(err).NotTo(HaveOccurred())
+				return n
+			}
+			v, err := strconv.Unquote(e.Value)
+			Expect(err).NotTo(HaveOccurred())
+			return v
+		case *ast.Ident:
+			return e.Name == "true"
+		}
+		Fail("unexpected waiter value")
+		return nil
+	}
+	fields := func(lit *ast.CompositeLit) map[string]ast.Expr {
+		kv := make(map[string]ast.Expr)
+		for _, elt := range lit.Elts {
+			pair := elt.(*ast.KeyValueExpr)
+			kv[pair.Key.(*ast.Ident).Name] = pair.Value
+		}
+		return kv
+	}
+
+	configs := make(map[string]waiter.Config)
+	for _, decl := range file.Decls {
+		fn, ok := decl.(*ast.FuncDecl)
+		if !ok || !strings.HasPrefix(fn.Name.Name, "WaitUntil") {
+			continue
+		}
+		cfg := fields(fn.Body.List[0].(*ast.AssignStmt).Rhs[0].(*ast.CompositeLit))
+		config := waiter.Config{
+			Operation:   value(cfg["Operation"]).(string),
+			Delay:       value(cfg["Delay"]).(int),
+			MaxAttempts: value(cfg["MaxAttempts"]).(int),
+		}
+		for _, elt := range cfg["Acceptors"].(*ast.CompositeLit).Elts {
+			acceptor := fields(elt.(*ast.CompositeLit))
+			a := waiter.WaitAcceptor{State: value(acceptor["State"]).(string), Matcher: value(acceptor["Matcher"]).(string), Expected: value(acceptor["Expected"])}
+			if argument, ok := acceptor["Argument"]; ok {
+				a.Argument = value(argument).(string)
+			}
+			config.Acceptors = append(config.Acceptors, a)
+		}
+		configs[fn.Name.Name] = config
+	}
+	return configs
+}
+
+var _ = Describe("EC2Wait", func() {
+
+	var (
+		ctx      context.Context
+		srv      *fakeaws.Server
+		svc      *ec2.EC2
+		id       string
+		attempts []ec2wait.Attempt
+		opts     ec2wait.Options
+	)
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		srv = fakeaws.New()
+		cli := srv.AwsCli()
+		svc = ec2.New(cli.Session(), cli.ServiceConfig(ec2.ServiceName))
+		id = srv.AddInstance(&ec2.Instance{State: &ec2.InstanceState{Code: aws.Int64(0), Name: aws.String(ec2.InstanceStateNamePending)}})
+		attempts = nil
+		opts = ec2wait.Options{
+			Interval: time.Millisecond,
+			Timeout:  time.Second,
+			Progress: func(a ec2wait.Attempt) { attempts = append(attempts, a) },
+		}
+	})
+
+	AfterEach(func() {
+		srv.Close()
+	})
+
+	It("Polls until the condition is met, reporting every poll", func() {
+		opts.Progress = func(a ec2wait.Attempt) {
+			attempts = append(attempts, a)
+			if a.Number == 2 {
+				srv.SetInstanceState(id, ec2.InstanceStateNameRunning)
+			}
+		}
+		Expect(ec2wait.Wait(ctx, svc, "instance-running", []string{id}, opts)).To(Succeed())
+		Expect(attempts).To(HaveLen(3))
+		Expect(attempts[0].States).To(Equal([]string{"pending"}))
+		Expect(attempts[2].States).To(Equal([]string{"running"}))
+		Expect(attempts[2].String()).To(HavePrefix("waiting for instance-running, poll 3, 0s of 1s: running"))
+	})
+
+	It("Fails as soon as a resource reaches a failure state", func() {
+		srv.SetInstanceState(id, ec2.InstanceStateNameTerminated)
+		Expect(ec2wait.Wait(ctx, svc, "instance-running", []string{id}, opts)).To(Equal(ec2wait.ErrFailed))
+		Expect(attempts).To(HaveLen(1))
+	})
+
+	It("Times out when the condition is not met in time", func() {
+		opts.Interval = 10 * time.Millisecond
+		opts.Timeout = 50 * time.Millisecond
+		Expect(ec2wait.Wait(ctx, svc, "instance-running", []string{id}, opts)).To(Equal(ec2wait.ErrTimeout))
+		Expect(len(attempts)).To(BeNumerically(">=", 2))
+		Expect(len(attempts)).To(BeNumerically("<=", 5))
+	})
+
+	It("Keeps polling through the errors the condition retries, returning the others", func() {
+		srv.Fail("DescribeInstances", "InvalidInstanceID.NotFound", "The instance ID does not exist")
+		srv.SetInstanceState(id, ec2.InstanceStateNameRunning)
+		Expect(ec2wait.Wait(ctx, svc, "instance-running", []string{id}, opts)).To(Succeed())
+		Expect(attempts[0].Err).To(HaveOccurred())
+		Expect(attempts[0].String()).To(ContainSubstring("InvalidInstanceID.NotFound"))
+
+		err := ec2wait.Wait(ctx, svc, "snapshot-completed", []string{"snap-00000000"}, opts)
+		Expect(err).To(MatchError(ContainSubstring("InvalidSnapshot.NotFound")))
+	})
+
+	It("Waits for volumes and snapshots", func() {
+		volume := srv.AddVolume(&ec2.Volume{})
+		Expect(ec2wait.Wait(ctx, svc, "volume-available", []string{volume}, opts)).To(Succeed())
+		Expect(ec2wait.Wait(ctx, svc, "volume-in-use", []string{volume}, ec2wait.Options{Interval: time.Millisecond, Timeout: 5 * time.Millisecond})).To(Equal(ec2wait.ErrTimeout))
+
+		snapshot := srv.AddSnapshot(&ec2.Snapshot{VolumeId: aws.String(volume)})
+		Expect(ec2wait.Wait(ctx, svc, "snapshot-completed", []string{snapshot}, opts)).To(Succeed())
+	})
+
+	It("Stops when the context is done", func() {
+		ctx, cancel := context.WithCancel(ctx)
+		opts.Interval = time.Hour
+		opts.Timeout = 2 * time.Hour
+		opts.Progress = func(ec2wait.Attempt) { cancel() }
+		Expect(ec2wait.Wait(ctx, svc, "instance-running", []string{id}, opts)).To(Equal(context.Canceled))
+	})
+
+	It("Waits as the sdk's waiters do", func() {
+		configs := sdkWaiters()
+		for name, cond := range ec2wait.Conditions {
+			fn := "WaitUntil" + strings.Replace(strings.Title(strings.Replace(name, "-", " ", -1)), " ", "", -1)
+			config, ok := configs[fn]
+			Expect(ok).To(BeTrue(), fn)
+			Expect(cond.Operation).To(Equal(config.Operation), name)
+			Expect(cond.Delay).To(Equal(time.Duration(config.Delay)*time.Second), name)
+			Expect(cond.MaxAttempts).To(Equal(config.MaxAttempts), name)
+			Expect(cond.Acceptors).To(Equal(config.Acceptors), name)
+		}
+	})
+
+	It("Refuses unknown conditions and no ids", func() {
+		Expect(ec2wait.Wait(ctx, svc, "instance-happy", []string{id}, opts)).To(MatchError(ContainSubstring("unknown condition 'instance-happy'")))
+		Expect(ec2wait.Wait(ctx, svc, "instance-running", nil, opts)).To(HaveOccurred())
+		Expect(ec2wait.Names()).To(ContainElement("instance-running"))
+	})
+})
@@ -5,40 +5,30 @@ import (
 	"fmt"
 
 	"github.com/aws/aws-sdk-go/aws"
-	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/ecr"
+
+	"github.com/aidevops/awscli/output"
 )
 
-// ECRInfo -
-func ECRInfo(registryID string) {
-	svc := ecr.New(session.New())
+// ECRInfo - print the registry's repositories
+func (a *AwsCli) ECRInfo(registryID string, p *output.Printer) error {
+	svc := ecr.New(a.Session(), a.ServiceConfig(ecr.ServiceName))
 
 	params := &ecr.DescribeRepositoriesInput{
 		MaxResults: aws.Int64(100),
-		// NextToken:  aws.String("NextToken"),
-		// RegistryId: aws.String("RegistryId"),
 		RegistryId: aws.String(registryID),
-		// RepositoryNames: []*string{
-		// 	aws.String("awscli"), // Required
-		// 	// More values...
-		// },
 	}
 	resp, err := svc.DescribeRepositories(params)
-
 	if err != nil {
-		// Print the error, cast err to awserr.Error to get the Code and
-		// Message from an error.
-		fmt.Println(err.Error())
-		return
+		return err
 	}
 
-	// Pretty-print the response data.
-	fmt.Println(resp)
+	return p.Print(resp)
 }
 
 // ECRLogin - login to aws ecr registry
-func ECRLogin(registryID string) (token string, err error) {
-	svc := ecr.New(session.New())
+func (a *AwsCli) ECRLogin(registryID string) (token string, err error) {
+	svc := ecr.New(a.Session(), a.ServiceConfig(ecr.ServiceName))
 
 	params := &ecr.GetAuthorizationTokenInput{
 		RegistryIds: []*string{
@@ -47,16 +37,12 @@ func ECRLogin(registryID string) (token string, err error) {
 		},
 	}
 	resp, err := svc.GetAuthorizationToken(params)
-
 	if err != nil {
-		// Print the error, cast err to awserr.Error to get the Code and
-		// Message from an error.
-		fmt.Println(err.Error())
 		return token, err
 	}
 
-	// Pretty-print the response data.
-	fmt.Println(resp)
-	token = fmt.Sprintf("%s", resp)
-	return token, nil
+	if len(resp.AuthorizationData) == 0 {
+		return token, fmt.Errorf("no authorization data returned for registry '%s'", registryID)
+	}
+	return aws.StringValue(resp.AuthorizationData[0].AuthorizationToken), nil
 }
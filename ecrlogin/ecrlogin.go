@@ -0,0 +1,72 @@
+// Package ecrlogin - docker login credentials for ecr registries
+package ecrlogin
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ecr"
+	"github.com/aws/aws-sdk-go/service/ecr/ecriface"
+
+	"github.com/aidevops/awscli"
+)
+
+// Credentials - a registry's decoded docker login
+type Credentials struct {
+	Username  string
+	Password  string
+	Endpoint  string
+	ExpiresAt time.Time
+}
+
+// DockerArgs - the docker arguments logging in with the credentials
+func (c *Credentials) DockerArgs() []string {
+	return []string{"login", "-u", c.Username, "-p", c.Password, "-e", "none", c.Endpoint}
+}
+
+// Authorization - fetch the raw authorization token response for registryID
+func Authorization(ctx context.Context, svc ecriface.ECRAPI, registryID string) (*ecr.GetAuthorizationTokenOutput, error) {
+	req, resp := svc.GetAuthorizationTokenRequest(&ecr.GetAuthorizationTokenInput{
+		RegistryIds: []*string{
+			aws.String(registryID), // Required
+		},
+	})
+	if err := awscli.Send(ctx, req); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// Login - fetch and decode the docker login credentials of registryID
+func Login(ctx context.Context, svc ecriface.ECRAPI, registryID string) (*Credentials, error) {
+	resp, err := Authorization(ctx, svc, registryID)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(resp.AuthorizationData) == 0 {
+		return nil, fmt.Errorf("no authorization data returned for registry '%s'", registryID)
+	}
+	data := resp.AuthorizationData[0]
+
+	decoded, err := base64.StdEncoding.DecodeString(aws.StringValue(data.AuthorizationToken))
+	if err != nil {
+		return nil, fmt.Errorf("decode error: %s", err)
+	}
+
+	creds := strings.SplitN(string(decoded), ":", 2)
+	if len(creds) != 2 {
+		return nil, fmt.Errorf("decode error: malformed authorization token")
+	}
+
+	return &Credentials{
+		Username:  creds[0],
+		Password:  creds[1],
+		Endpoint:  aws.StringValue(data.ProxyEndpoint),
+		ExpiresAt: aws.TimeValue(data.ExpiresAt),
+	}, nil
+}
@@ -0,0 +1,41 @@
+package ecrlogin_test
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/aws/aws-sdk-go/service/ecr"
+
+	"github.com/aidevops/awscli/ecrlogin"
+	"github.com/aidevops/awscli/fakeaws"
+)
+
+var _ = Describe("Login", func() {
+
+	var (
+		srv *fakeaws.Server
+		svc *ecr.ECR
+	)
+
+	BeforeEach(func() {
+		srv = fakeaws.New()
+		cli := srv.AwsCli()
+		svc = ecr.New(cli.Session(), cli.ServiceConfig(ecr.ServiceName))
+	})
+
+	AfterEach(func() {
+		srv.Close()
+	})
+
+	It("Decodes the registry credentials", func() {
+		creds, err := ecrlogin.Login(context.Background(), svc, srv.Account)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(creds.Username).To(Equal("AWS"))
+		Expect(creds.Password).To(Equal(fakeaws.RegistryPassword))
+		Expect(creds.Endpoint).To(Equal("https://" + srv.Account + ".dkr.ecr." + srv.Region + ".amazonaws.com"))
+		Expect(creds.ExpiresAt.IsZero()).To(BeFalse())
+		Expect(creds.DockerArgs()).To(Equal([]string{"login", "-u", "AWS", "-p", fakeaws.RegistryPassword, "-e", "none", creds.Endpoint}))
+	})
+})
@@ -0,0 +1,68 @@
+// Package awscli -
+package awscli
+
+import (
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+)
+
+// Endpoint - return the endpoint override for service ("ec2", "ecr", "sqs", "s3"...),
+// an empty string means the SDK's regional endpoint is used.
+//
+// Lookup order: -endpoint-url (either a single url or 'sqs=url,s3=url' pairs),
+// $AWS_ENDPOINT_URL_<SERVICE>, $AWS_ENDPOINT_URL, then the profile's
+// '<service>_endpoint_url' and 'endpoint_url' keys in the shared config file.
+func (a *AwsCli) Endpoint(service string) string {
+	if a.EndpointURL != "" {
+		if !strings.Contains(a.EndpointURL, "=") {
+			return a.EndpointURL
+		}
+		for _, pair := range strings.Split(a.EndpointURL, ",") {
+			kv := strings.SplitN(strings.TrimSpace(pair), "=", 2)
+			if len(kv) == 2 && strings.EqualFold(kv[0], service) {
+				return kv[1]
+			}
+		}
+	}
+
+	for _, env := range []string{"AWS_ENDPOINT_URL_" + strings.ToUpper(service), "AWS_ENDPOINT_URL"} {
+		if endpoint := os.Getenv(env); endpoint != "" {
+			return endpoint
+		}
+	}
+
+	profile := a.GetProfile()
+	if endpoint := ProfileValue(profile, strings.ToLower(service)+"_endpoint_url"); endpoint != "" {
+		return endpoint
+	}
+	return ProfileValue(profile, "endpoint_url")
+}
+
+// GetS3PathStyle - return true when s3 requests should use path style addressing:
+// -s3-path-style, $AWS_S3_FORCE_PATH_STYLE or the profile's 's3_force_path_style'
+func (a *AwsCli) GetS3PathStyle() bool {
+	if a.S3PathStyle {
+		return true
+	}
+	if force, err := strconv.ParseBool(os.Getenv("AWS_S3_FORCE_PATH_STYLE")); err == nil {
+		return force
+	}
+	force, _ := strconv.ParseBool(ProfileValue(a.GetProfile(), "s3_force_path_style"))
+	return force
+}
+
+// ServiceConfig - return the per service configuration to pass alongside Session()
+// when creating a client, e.g. sqs.New(cli.Session(), cli.ServiceConfig(sqs.ServiceName))
+func (a *AwsCli) ServiceConfig(service string) *aws.Config {
+	cfg := aws.NewConfig()
+	if endpoint := a.Endpoint(service); endpoint != "" {
+		cfg = cfg.WithEndpoint(endpoint)
+	}
+	if service == "s3" && a.GetS3PathStyle() {
+		cfg = cfg.WithS3ForcePathStyle(true)
+	}
+	return cfg
+}
@@ -0,0 +1,331 @@
+// Package fakeaws -
+package fakeaws
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/xml"
+	"fmt"
+	"net/url"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// iso8601 - the timestamp layout the sdk's xml unmarshaler accepts (no fractional seconds)
+const iso8601 = "2006-01-02T15:04:05Z"
+
+// decodeQuery - fill the sdk input struct v from an ec2/sqs query request, the
+// inverse of the sdk's queryutil.Parse
+func decodeQuery(form url.Values, v interface{}, isEC2 bool) error {
+	d := queryDecoder{form: form, isEC2: isEC2}
+	return d.decodeValue(reflect.ValueOf(v), "", "")
+}
+
+// queryDecoder -
+type queryDecoder struct {
+	form  url.Values
+	isEC2 bool
+}
+
+// has - true when the form holds name or anything nested below it
+func (d *queryDecoder) has(name string) bool {
+	for key := range d.form {
+		if key == name || strings.HasPrefix(key, name+".") {
+			return true
+		}
+	}
+	return false
+}
+
+// decodeValue -
+func (d *queryDecoder) decodeValue(value reflect.Value, prefix string, tag reflect.StructTag) error {
+	t := tag.Get("type")
+	kind := value.Type().Kind()
+	if kind == reflect.Ptr {
+		kind = value.Type().Elem().Kind()
+	}
+	if t == "" {
+		switch kind {
+		case reflect.Struct:
+			t = "structure"
+		case reflect.Slice:
+			t = "list"
+		case reflect.Map:
+			t = "map"
+		}
+	}
+
+	switch t {
+	case "structure":
+		return d.decodeStruct(value, prefix)
+	case "list":
+		return d.decodeList(value, prefix, tag)
+	case "map":
+		return d.decodeMap(value, prefix, tag)
+	default:
+		return d.decodeScalar(value, prefix)
+	}
+}
+
+// decodeStruct -
+func (d *queryDecoder) decodeStruct(value reflect.Value, prefix string) error {
+	if value.Kind() == reflect.Ptr {
+		if value.IsNil() {
+			value.Set(reflect.New(value.Type().Elem()))
+		}
+		value = value.Elem()
+	}
+
+	t := value.Type()
+	for i := 0; i < value.NumField(); i++ {
+		field := t.Field(i)
+		if field.PkgPath != "" {
+			continue // ignore unexported fields
+		}
+
+		var name string
+		if d.isEC2 {
+			name = field.Tag.Get("queryName")
+		}
+		if name == "" {
+			if field.Tag.Get("flattened") != "" && field.Tag.Get("locationNameList") != "" {
+				name = field.Tag.Get("locationNameList")
+			} else if locName := field.Tag.Get("locationName"); locName != "" {
+				name = locName
+			}
+			if name != "" && d.isEC2 {
+				name = strings.ToUpper(name[0:1]) + name[1:]
+			}
+		}
+		if name == "" {
+			name = field.Name
+		}
+		if prefix != "" {
+			name = prefix + "." + name
+		}
+
+		if !d.has(name) {
+			continue
+		}
+		if err := d.decodeValue(value.Field(i), name, field.Tag); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// decodeList -
+func (d *queryDecoder) decodeList(value reflect.Value, prefix string, tag reflect.StructTag) error {
+	if !d.isEC2 && tag.Get("flattened") == "" {
+		prefix += ".member"
+	}
+
+	list := reflect.MakeSlice(value.Type(), 0, 0)
+	for i := 1; d.has(prefix + "." + strconv.Itoa(i)); i++ {
+		item := reflect.New(value.Type().Elem()).Elem()
+		if err := d.decodeValue(item, prefix+"."+strconv.Itoa(i), ""); err != nil {
+			return err
+		}
+		list = reflect.Append(list, item)
+	}
+	value.Set(list)
+	return nil
+}
+
+// decodeMap -
+func (d *queryDecoder) decodeMap(value reflect.Value, prefix string, tag reflect.StructTag) error {
+	if !d.isEC2 && tag.Get("flattened") == "" {
+		prefix += ".entry"
+	}
+	kname, vname := "key", "value"
+	if n := tag.Get("locationNameKey"); n != "" {
+		kname = n
+	}
+	if n := tag.Get("locationNameValue"); n != "" {
+		vname = n
+	}
+
+	m := reflect.MakeMap(value.Type())
+	for i := 1; d.has(prefix + "." + strconv.Itoa(i)); i++ {
+		entry := prefix + "." + strconv.Itoa(i)
+		item := reflect.New(value.Type().Elem()).Elem()
+		if err := d.decodeValue(item, entry+"."+vname, ""); err != nil {
+			return err
+		}
+		m.SetMapIndex(reflect.ValueOf(d.form.Get(entry+"."+kname)), item)
+	}
+	value.Set(m)
+	return nil
+}
+
+// decodeScalar -
+func (d *queryDecoder) decodeScalar(value reflect.Value, name string) error {
+	raw := d.form.Get(name)
+	var v interface{}
+	switch value.Interface().(type) {
+	case *string:
+		v = &raw
+	case []byte:
+		b, err := base64.StdEncoding.DecodeString(raw)
+		if err != nil {
+			return fmt.Errorf("%s: %s", name, err)
+		}
+		v = b
+	case *bool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return fmt.Errorf("%s: %s", name, err)
+		}
+		v = &b
+	case *int64:
+		i, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil {
+			return fmt.Errorf("%s: %s", name, err)
+		}
+		v = &i
+	case *float64:
+		f, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return fmt.Errorf("%s: %s", name, err)
+		}
+		v = &f
+	case *time.Time:
+		t, err := time.Parse(iso8601, raw)
+		if err != nil {
+			return fmt.Errorf("%s: %s", name, err)
+		}
+		v = &t
+	default:
+		return fmt.Errorf("unsupported query value for %s: %s", name, value.Type())
+	}
+	value.Set(reflect.ValueOf(v))
+	return nil
+}
+
+// encodeFields - write each exported field of the sdk output struct value as xml,
+// following the same locationName rules the sdk's xmlutil unmarshaler reads
+func encodeFields(buf *bytes.Buffer, value reflect.Value) {
+	for value.Kind() == reflect.Ptr {
+		if value.IsNil() {
+			return
+		}
+		value = value.Elem()
+	}
+
+	t := value.Type()
+	for i := 0; i < value.NumField(); i++ {
+		field := t.Field(i)
+		if field.PkgPath != "" || field.Tag.Get("location") != "" {
+			continue // ignore unexported and header/uri members
+		}
+
+		name := field.Name
+		if field.Tag.Get("flattened") != "" && field.Tag.Get("locationNameList") != "" {
+			name = field.Tag.Get("locationNameList")
+		} else if locName := field.Tag.Get("locationName"); locName != "" {
+			name = locName
+		}
+		encodeValue(buf, name, value.Field(i), field.Tag)
+	}
+}
+
+// encodeValue -
+func encodeValue(buf *bytes.Buffer, name string, value reflect.Value, tag reflect.StructTag) {
+	switch value.Kind() {
+	case reflect.Ptr, reflect.Slice, reflect.Map, reflect.Interface:
+		if value.IsNil() {
+			return
+		}
+	}
+
+	elem := value
+	for elem.Kind() == reflect.Ptr {
+		elem = elem.Elem()
+	}
+
+	switch {
+	case elem.Kind() == reflect.Slice && elem.Type().Elem().Kind() != reflect.Uint8:
+		if tag.Get("flattened") != "" {
+			for i := 0; i < elem.Len(); i++ {
+				encodeValue(buf, name, elem.Index(i), "")
+			}
+			return
+		}
+		member := tag.Get("locationNameList")
+		if member == "" {
+			member = "member"
+		}
+		fmt.Fprintf(buf, "<%s>", name)
+		for i := 0; i < elem.Len(); i++ {
+			encodeValue(buf, member, elem.Index(i), "")
+		}
+		fmt.Fprintf(buf, "</%s>", name)
+	case elem.Kind() == reflect.Map:
+		kname, vname := "key", "value"
+		if n := tag.Get("locationNameKey"); n != "" {
+			kname = n
+		}
+		if n := tag.Get("locationNameValue"); n != "" {
+			vname = n
+		}
+		flattened := tag.Get("flattened") != ""
+		if !flattened {
+			fmt.Fprintf(buf, "<%s>", name)
+		}
+		for _, key := range sortedKeys(elem) {
+			entry := "entry"
+			if flattened {
+				entry = name
+			}
+			fmt.Fprintf(buf, "<%s><%s>", entry, kname)
+			xml.EscapeText(buf, []byte(key))
+			fmt.Fprintf(buf, "</%s>", kname)
+			encodeValue(buf, vname, elem.MapIndex(reflect.ValueOf(key)), "")
+			fmt.Fprintf(buf, "</%s>", entry)
+		}
+		if !flattened {
+			fmt.Fprintf(buf, "</%s>", name)
+		}
+	case elem.Kind() == reflect.Struct && elem.Type() != reflect.TypeOf(time.Time{}):
+		fmt.Fprintf(buf, "<%s>", name)
+		encodeFields(buf, elem)
+		fmt.Fprintf(buf, "</%s>", name)
+	default:
+		fmt.Fprintf(buf, "<%s>", name)
+		xml.EscapeText(buf, []byte(scalarString(elem)))
+		fmt.Fprintf(buf, "</%s>", name)
+	}
+}
+
+// scalarString -
+func scalarString(value reflect.Value) string {
+	switch v := value.Interface().(type) {
+	case string:
+		return v
+	case []byte:
+		return base64.StdEncoding.EncodeToString(v)
+	case bool:
+		return strconv.FormatBool(v)
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case time.Time:
+		return v.UTC().Format(iso8601)
+	default:
+		return fmt.Sprintf("%v", v)
+	}
+}
+
+// sortedKeys - map keys in a stable order
+func sortedKeys(m reflect.Value) []string {
+	keys := make([]string, 0, m.Len())
+	for _, k := range m.MapKeys() {
+		keys = append(keys, k.String())
+	}
+	sort.Strings(keys)
+	return keys
+}
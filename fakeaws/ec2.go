@@ -0,0 +1,675 @@
+// Package fakeaws -
+package fakeaws
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+
+	"github.com/aidevops/awscli/ec2ext"
+)
+
+// ec2Actions - ec2 query actions the fake server implements
+var ec2Actions = map[string]interface{}{
+	"CreateTags":                    (*Server).createTags,
+	"DeleteTags":                    (*Server).deleteTags,
+	"DescribeTags":                  (*Server).describeTags,
+	"DescribeInstances":             (*Server).describeInstances,
+	"DescribeVolumes":               (*Server).describeVolumes,
+	"DescribeSnapshots":             (*Server).describeSnapshots,
+	"DescribeRegions":               (*Server).describeRegions,
+	"StartInstances":                (*Server).startInstances,
+	"StopInstances":                 (*Server).stopInstances,
+	"RebootInstances":               (*Server).rebootInstances,
+	"TerminateInstances":            (*Server).terminateInstances,
+	"DescribeSecurityGroups":        (*Server).describeSecurityGroups,
+	"AuthorizeSecurityGroupIngress": (*Server).authorizeSecurityGroupIngress,
+	"RevokeSecurityGroupIngress":    (*Server).revokeSecurityGroupIngress,
+	"AuthorizeSecurityGroupEgress":  (*Server).authorizeSecurityGroupEgress,
+	"RevokeSecurityGroupEgress":     (*Server).revokeSecurityGroupEgress,
+
+	"UpdateSecurityGroupRuleDescriptionsIngress": (*Server).updateSecurityGroupRuleDescriptionsIngress,
+	"UpdateSecurityGroupRuleDescriptionsEgress":  (*Server).updateSecurityGroupRuleDescriptionsEgress,
+}
+
+// ec2State -
+type ec2State struct {
+	tags      map[string]map[string]string
+	groups    map[string]*securityGroup
+	instances map[string]*ec2.Instance
+	volumes   map[string]*ec2.Volume
+	snapshots map[string]*ec2.Snapshot
+}
+
+// securityGroup - a group and its rules, one cidr or group pair per rule
+type securityGroup struct {
+	id      string
+	name    string
+	vpcID   string
+	owner   string
+	ingress []*ec2ext.IpPermission
+	egress  []*ec2ext.IpPermission
+}
+
+// newEC2State -
+func newEC2State() *ec2State {
+	return &ec2State{
+		tags:      make(map[string]map[string]string),
+		groups:    make(map[string]*securityGroup),
+		instances: make(map[string]*ec2.Instance),
+		volumes:   make(map[string]*ec2.Volume),
+		snapshots: make(map[string]*ec2.Snapshot),
+	}
+}
+
+// Tags - return a copy of the tags on resource
+func (s *Server) Tags(resource string) map[string]string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	tags := make(map[string]string)
+	for k, v := range s.ec2.tags[resource] {
+		tags[k] = v
+	}
+	return tags
+}
+
+// SetTags - replace the tags on resource
+func (s *Server) SetTags(resource string, tags map[string]string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.ec2.tags[resource] = make(map[string]string)
+	for k, v := range tags {
+		s.ec2.tags[resource][k] = v
+	}
+}
+
+// CreateSecurityGroup - add a security group and return its id, one in a vpc
+// allows all egress like ec2's do
+func (s *Server) CreateSecurityGroup(name, vpcID string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	id := fmt.Sprintf("sg-%08x", s.nextID())
+	sg := &securityGroup{id: id, name: name, vpcID: vpcID, owner: s.Account}
+	if vpcID != "" {
+		sg.egress = []*ec2ext.IpPermission{{IpProtocol: aws.String("-1"), IpRanges: []*ec2ext.IpRange{{CidrIp: aws.String("0.0.0.0/0")}}}}
+	}
+	s.ec2.groups[id] = sg
+	return id
+}
+
+// SecurityGroup - return the described security group, nil if it does not exist
+func (s *Server) SecurityGroup(id string) *ec2ext.SecurityGroup {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sg, ok := s.ec2.groups[id]
+	if !ok {
+		return nil
+	}
+	return s.describeGroup(sg)
+}
+
+// createTags - once instances or volumes were added, unknown ids of that kind are
+// rejected like ec2 does, and the whole call with them
+func (s *Server) createTags(in *ec2.CreateTagsInput) (*ec2.CreateTagsOutput, error) {
+	for _, resource := range in.Resources {
+		id := aws.StringValue(resource)
+		if _, ok := s.ec2.instances[id]; !ok && len(s.ec2.instances) > 0 && resourceType(id) == "instance" {
+			return nil, newError("InvalidInstanceID.NotFound", "The instance ID '%s' does not exist", id)
+		}
+		if _, ok := s.ec2.volumes[id]; !ok && len(s.ec2.volumes) > 0 && resourceType(id) == "volume" {
+			return nil, newError("InvalidVolume.NotFound", "The volume '%s' does not exist.", id)
+		}
+	}
+	for _, resource := range in.Resources {
+		id := aws.StringValue(resource)
+		if s.ec2.tags[id] == nil {
+			s.ec2.tags[id] = make(map[string]string)
+		}
+		for _, tag := range in.Tags {
+			s.ec2.tags[id][aws.StringValue(tag.Key)] = aws.StringValue(tag.Value)
+		}
+	}
+	return &ec2.CreateTagsOutput{}, nil
+}
+
+// deleteTags - a tag without a value deletes the key, one with a value only when it matches
+func (s *Server) deleteTags(in *ec2.DeleteTagsInput) (*ec2.DeleteTagsOutput, error) {
+	for _, resource := range in.Resources {
+		id := aws.StringValue(resource)
+		for _, tag := range in.Tags {
+			key := aws.StringValue(tag.Key)
+			if current, ok := s.ec2.tags[id][key]; ok && (tag.Value == nil || *tag.Value == current) {
+				delete(s.ec2.tags[id], key)
+			}
+		}
+	}
+	return &ec2.DeleteTagsOutput{}, nil
+}
+
+// describeTags - tags of every resource, filtered by resource-id, resource-type, key and value
+func (s *Server) describeTags(in *ec2.DescribeTagsInput) (*ec2.DescribeTagsOutput, error) {
+	resources := make([]string, 0, len(s.ec2.tags))
+	for id := range s.ec2.tags {
+		resources = append(resources, id)
+	}
+	sort.Strings(resources)
+
+	var tags []*ec2.TagDescription
+	for _, id := range resources {
+		for _, key := range sortedTagKeys(s.ec2.tags[id]) {
+			value := s.ec2.tags[id][key]
+			fields := map[string]string{"resource-id": id, "resource-type": resourceType(id), "key": key, "value": value}
+			if !matchFilters(in.Filters, fields, nil) {
+				continue
+			}
+			tags = append(tags, &ec2.TagDescription{
+				ResourceId:   aws.String(id),
+				ResourceType: aws.String(resourceType(id)),
+				Key:          aws.String(key),
+				Value:        aws.String(value),
+			})
+		}
+	}
+
+	start, end, next, err := s.page(len(tags), in.NextToken, in.MaxResults)
+	if err != nil {
+		return nil, err
+	}
+	return &ec2.DescribeTagsOutput{Tags: append([]*ec2.TagDescription{}, tags[start:end]...), NextToken: next}, nil
+}
+
+// resourceType - the DescribeTags resource type of an id, from its prefix
+func resourceType(id string) string {
+	types := map[string]string{
+		"i": "instance", "vol": "volume", "snap": "snapshot", "eni": "network-interface",
+		"sg": "security-group", "ami": "image", "vpc": "vpc", "subnet": "subnet",
+	}
+	if t, ok := types[strings.SplitN(id, "-", 2)[0]]; ok {
+		return t
+	}
+	return "unknown"
+}
+
+// describeSecurityGroups -
+func (s *Server) describeSecurityGroups(in *ec2.DescribeSecurityGroupsInput) (*ec2ext.DescribeSecurityGroupsOutput, error) {
+	ids := aws.StringValueSlice(in.GroupIds)
+	names := aws.StringValueSlice(in.GroupNames)
+	for _, id := range ids {
+		if _, ok := s.ec2.groups[id]; !ok {
+			return nil, newError("InvalidGroup.NotFound", "The security group '%s' does not exist", id)
+		}
+	}
+
+	out := &ec2ext.DescribeSecurityGroupsOutput{SecurityGroups: []*ec2ext.SecurityGroup{}}
+	for _, id := range s.groupIDs() {
+		sg := s.ec2.groups[id]
+		if len(ids) > 0 && !contains(ids, sg.id) {
+			continue
+		}
+		if len(names) > 0 && !contains(names, sg.name) {
+			continue
+		}
+		fields := map[string]string{"group-id": sg.id, "group-name": sg.name, "vpc-id": sg.vpcID, "owner-id": sg.owner}
+		if !matchFilters(in.Filters, fields, s.ec2.tags[sg.id]) {
+			continue
+		}
+		out.SecurityGroups = append(out.SecurityGroups, s.describeGroup(sg))
+	}
+	return out, nil
+}
+
+// authorizeSecurityGroupIngress -
+func (s *Server) authorizeSecurityGroupIngress(in *ec2ext.RulesInput) (*ec2ext.RulesOutput, error) {
+	return s.authorize(in, false)
+}
+
+// authorizeSecurityGroupEgress -
+func (s *Server) authorizeSecurityGroupEgress(in *ec2ext.RulesInput) (*ec2ext.RulesOutput, error) {
+	return s.authorize(in, true)
+}
+
+// revokeSecurityGroupIngress -
+func (s *Server) revokeSecurityGroupIngress(in *ec2ext.RulesInput) (*ec2ext.RulesOutput, error) {
+	return s.revoke(in, false)
+}
+
+// revokeSecurityGroupEgress -
+func (s *Server) revokeSecurityGroupEgress(in *ec2ext.RulesInput) (*ec2ext.RulesOutput, error) {
+	return s.revoke(in, true)
+}
+
+// updateSecurityGroupRuleDescriptionsIngress -
+func (s *Server) updateSecurityGroupRuleDescriptionsIngress(in *ec2ext.RulesInput) (*ec2ext.RulesOutput, error) {
+	return s.updateDescriptions(in, false)
+}
+
+// updateSecurityGroupRuleDescriptionsEgress -
+func (s *Server) updateSecurityGroupRuleDescriptionsEgress(in *ec2ext.RulesInput) (*ec2ext.RulesOutput, error) {
+	return s.updateDescriptions(in, true)
+}
+
+// authorize - add the rules, all or none
+func (s *Server) authorize(in *ec2ext.RulesInput, egress bool) (*ec2ext.RulesOutput, error) {
+	sg, rules, err := s.groupRules(in, egress)
+	if err != nil {
+		return nil, err
+	}
+	list := sg.rules(egress)
+	for _, rule := range rules {
+		if indexOfRule(*list, rule) >= 0 {
+			return nil, newError("InvalidPermission.Duplicate",
+				"the specified rule \"%s\" already exists", ruleString(rule))
+		}
+	}
+	*list = append(*list, rules...)
+	return &ec2ext.RulesOutput{}, nil
+}
+
+// revoke - remove the rules, all or none
+func (s *Server) revoke(in *ec2ext.RulesInput, egress bool) (*ec2ext.RulesOutput, error) {
+	sg, rules, err := s.groupRules(in, egress)
+	if err != nil {
+		return nil, err
+	}
+	list := sg.rules(egress)
+	for _, rule := range rules {
+		if indexOfRule(*list, rule) < 0 {
+			return nil, newError("InvalidPermission.NotFound",
+				"The specified rule does not exist in this security group.")
+		}
+	}
+	for _, rule := range rules {
+		pos := indexOfRule(*list, rule)
+		*list = append((*list)[:pos], (*list)[pos+1:]...)
+	}
+	return &ec2ext.RulesOutput{}, nil
+}
+
+// updateDescriptions - replace the descriptions of existing rules
+func (s *Server) updateDescriptions(in *ec2ext.RulesInput, egress bool) (*ec2ext.RulesOutput, error) {
+	sg, rules, err := s.groupRules(in, egress)
+	if err != nil {
+		return nil, err
+	}
+	list := sg.rules(egress)
+	for _, rule := range rules {
+		if indexOfRule(*list, rule) < 0 {
+			return nil, newError("InvalidPermission.NotFound",
+				"The specified rule does not exist in this security group.")
+		}
+	}
+	for _, rule := range rules {
+		(*list)[indexOfRule(*list, rule)] = rule
+	}
+	return &ec2ext.RulesOutput{}, nil
+}
+
+// groupRules - the group and single rules of a rule operation's input
+func (s *Server) groupRules(in *ec2ext.RulesInput, egress bool) (*securityGroup, []*ec2ext.IpPermission, error) {
+	sg, err := s.lookupGroup(in.GroupId, in.GroupName)
+	if err != nil {
+		return nil, nil, err
+	}
+	if egress && sg.vpcID == "" {
+		return nil, nil, newError("InvalidGroup.NotFound", "EC2-Classic security group '%s' has no egress rules", sg.id)
+	}
+	rules, err := s.flattenPermissions(in)
+	if err != nil {
+		return nil, nil, err
+	}
+	return sg, rules, nil
+}
+
+// rules - the group's ingress or egress rules
+func (sg *securityGroup) rules(egress bool) *[]*ec2ext.IpPermission {
+	if egress {
+		return &sg.egress
+	}
+	return &sg.ingress
+}
+
+// groupIDs - sorted security group ids, the caller holds mu
+func (s *Server) groupIDs() []string {
+	ids := make([]string, 0, len(s.ec2.groups))
+	for id := range s.ec2.groups {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// lookupGroup - find a group by id or, failing that, name; the caller holds mu
+func (s *Server) lookupGroup(id, name *string) (*securityGroup, error) {
+	if aws.StringValue(id) != "" {
+		if sg, ok := s.ec2.groups[*id]; ok {
+			return sg, nil
+		}
+		return nil, newError("InvalidGroup.NotFound", "The security group '%s' does not exist", *id)
+	}
+	if aws.StringValue(name) != "" {
+		for _, gid := range s.groupIDs() {
+			if s.ec2.groups[gid].name == *name {
+				return s.ec2.groups[gid], nil
+			}
+		}
+		return nil, newError("InvalidGroup.NotFound", "The security group '%s' does not exist in default VPC", *name)
+	}
+	return nil, newError("MissingParameter", "The request must contain the parameter groupName or groupId")
+}
+
+// describeGroup - render a group with its rules merged by protocol and port range
+func (s *Server) describeGroup(sg *securityGroup) *ec2ext.SecurityGroup {
+	out := &ec2ext.SecurityGroup{
+		GroupId:             aws.String(sg.id),
+		GroupName:           aws.String(sg.name),
+		OwnerId:             aws.String(sg.owner),
+		Description:         aws.String(sg.name),
+		IpPermissions:       mergePermissions(sg.ingress),
+		IpPermissionsEgress: mergePermissions(sg.egress),
+	}
+	if sg.vpcID != "" {
+		out.VpcId = aws.String(sg.vpcID)
+	}
+	for _, k := range sortedTagKeys(s.ec2.tags[sg.id]) {
+		out.Tags = append(out.Tags, &ec2.Tag{Key: aws.String(k), Value: aws.String(s.ec2.tags[sg.id][k])})
+	}
+	return out
+}
+
+// flattenPermissions - split the flat parameters and IpPermissions into one
+// rule per range, prefix list or group. Protocol numbers ec2 has names for are named, group
+// pairs of the account are looked up and get their id and owner.
+func (s *Server) flattenPermissions(in *ec2ext.RulesInput) ([]*ec2ext.IpPermission, error) {
+	perms := in.IpPermissions
+	if aws.StringValue(in.CidrIp) != "" || aws.StringValue(in.SourceSecurityGroupName) != "" {
+		perm := &ec2ext.IpPermission{IpProtocol: in.IpProtocol, FromPort: in.FromPort, ToPort: in.ToPort}
+		if aws.StringValue(in.CidrIp) != "" {
+			perm.IpRanges = []*ec2ext.IpRange{{CidrIp: in.CidrIp}}
+		} else {
+			perm.UserIdGroupPairs = []*ec2ext.UserIdGroupPair{{GroupName: in.SourceSecurityGroupName, UserId: in.SourceSecurityGroupOwnerId}}
+		}
+		perms = append(perms, perm)
+	}
+	if len(perms) == 0 {
+		return nil, newError("MissingParameter", "No permissions were specified")
+	}
+
+	var rules []*ec2ext.IpPermission
+	for _, perm := range perms {
+		proto := strings.ToLower(aws.StringValue(perm.IpProtocol))
+		if name, ok := protocolNames[proto]; ok {
+			proto = name
+		}
+		base := ec2ext.IpPermission{IpProtocol: aws.String(proto)}
+		switch proto {
+		case "-1", "all":
+			base.IpProtocol = aws.String("-1")
+		case "tcp", "udp":
+			if perm.FromPort == nil || perm.ToPort == nil || *perm.FromPort < 0 || *perm.ToPort > 65535 || *perm.FromPort > *perm.ToPort {
+				return nil, newError("InvalidParameterValue", "Invalid port range (%d, %d) for protocol %s",
+					aws.Int64Value(perm.FromPort), aws.Int64Value(perm.ToPort), proto)
+			}
+			base.FromPort, base.ToPort = perm.FromPort, perm.ToPort
+		case "icmp", "icmpv6":
+			if perm.FromPort == nil || perm.ToPort == nil || *perm.FromPort < -1 || *perm.FromPort > 255 || *perm.ToPort < -1 || *perm.ToPort > 255 {
+				return nil, newError("InvalidParameterValue", "Invalid type/code (%d, %d) for protocol %s",
+					aws.Int64Value(perm.FromPort), aws.Int64Value(perm.ToPort), proto)
+			}
+			base.FromPort, base.ToPort = perm.FromPort, perm.ToPort
+		default:
+			if n, err := strconv.Atoi(proto); err != nil || n < 0 || n > 255 {
+				return nil, newError("InvalidParameterValue", "Invalid value '%s' for IP protocol", proto)
+			}
+		}
+
+		for _, r := range perm.IpRanges {
+			cidr, err := canonicalCIDR(r.CidrIp)
+			if err != nil {
+				return nil, err
+			}
+			rule := base
+			rule.IpRanges = []*ec2ext.IpRange{{CidrIp: cidr, Description: r.Description}}
+			rules = append(rules, &rule)
+		}
+		for _, r := range perm.Ipv6Ranges {
+			cidr, err := canonicalCIDR(r.CidrIpv6)
+			if err != nil {
+				return nil, err
+			}
+			rule := base
+			rule.Ipv6Ranges = []*ec2ext.Ipv6Range{{CidrIpv6: cidr, Description: r.Description}}
+			rules = append(rules, &rule)
+		}
+		for _, list := range perm.PrefixListIds {
+			rule := base
+			rule.PrefixListIds = []*ec2ext.PrefixListId{{PrefixListId: list.PrefixListId, Description: list.Description}}
+			rules = append(rules, &rule)
+		}
+		for _, pair := range perm.UserIdGroupPairs {
+			peer := *pair
+			if owner := aws.StringValue(peer.UserId); owner == "" || owner == s.Account {
+				sg, err := s.lookupGroup(peer.GroupId, peer.GroupName)
+				if err != nil {
+					return nil, err
+				}
+				peer.GroupId, peer.UserId = aws.String(sg.id), aws.String(sg.owner)
+				if sg.vpcID != "" {
+					peer.GroupName = nil
+				}
+			}
+			rule := base
+			rule.UserIdGroupPairs = []*ec2ext.UserIdGroupPair{&peer}
+			rules = append(rules, &rule)
+		}
+	}
+	return rules, nil
+}
+
+// canonicalCIDR - the cidr as ec2 keeps it, its network in lower case
+func canonicalCIDR(cidr *string) (*string, error) {
+	_, network, err := net.ParseCIDR(aws.StringValue(cidr))
+	if err != nil {
+		return nil, newError("InvalidParameterValue", "CIDR block %s is malformed", aws.StringValue(cidr))
+	}
+	return aws.String(network.String()), nil
+}
+
+// checkVersion - reject what a request's older api version doesn't know: the
+// description updates, ipv6 ranges and rule descriptions
+func checkVersion(action string, form url.Values) error {
+	version := form.Get("Version")
+	if version >= ec2ext.APIVersion {
+		return nil
+	}
+	switch action {
+	case ec2ext.UpdateIngressDescriptions, ec2ext.UpdateEgressDescriptions:
+		return newError("InvalidAction", "The action %s is not valid for this web service (api version %s)", action, version)
+	}
+	for key := range form {
+		if strings.HasPrefix(key, "IpPermissions.") && (strings.Contains(key, ".Ipv6Ranges.") || strings.HasSuffix(key, ".Description")) {
+			return newError("UnknownParameter", "The parameter %s is not recognized (api version %s)", key, version)
+		}
+	}
+	return nil
+}
+
+// legacyGroups - the groups as an older api version describes them, without
+// ipv6 ranges and rule descriptions
+func legacyGroups(out *ec2ext.DescribeSecurityGroupsOutput) *ec2ext.DescribeSecurityGroupsOutput {
+	legacy := &ec2ext.DescribeSecurityGroupsOutput{SecurityGroups: []*ec2ext.SecurityGroup{}}
+	strip := func(perms []*ec2ext.IpPermission) []*ec2ext.IpPermission {
+		stripped := []*ec2ext.IpPermission{}
+		for _, perm := range perms {
+			p := &ec2ext.IpPermission{IpProtocol: perm.IpProtocol, FromPort: perm.FromPort, ToPort: perm.ToPort}
+			for _, r := range perm.IpRanges {
+				p.IpRanges = append(p.IpRanges, &ec2ext.IpRange{CidrIp: r.CidrIp})
+			}
+			for _, list := range perm.PrefixListIds {
+				p.PrefixListIds = append(p.PrefixListIds, &ec2ext.PrefixListId{PrefixListId: list.PrefixListId})
+			}
+			for _, pair := range perm.UserIdGroupPairs {
+				peer := *pair
+				peer.Description = nil
+				p.UserIdGroupPairs = append(p.UserIdGroupPairs, &peer)
+			}
+			stripped = append(stripped, p)
+		}
+		return stripped
+	}
+	for _, sg := range out.SecurityGroups {
+		group := *sg
+		group.IpPermissions, group.IpPermissionsEgress = strip(sg.IpPermissions), strip(sg.IpPermissionsEgress)
+		legacy.SecurityGroups = append(legacy.SecurityGroups, &group)
+	}
+	return legacy
+}
+
+// protocolNames - the protocol numbers ec2 describes by name
+var protocolNames = map[string]string{"1": "icmp", "6": "tcp", "17": "udp", "58": "icmpv6"}
+
+// ruleString - describe a single rule for error messages
+func ruleString(rule *ec2ext.IpPermission) string {
+	return fmt.Sprintf("peer: %s, %s, from port: %d, to port: %d, ALLOW",
+		rulePeer(rule), strings.ToUpper(aws.StringValue(rule.IpProtocol)), aws.Int64Value(rule.FromPort), aws.Int64Value(rule.ToPort))
+}
+
+// rulePeer - the cidr, prefix list or group of a single rule
+func rulePeer(rule *ec2ext.IpPermission) string {
+	switch {
+	case len(rule.IpRanges) > 0:
+		return aws.StringValue(rule.IpRanges[0].CidrIp)
+	case len(rule.Ipv6Ranges) > 0:
+		return aws.StringValue(rule.Ipv6Ranges[0].CidrIpv6)
+	case len(rule.PrefixListIds) > 0:
+		return aws.StringValue(rule.PrefixListIds[0].PrefixListId)
+	case len(rule.UserIdGroupPairs) > 0:
+		pair := rule.UserIdGroupPairs[0]
+		return aws.StringValue(pair.GroupId) + aws.StringValue(pair.GroupName)
+	}
+	return ""
+}
+
+// ruleKey - identity of a single rule, its description aside
+func ruleKey(rule *ec2ext.IpPermission) string {
+	return fmt.Sprintf("%s|%s|%s|%s", aws.StringValue(rule.IpProtocol), portString(rule.FromPort), portString(rule.ToPort), rulePeer(rule))
+}
+
+// portString -
+func portString(port *int64) string {
+	if port == nil {
+		return ""
+	}
+	return fmt.Sprintf("%d", *port)
+}
+
+// indexOfRule -
+func indexOfRule(rules []*ec2ext.IpPermission, rule *ec2ext.IpPermission) int {
+	key := ruleKey(rule)
+	for pos, r := range rules {
+		if ruleKey(r) == key {
+			return pos
+		}
+	}
+	return -1
+}
+
+// mergePermissions - group single rules by protocol and port range like ec2 does
+func mergePermissions(rules []*ec2ext.IpPermission) []*ec2ext.IpPermission {
+	merged := []*ec2ext.IpPermission{}
+	byRange := make(map[string]*ec2ext.IpPermission)
+	for _, rule := range rules {
+		key := fmt.Sprintf("%s|%s|%s", aws.StringValue(rule.IpProtocol), portString(rule.FromPort), portString(rule.ToPort))
+		perm, ok := byRange[key]
+		if !ok {
+			perm = &ec2ext.IpPermission{
+				IpProtocol:       rule.IpProtocol,
+				FromPort:         rule.FromPort,
+				ToPort:           rule.ToPort,
+				IpRanges:         []*ec2ext.IpRange{},
+				Ipv6Ranges:       []*ec2ext.Ipv6Range{},
+				PrefixListIds:    []*ec2ext.PrefixListId{},
+				UserIdGroupPairs: []*ec2ext.UserIdGroupPair{},
+			}
+			byRange[key] = perm
+			merged = append(merged, perm)
+		}
+		perm.IpRanges = append(perm.IpRanges, rule.IpRanges...)
+		perm.Ipv6Ranges = append(perm.Ipv6Ranges, rule.Ipv6Ranges...)
+		perm.PrefixListIds = append(perm.PrefixListIds, rule.PrefixListIds...)
+		perm.UserIdGroupPairs = append(perm.UserIdGroupPairs, rule.UserIdGroupPairs...)
+	}
+	return merged
+}
+
+// matchFilters - ec2 filter semantics: every filter must match, any value of a filter may
+// match, values support the * and ? wildcards
+func matchFilters(filters []*ec2.Filter, fields map[string]string, tags map[string]string) bool {
+	for _, filter := range filters {
+		name := aws.StringValue(filter.Name)
+		values := aws.StringValueSlice(filter.Values)
+
+		var candidates []string
+		switch {
+		case strings.HasPrefix(name, "tag:"):
+			if v, ok := tags[strings.TrimPrefix(name, "tag:")]; ok {
+				candidates = []string{v}
+			}
+		case name == "tag-key":
+			candidates = sortedTagKeys(tags)
+		case name == "tag-value":
+			for _, k := range sortedTagKeys(tags) {
+				candidates = append(candidates, tags[k])
+			}
+		default:
+			v, ok := fields[name]
+			if !ok {
+				return false
+			}
+			candidates = []string{v}
+		}
+
+		if !matchAny(candidates, values) {
+			return false
+		}
+	}
+	return true
+}
+
+// matchAny -
+func matchAny(candidates, patterns []string) bool {
+	for _, candidate := range candidates {
+		for _, pattern := range patterns {
+			if ok, _ := path.Match(pattern, candidate); ok {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// contains -
+func contains(list []string, item string) bool {
+	for _, v := range list {
+		if v == item {
+			return true
+		}
+	}
+	return false
+}
+
+// sortedTagKeys -
+func sortedTagKeys(tags map[string]string) []string {
+	keys := make([]string, 0, len(tags))
+	for k := range tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
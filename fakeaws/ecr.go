@@ -0,0 +1,98 @@
+// Package fakeaws -
+package fakeaws
+
+import (
+	"encoding/base64"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ecr"
+)
+
+// RegistryPassword - password encoded in the authorization tokens the fake server hands out
+const RegistryPassword = "fakeaws-password"
+
+// ecrActions - ecr json actions the fake server implements
+var ecrActions = map[string]interface{}{
+	"GetAuthorizationToken": (*Server).getAuthorizationToken,
+	"DescribeRepositories":  (*Server).describeRepositories,
+}
+
+// ecrState -
+type ecrState struct {
+	repositories map[string]*ecr.Repository
+}
+
+// newECRState -
+func newECRState() *ecrState {
+	return &ecrState{repositories: make(map[string]*ecr.Repository)}
+}
+
+// CreateRepository - add a repository to the server's registry and return its uri
+func (s *Server) CreateRepository(name string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	uri := fmt.Sprintf("%s/%s", s.registryHost(s.Account), name)
+	s.ecr.repositories[name] = &ecr.Repository{
+		RegistryId:     aws.String(s.Account),
+		RepositoryName: aws.String(name),
+		RepositoryArn:  aws.String(fmt.Sprintf("arn:aws:ecr:%s:%s:repository/%s", s.Region, s.Account, name)),
+		RepositoryUri:  aws.String(uri),
+	}
+	return uri
+}
+
+// registryHost -
+func (s *Server) registryHost(registryID string) string {
+	return fmt.Sprintf("%s.dkr.ecr.%s.amazonaws.com", registryID, s.Region)
+}
+
+// getAuthorizationToken - one token per requested registry, the caller's account by default
+func (s *Server) getAuthorizationToken(in *ecr.GetAuthorizationTokenInput) (*ecr.GetAuthorizationTokenOutput, error) {
+	registries := aws.StringValueSlice(in.RegistryIds)
+	if len(registries) == 0 {
+		registries = []string{s.Account}
+	}
+
+	token := base64.StdEncoding.EncodeToString([]byte("AWS:" + RegistryPassword))
+	expires := time.Now().Add(12 * time.Hour).Truncate(time.Second)
+	out := &ecr.GetAuthorizationTokenOutput{}
+	for _, registry := range registries {
+		out.AuthorizationData = append(out.AuthorizationData, &ecr.AuthorizationData{
+			AuthorizationToken: aws.String(token),
+			ExpiresAt:          aws.Time(expires),
+			ProxyEndpoint:      aws.String("https://" + s.registryHost(registry)),
+		})
+	}
+	return out, nil
+}
+
+// describeRepositories -
+func (s *Server) describeRepositories(in *ecr.DescribeRepositoriesInput) (*ecr.DescribeRepositoriesOutput, error) {
+	out := &ecr.DescribeRepositoriesOutput{Repositories: []*ecr.Repository{}}
+	names := aws.StringValueSlice(in.RepositoryNames)
+	for _, name := range names {
+		repo, ok := s.ecr.repositories[name]
+		if !ok {
+			return nil, newError("RepositoryNotFoundException",
+				"The repository with name '%s' does not exist in the registry with id '%s'", name, s.Account)
+		}
+		out.Repositories = append(out.Repositories, repo)
+	}
+	if len(names) == 0 {
+		for _, name := range sortedRepositoryNames(s.ecr.repositories) {
+			out.Repositories = append(out.Repositories, s.ecr.repositories[name])
+		}
+	}
+	return out, nil
+}
+
+// sortedRepositoryNames -
+func sortedRepositoryNames(repositories map[string]*ecr.Repository) []string {
+	names := make(map[string]string, len(repositories))
+	for name := range repositories {
+		names[name] = ""
+	}
+	return sortedTagKeys(names)
+}
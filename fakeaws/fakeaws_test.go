@@ -0,0 +1,273 @@
+package fakeaws_test
+
+import (
+	"bytes"
+	"encoding/base64"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/ecr"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3manager"
+	"github.com/aws/aws-sdk-go/service/sqs"
+
+	"github.com/aidevops/awscli/ec2ext"
+	"github.com/aidevops/awscli/fakeaws"
+)
+
+// errorCode - the aws error code of err, empty when err is not an aws error
+func errorCode(err error) string {
+	if aerr, ok := err.(awserr.Error); ok {
+		return aerr.Code()
+	}
+	return ""
+}
+
+var _ = Describe("Server", func() {
+
+	var srv *fakeaws.Server
+
+	BeforeEach(func() {
+		srv = fakeaws.New()
+	})
+
+	AfterEach(func() {
+		srv.Close()
+	})
+
+	Describe("EC2", func() {
+		var svc *ec2.EC2
+
+		BeforeEach(func() {
+			cli := srv.AwsCli()
+			svc = ec2.New(cli.Session(), cli.ServiceConfig(ec2.ServiceName))
+		})
+
+		It("Creates tags and records the call", func() {
+			_, err := svc.CreateTags(&ec2.CreateTagsInput{
+				Resources: []*string{aws.String("i-1234")},
+				Tags:      []*ec2.Tag{{Key: aws.String("Name"), Value: aws.String("web")}},
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(srv.Tags("i-1234")).To(Equal(map[string]string{"Name": "web"}))
+
+			calls := srv.Calls("CreateTags")
+			Expect(calls).To(HaveLen(1))
+			Expect(aws.StringValue(calls[0].Input.(*ec2.CreateTagsInput).Resources[0])).To(Equal("i-1234"))
+		})
+
+		It("Returns queued failures once", func() {
+			srv.Fail("CreateTags", "UnauthorizedOperation", "not allowed")
+			input := &ec2.CreateTagsInput{
+				Resources: []*string{aws.String("i-1234")},
+				Tags:      []*ec2.Tag{{Key: aws.String("Name"), Value: aws.String("web")}},
+			}
+			_, err := svc.CreateTags(input)
+			Expect(errorCode(err)).To(Equal("UnauthorizedOperation"))
+			_, err = svc.CreateTags(input)
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("Honours DryRun", func() {
+			_, err := svc.CreateTags(&ec2.CreateTagsInput{
+				DryRun:    aws.Bool(true),
+				Resources: []*string{aws.String("i-1234")},
+				Tags:      []*ec2.Tag{{Key: aws.String("Name"), Value: aws.String("web")}},
+			})
+			Expect(errorCode(err)).To(Equal("DryRunOperation"))
+			Expect(srv.Tags("i-1234")).To(BeEmpty())
+		})
+
+		It("Authorizes, describes and revokes ingress", func() {
+			id := srv.CreateSecurityGroup("web", "vpc-1")
+			srv.CreateSecurityGroup("db", "vpc-2")
+
+			rule := &ec2.AuthorizeSecurityGroupIngressInput{
+				GroupId: aws.String(id), IpProtocol: aws.String("tcp"),
+				FromPort: aws.Int64(443), ToPort: aws.Int64(443), CidrIp: aws.String("10.0.0.1/32"),
+			}
+			_, err := svc.AuthorizeSecurityGroupIngress(rule)
+			Expect(err).NotTo(HaveOccurred())
+			_, err = svc.AuthorizeSecurityGroupIngress(rule)
+			Expect(errorCode(err)).To(Equal("InvalidPermission.Duplicate"))
+
+			resp, err := svc.DescribeSecurityGroups(&ec2.DescribeSecurityGroupsInput{
+				Filters: []*ec2.Filter{
+					{Name: aws.String("group-name"), Values: []*string{aws.String("w*")}},
+					{Name: aws.String("vpc-id"), Values: []*string{aws.String("vpc-1")}},
+				},
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(resp.SecurityGroups).To(HaveLen(1))
+			perms := resp.SecurityGroups[0].IpPermissions
+			Expect(perms).To(HaveLen(1))
+			Expect(aws.Int64Value(perms[0].FromPort)).To(Equal(int64(443)))
+			Expect(aws.StringValue(perms[0].IpRanges[0].CidrIp)).To(Equal("10.0.0.1/32"))
+
+			_, err = svc.RevokeSecurityGroupIngress(&ec2.RevokeSecurityGroupIngressInput{
+				GroupName: aws.String("web"), IpProtocol: aws.String("tcp"),
+				FromPort: aws.Int64(443), ToPort: aws.Int64(443), CidrIp: aws.String("10.0.0.1/32"),
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(srv.SecurityGroup(id).IpPermissions).To(BeEmpty())
+
+			_, err = svc.RevokeSecurityGroupIngress(&ec2.RevokeSecurityGroupIngressInput{
+				GroupId: aws.String(id), IpProtocol: aws.String("tcp"),
+				FromPort: aws.Int64(443), ToPort: aws.Int64(443), CidrIp: aws.String("10.0.0.1/32"),
+			})
+			Expect(errorCode(err)).To(Equal("InvalidPermission.NotFound"))
+		})
+
+		It("Takes and describes rule descriptions and ipv6 from the 2016-11-15 api on", func() {
+			id := srv.CreateSecurityGroup("web", "vpc-1")
+			rules := &ec2ext.RulesInput{GroupId: aws.String(id), IpPermissions: []*ec2ext.IpPermission{{
+				IpProtocol: aws.String("tcp"), FromPort: aws.Int64(22), ToPort: aws.Int64(22),
+				IpRanges:   []*ec2ext.IpRange{{CidrIp: aws.String("10.0.0.1/32"), Description: aws.String("ssh")}},
+				Ipv6Ranges: []*ec2ext.Ipv6Range{{CidrIpv6: aws.String("2001:db8::/32")}},
+			}}}
+
+			req := ec2ext.RulesRequest(svc, ec2ext.AuthorizeIngress, rules)
+			Expect(req.ClientInfo.APIVersion).To(Equal(ec2ext.APIVersion))
+			req.ClientInfo.APIVersion = "2015-10-01"
+			Expect(errorCode(req.Send())).To(Equal("UnknownParameter"))
+			req = ec2ext.RulesRequest(svc, ec2ext.UpdateIngressDescriptions, rules)
+			req.ClientInfo.APIVersion = "2015-10-01"
+			Expect(errorCode(req.Send())).To(Equal("InvalidAction"))
+			Expect(ec2ext.RulesRequest(svc, ec2ext.AuthorizeIngress, rules).Send()).To(Succeed())
+
+			req, out := ec2ext.DescribeSecurityGroupsRequest(svc, nil)
+			Expect(req.ClientInfo.APIVersion).To(Equal(ec2ext.APIVersion))
+			Expect(req.Send()).To(Succeed())
+			perm := out.SecurityGroups[0].IpPermissions[0]
+			Expect(aws.StringValue(perm.IpRanges[0].Description)).To(Equal("ssh"))
+			Expect(perm.Ipv6Ranges).To(HaveLen(1))
+
+			req, out = ec2ext.DescribeSecurityGroupsRequest(svc, nil)
+			req.ClientInfo.APIVersion = "2015-10-01"
+			Expect(req.Send()).To(Succeed())
+			perm = out.SecurityGroups[0].IpPermissions[0]
+			Expect(perm.IpRanges[0].Description).To(BeNil())
+			Expect(perm.Ipv6Ranges).To(BeEmpty())
+			Expect(aws.StringValue(srv.SecurityGroup(id).IpPermissions[0].IpRanges[0].Description)).To(Equal("ssh"))
+		})
+
+		It("Rejects unknown groups", func() {
+			_, err := svc.DescribeSecurityGroups(&ec2.DescribeSecurityGroupsInput{GroupIds: []*string{aws.String("sg-missing")}})
+			Expect(errorCode(err)).To(Equal("InvalidGroup.NotFound"))
+		})
+	})
+
+	Describe("ECR", func() {
+		It("Hands out authorization tokens", func() {
+			cli := srv.AwsCli()
+			svc := ecr.New(cli.Session(), cli.ServiceConfig(ecr.ServiceName))
+			resp, err := svc.GetAuthorizationToken(&ecr.GetAuthorizationTokenInput{})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(resp.AuthorizationData).To(HaveLen(1))
+
+			token, err := base64.StdEncoding.DecodeString(aws.StringValue(resp.AuthorizationData[0].AuthorizationToken))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(token)).To(Equal("AWS:" + fakeaws.RegistryPassword))
+			Expect(aws.StringValue(resp.AuthorizationData[0].ProxyEndpoint)).To(ContainSubstring(fakeaws.DefaultAccount))
+		})
+	})
+
+	Describe("SQS", func() {
+		var svc *sqs.SQS
+
+		BeforeEach(func() {
+			cli := srv.AwsCli()
+			svc = sqs.New(cli.Session(), cli.ServiceConfig(sqs.ServiceName))
+		})
+
+		It("Sends and receives messages", func() {
+			srv.CreateQueue("jobs")
+			url, err := svc.GetQueueUrl(&sqs.GetQueueUrlInput{QueueName: aws.String("jobs")})
+			Expect(err).NotTo(HaveOccurred())
+
+			_, err = svc.SendMessage(&sqs.SendMessageInput{
+				QueueUrl:    url.QueueUrl,
+				MessageBody: aws.String("hello"),
+				MessageAttributes: map[string]*sqs.MessageAttributeValue{
+					"node": {DataType: aws.String("String"), StringValue: aws.String("n1")},
+				},
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(srv.Messages("jobs")).To(HaveLen(1))
+
+			resp, err := svc.ReceiveMessage(&sqs.ReceiveMessageInput{
+				QueueUrl:              url.QueueUrl,
+				MessageAttributeNames: []*string{aws.String("node")},
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(resp.Messages).To(HaveLen(1))
+			Expect(aws.StringValue(resp.Messages[0].Body)).To(Equal("hello"))
+			Expect(aws.StringValue(resp.Messages[0].MessageAttributes["node"].StringValue)).To(Equal("n1"))
+
+			resp, err = svc.ReceiveMessage(&sqs.ReceiveMessageInput{QueueUrl: url.QueueUrl})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(resp.Messages).To(BeEmpty())
+		})
+
+		It("Fails on unknown queues", func() {
+			_, err := svc.GetQueueUrl(&sqs.GetQueueUrlInput{QueueName: aws.String("missing")})
+			Expect(errorCode(err)).To(Equal("AWS.SimpleQueueService.NonExistentQueue"))
+		})
+	})
+
+	Describe("S3", func() {
+		var svc *s3.S3
+
+		BeforeEach(func() {
+			cli := srv.AwsCli()
+			svc = s3.New(cli.Session(), cli.ServiceConfig(s3.ServiceName))
+			srv.CreateBucket("bucket")
+		})
+
+		It("Puts and gets objects", func() {
+			_, err := s3manager.NewUploaderWithClient(svc).Upload(&s3manager.UploadInput{
+				Bucket: aws.String("bucket"), Key: aws.String("dir/small"), Body: bytes.NewReader([]byte("data")),
+			})
+			Expect(err).NotTo(HaveOccurred())
+			body, ok := srv.Object("bucket", "dir/small")
+			Expect(ok).To(BeTrue())
+			Expect(string(body)).To(Equal("data"))
+
+			buf := &aws.WriteAtBuffer{}
+			n, err := s3manager.NewDownloaderWithClient(svc).Download(buf, &s3.GetObjectInput{
+				Bucket: aws.String("bucket"), Key: aws.String("dir/small"),
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(n).To(Equal(int64(4)))
+			Expect(string(buf.Bytes())).To(Equal("data"))
+		})
+
+		It("Assembles multipart uploads and ranged downloads", func() {
+			data := bytes.Repeat([]byte("0123456789abcdef"), 11*1024*1024/16)
+			_, err := s3manager.NewUploaderWithClient(svc).Upload(&s3manager.UploadInput{
+				Bucket: aws.String("bucket"), Key: aws.String("large"), Body: bytes.NewReader(data),
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(srv.CallCount("UploadPart")).To(Equal(3))
+			Expect(srv.Uploads()).To(Equal(0))
+
+			buf := &aws.WriteAtBuffer{}
+			_, err = s3manager.NewDownloaderWithClient(svc).Download(buf, &s3.GetObjectInput{
+				Bucket: aws.String("bucket"), Key: aws.String("large"),
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(bytes.Equal(buf.Bytes(), data)).To(BeTrue())
+		})
+
+		It("Reports missing keys and buckets", func() {
+			_, err := svc.GetObject(&s3.GetObjectInput{Bucket: aws.String("bucket"), Key: aws.String("missing")})
+			Expect(errorCode(err)).To(Equal("NoSuchKey"))
+			_, err = svc.GetObject(&s3.GetObjectInput{Bucket: aws.String("nobucket"), Key: aws.String("key")})
+			Expect(errorCode(err)).To(Equal("NoSuchBucket"))
+		})
+	})
+})
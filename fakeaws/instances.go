@@ -0,0 +1,382 @@
+package fakeaws
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+)
+
+// AddInstance - add an instance and return its id. Missing ids, state, type, zone
+// and launch time are filled in, the instance's Tags are stored like CreateTags'.
+func (s *Server) AddInstance(instance *ec2.Instance) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	in := *instance
+	if aws.StringValue(in.InstanceId) == "" {
+		in.InstanceId = aws.String(fmt.Sprintf("i-%08x", s.nextID()))
+	}
+	if in.State == nil {
+		in.State = &ec2.InstanceState{Code: aws.Int64(instanceStateCodes[ec2.InstanceStateNameRunning]), Name: aws.String(ec2.InstanceStateNameRunning)}
+	}
+	if in.InstanceType == nil {
+		in.InstanceType = aws.String(ec2.InstanceTypeT2Micro)
+	}
+	if in.Placement == nil {
+		in.Placement = &ec2.Placement{AvailabilityZone: aws.String(s.Region + "a")}
+	}
+	if in.LaunchTime == nil {
+		in.LaunchTime = aws.Time(time.Date(2016, 1, 1, 0, 0, 0, 0, time.UTC))
+	}
+	id := *in.InstanceId
+	s.setTags(id, in.Tags)
+	in.Tags = nil
+	s.ec2.instances[id] = &in
+	return id
+}
+
+// Instance - return the described instance, nil if it does not exist
+func (s *Server) Instance(id string) *ec2.Instance {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.ec2.instances[id]; !ok {
+		return nil
+	}
+	return s.describeInstance(id)
+}
+
+// instanceStateCodes - the codes ec2 reports with each state name
+var instanceStateCodes = map[string]int64{
+	ec2.InstanceStateNamePending:      0,
+	ec2.InstanceStateNameRunning:      16,
+	ec2.InstanceStateNameShuttingDown: 32,
+	ec2.InstanceStateNameTerminated:   48,
+	ec2.InstanceStateNameStopping:     64,
+	ec2.InstanceStateNameStopped:      80,
+}
+
+// SetInstanceState - move an instance to the named state, e.g. ec2.InstanceStateNameStopped
+func (s *Server) SetInstanceState(id, name string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if instance, ok := s.ec2.instances[id]; ok {
+		instance.State = &ec2.InstanceState{Code: aws.Int64(instanceStateCodes[name]), Name: aws.String(name)}
+	}
+}
+
+// AddVolume - add a volume and return its id, missing ids, state and zone are filled in
+func (s *Server) AddVolume(volume *ec2.Volume) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	vol := *volume
+	if aws.StringValue(vol.VolumeId) == "" {
+		vol.VolumeId = aws.String(fmt.Sprintf("vol-%08x", s.nextID()))
+	}
+	if vol.State == nil {
+		vol.State = aws.String(ec2.VolumeStateAvailable)
+		if len(vol.Attachments) > 0 {
+			vol.State = aws.String(ec2.VolumeStateInUse)
+		}
+	}
+	if vol.AvailabilityZone == nil {
+		vol.AvailabilityZone = aws.String(s.Region + "a")
+	}
+	id := *vol.VolumeId
+	s.setTags(id, vol.Tags)
+	vol.Tags = nil
+	s.ec2.volumes[id] = &vol
+	return id
+}
+
+// AddSnapshot - add a snapshot and return its id, missing ids, state and owner are filled in
+func (s *Server) AddSnapshot(snapshot *ec2.Snapshot) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	snap := *snapshot
+	if aws.StringValue(snap.SnapshotId) == "" {
+		snap.SnapshotId = aws.String(fmt.Sprintf("snap-%08x", s.nextID()))
+	}
+	if snap.State == nil {
+		snap.State = aws.String(ec2.SnapshotStateCompleted)
+	}
+	if snap.OwnerId == nil {
+		snap.OwnerId = aws.String(s.Account)
+	}
+	id := *snap.SnapshotId
+	s.setTags(id, snap.Tags)
+	snap.Tags = nil
+	s.ec2.snapshots[id] = &snap
+	return id
+}
+
+// setTags - merge tags into the resource's, the caller holds mu
+func (s *Server) setTags(id string, tags []*ec2.Tag) {
+	if len(tags) == 0 {
+		return
+	}
+	if s.ec2.tags[id] == nil {
+		s.ec2.tags[id] = make(map[string]string)
+	}
+	for _, tag := range tags {
+		s.ec2.tags[id][aws.StringValue(tag.Key)] = aws.StringValue(tag.Value)
+	}
+}
+
+// ec2Tags - the resource's tags as ec2.Tag sorted by key, the caller holds mu
+func (s *Server) ec2Tags(id string) []*ec2.Tag {
+	var tags []*ec2.Tag
+	for _, k := range sortedTagKeys(s.ec2.tags[id]) {
+		tags = append(tags, &ec2.Tag{Key: aws.String(k), Value: aws.String(s.ec2.tags[id][k])})
+	}
+	return tags
+}
+
+// describeInstance - a copy of the instance with its tags, the caller holds mu
+func (s *Server) describeInstance(id string) *ec2.Instance {
+	in := *s.ec2.instances[id]
+	state := *in.State
+	in.State = &state
+	in.Tags = s.ec2Tags(id)
+	return &in
+}
+
+// instanceFields - the filterable fields of an instance
+func instanceFields(in *ec2.Instance) map[string]string {
+	return map[string]string{
+		"instance-id":         aws.StringValue(in.InstanceId),
+		"instance-state-name": aws.StringValue(in.State.Name),
+		"instance-state-code": fmt.Sprintf("%d", aws.Int64Value(in.State.Code)),
+		"instance-type":       aws.StringValue(in.InstanceType),
+		"availability-zone":   aws.StringValue(in.Placement.AvailabilityZone),
+		"vpc-id":              aws.StringValue(in.VpcId),
+		"subnet-id":           aws.StringValue(in.SubnetId),
+		"image-id":            aws.StringValue(in.ImageId),
+		"key-name":            aws.StringValue(in.KeyName),
+		"private-ip-address":  aws.StringValue(in.PrivateIpAddress),
+		"ip-address":          aws.StringValue(in.PublicIpAddress),
+	}
+}
+
+// describeInstances - one reservation per instance
+func (s *Server) describeInstances(in *ec2.DescribeInstancesInput) (*ec2.DescribeInstancesOutput, error) {
+	ids := aws.StringValueSlice(in.InstanceIds)
+	for _, id := range ids {
+		if _, ok := s.ec2.instances[id]; !ok {
+			return nil, newError("InvalidInstanceID.NotFound", "The instance ID '%s' does not exist", id)
+		}
+	}
+
+	var all []string
+	for id := range s.ec2.instances {
+		all = append(all, id)
+	}
+	sort.Strings(all)
+
+	var matched []*ec2.Reservation
+	for _, id := range all {
+		if len(ids) > 0 && !contains(ids, id) {
+			continue
+		}
+		instance := s.ec2.instances[id]
+		if !matchFilters(in.Filters, instanceFields(instance), s.ec2.tags[id]) {
+			continue
+		}
+		matched = append(matched, &ec2.Reservation{
+			ReservationId: aws.String("r-" + id[2:]),
+			OwnerId:       aws.String(s.Account),
+			Instances:     []*ec2.Instance{s.describeInstance(id)},
+		})
+	}
+
+	start, end, next, err := s.page(len(matched), in.NextToken, in.MaxResults)
+	if err != nil {
+		return nil, err
+	}
+	return &ec2.DescribeInstancesOutput{Reservations: append([]*ec2.Reservation{}, matched[start:end]...), NextToken: next}, nil
+}
+
+// describeVolumes -
+func (s *Server) describeVolumes(in *ec2.DescribeVolumesInput) (*ec2.DescribeVolumesOutput, error) {
+	ids := aws.StringValueSlice(in.VolumeIds)
+	for _, id := range ids {
+		if _, ok := s.ec2.volumes[id]; !ok {
+			return nil, newError("InvalidVolume.NotFound", "The volume '%s' does not exist.", id)
+		}
+	}
+
+	var all []string
+	for id := range s.ec2.volumes {
+		all = append(all, id)
+	}
+	sort.Strings(all)
+
+	var matched []*ec2.Volume
+	for _, id := range all {
+		if len(ids) > 0 && !contains(ids, id) {
+			continue
+		}
+		vol := *s.ec2.volumes[id]
+		fields := map[string]string{
+			"volume-id":         id,
+			"status":            aws.StringValue(vol.State),
+			"availability-zone": aws.StringValue(vol.AvailabilityZone),
+			"volume-type":       aws.StringValue(vol.VolumeType),
+			"size":              fmt.Sprintf("%d", aws.Int64Value(vol.Size)),
+			"snapshot-id":       aws.StringValue(vol.SnapshotId),
+		}
+		for _, attachment := range vol.Attachments {
+			fields["attachment.instance-id"] = aws.StringValue(attachment.InstanceId)
+			fields["attachment.device"] = aws.StringValue(attachment.Device)
+		}
+		if !matchFilters(in.Filters, fields, s.ec2.tags[id]) {
+			continue
+		}
+		vol.Tags = s.ec2Tags(id)
+		matched = append(matched, &vol)
+	}
+
+	start, end, next, err := s.page(len(matched), in.NextToken, in.MaxResults)
+	if err != nil {
+		return nil, err
+	}
+	return &ec2.DescribeVolumesOutput{Volumes: append([]*ec2.Volume{}, matched[start:end]...), NextToken: next}, nil
+}
+
+// describeSnapshots - OwnerIds takes account ids and 'self'
+func (s *Server) describeSnapshots(in *ec2.DescribeSnapshotsInput) (*ec2.DescribeSnapshotsOutput, error) {
+	ids := aws.StringValueSlice(in.SnapshotIds)
+	for _, id := range ids {
+		if _, ok := s.ec2.snapshots[id]; !ok {
+			return nil, newError("InvalidSnapshot.NotFound", "The snapshot '%s' does not exist.", id)
+		}
+	}
+	var owners []string
+	for _, owner := range aws.StringValueSlice(in.OwnerIds) {
+		if owner == "self" {
+			owner = s.Account
+		}
+		owners = append(owners, owner)
+	}
+
+	var all []string
+	for id := range s.ec2.snapshots {
+		all = append(all, id)
+	}
+	sort.Strings(all)
+
+	var matched []*ec2.Snapshot
+	for _, id := range all {
+		snap := *s.ec2.snapshots[id]
+		if len(ids) > 0 && !contains(ids, id) || len(owners) > 0 && !contains(owners, aws.StringValue(snap.OwnerId)) {
+			continue
+		}
+		fields := map[string]string{
+			"snapshot-id": id,
+			"volume-id":   aws.StringValue(snap.VolumeId),
+			"status":      aws.StringValue(snap.State),
+			"owner-id":    aws.StringValue(snap.OwnerId),
+			"description": aws.StringValue(snap.Description),
+		}
+		if !matchFilters(in.Filters, fields, s.ec2.tags[id]) {
+			continue
+		}
+		snap.Tags = s.ec2Tags(id)
+		matched = append(matched, &snap)
+	}
+
+	start, end, next, err := s.page(len(matched), in.NextToken, in.MaxResults)
+	if err != nil {
+		return nil, err
+	}
+	return &ec2.DescribeSnapshotsOutput{Snapshots: append([]*ec2.Snapshot{}, matched[start:end]...), NextToken: next}, nil
+}
+
+// describeRegions -
+func (s *Server) describeRegions(in *ec2.DescribeRegionsInput) (*ec2.DescribeRegionsOutput, error) {
+	regions := s.Regions
+	if len(regions) == 0 {
+		regions = []string{s.Region}
+	}
+	out := &ec2.DescribeRegionsOutput{}
+	for _, region := range regions {
+		if names := aws.StringValueSlice(in.RegionNames); len(names) > 0 && !contains(names, region) {
+			continue
+		}
+		out.Regions = append(out.Regions, &ec2.Region{
+			RegionName: aws.String(region),
+			Endpoint:   aws.String("ec2." + region + ".amazonaws.com"),
+		})
+	}
+	return out, nil
+}
+
+// startInstances -
+func (s *Server) startInstances(in *ec2.StartInstancesInput) (*ec2.StartInstancesOutput, error) {
+	changes, err := s.changeStates(in.InstanceIds, ec2.InstanceStateNamePending, ec2.InstanceStateNameRunning, "started")
+	return &ec2.StartInstancesOutput{StartingInstances: changes}, err
+}
+
+// stopInstances -
+func (s *Server) stopInstances(in *ec2.StopInstancesInput) (*ec2.StopInstancesOutput, error) {
+	changes, err := s.changeStates(in.InstanceIds, ec2.InstanceStateNameStopping, ec2.InstanceStateNameStopped, "stopped")
+	return &ec2.StopInstancesOutput{StoppingInstances: changes}, err
+}
+
+// terminateInstances - terminating a terminated instance is fine, as in ec2
+func (s *Server) terminateInstances(in *ec2.TerminateInstancesInput) (*ec2.TerminateInstancesOutput, error) {
+	changes, err := s.changeStates(in.InstanceIds, ec2.InstanceStateNameShuttingDown, ec2.InstanceStateNameTerminated, "")
+	return &ec2.TerminateInstancesOutput{TerminatingInstances: changes}, err
+}
+
+// rebootInstances -
+func (s *Server) rebootInstances(in *ec2.RebootInstancesInput) (*ec2.RebootInstancesOutput, error) {
+	if err := s.checkStates(in.InstanceIds, "rebooted"); err != nil {
+		return nil, err
+	}
+	return &ec2.RebootInstancesOutput{}, nil
+}
+
+// changeStates - move the instances through the transitional state, which is
+// reported, to the final one at once.
+func (s *Server) changeStates(ids []*string, transitional, final, verb string) ([]*ec2.InstanceStateChange, error) {
+	if err := s.checkStates(ids, verb); err != nil {
+		return nil, err
+	}
+	var changes []*ec2.InstanceStateChange
+	for _, id := range aws.StringValueSlice(ids) {
+		instance := s.ec2.instances[id]
+		current := transitional
+		if aws.StringValue(instance.State.Name) == final {
+			current = final
+		}
+		changes = append(changes, &ec2.InstanceStateChange{
+			InstanceId:    aws.String(id),
+			PreviousState: instance.State,
+			CurrentState:  &ec2.InstanceState{Code: aws.Int64(instanceStateCodes[current]), Name: aws.String(current)},
+		})
+		instance.State = &ec2.InstanceState{Code: aws.Int64(instanceStateCodes[final]), Name: aws.String(final)}
+	}
+	return changes, nil
+}
+
+// checkStates - every instance exists and, unless verb is empty, isn't terminated
+func (s *Server) checkStates(ids []*string, verb string) error {
+	if len(ids) == 0 {
+		return newError("MissingParameter", "The request must contain the parameter InstanceId")
+	}
+	for _, id := range aws.StringValueSlice(ids) {
+		instance, ok := s.ec2.instances[id]
+		if !ok {
+			return newError("InvalidInstanceID.NotFound", "The instance ID '%s' does not exist", id)
+		}
+		if verb != "" && aws.StringValue(instance.State.Name) == ec2.InstanceStateNameTerminated {
+			return newError("IncorrectInstanceState", "The instance '%s' is not in a state from which it can be %s.", id, verb)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,170 @@
+package fakeaws
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultInstanceID - instance id reported by the fake metadata service
+const DefaultInstanceID = "i-0fakeaws0000001"
+
+// Metadata - fake ec2 instance metadata service, serving the identity document and
+// the meta-data paths derived from it. It hands out IMDSv2 tokens and, with
+// RequireToken, rejects requests without a valid one like an instance with IMDSv1
+// turned off.
+type Metadata struct {
+	*httptest.Server
+	InstanceID string
+	Account    string
+	Region     string
+	// Role - instance profile role served under iam/security-credentials/, none when empty
+	Role string
+
+	// Disabled - answer every request with 403 like an instance with metadata turned off
+	Disabled bool
+	// RequireToken - answer token-less requests with 401
+	RequireToken bool
+	// NoTokens - answer token requests with 404 like an IMDSv1 only service
+	NoTokens bool
+	// MaxTokenTTL - cap on the lifetime of the tokens handed out, none when zero
+	MaxTokenTTL time.Duration
+
+	mu       sync.Mutex
+	requests []string
+	tokens   map[string]time.Time
+	issued   int
+}
+
+// NewMetadata - start a new fake metadata service, Close it when done.
+// Point an AwsCli at it with -endpoint-url='ec2metadata=<URL>' or
+// $AWS_EC2_METADATA_SERVICE_ENDPOINT.
+func NewMetadata() *Metadata {
+	m := &Metadata{
+		InstanceID: DefaultInstanceID,
+		Account:    DefaultAccount,
+		Region:     DefaultRegion,
+		Role:       "fakeaws-role",
+		tokens:     make(map[string]time.Time),
+	}
+	m.Server = httptest.NewServer(m)
+	return m
+}
+
+// Requests - return the requests made so far as 'METHOD /path'
+func (m *Metadata) Requests() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]string(nil), m.requests...)
+}
+
+// TokensIssued - number of IMDSv2 tokens handed out
+func (m *Metadata) TokensIssued() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.issued
+}
+
+// ExpireTokens - invalidate every token handed out so far
+func (m *Metadata) ExpireTokens() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.tokens = make(map[string]time.Time)
+}
+
+// ServeHTTP -
+func (m *Metadata) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.requests = append(m.requests, r.Method+" "+r.URL.Path)
+
+	if m.Disabled {
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return
+	}
+
+	if r.URL.Path == "/latest/api/token" {
+		m.token(w, r)
+		return
+	}
+
+	if r.Method != "GET" {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if token := r.Header.Get("X-aws-ec2-metadata-token"); token != "" || m.RequireToken {
+		if expires, ok := m.tokens[token]; !ok || time.Now().After(expires) {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+	}
+
+	zone := m.Region + "a"
+	switch strings.TrimSuffix(r.URL.Path, "/") {
+	case "/latest/dynamic/instance-identity/document":
+		w.Header().Set("Content-Type", "text/plain")
+		json.NewEncoder(w).Encode(map[string]string{
+			"accountId":        m.Account,
+			"availabilityZone": zone,
+			"instanceId":       m.InstanceID,
+			"instanceType":     "t2.micro",
+			"privateIp":        "10.0.0.10",
+			"region":           m.Region,
+		})
+	case "/latest/meta-data/instance-id":
+		w.Write([]byte(m.InstanceID))
+	case "/latest/meta-data/placement/availability-zone":
+		w.Write([]byte(zone))
+	case "/latest/meta-data/iam/security-credentials":
+		if m.Role == "" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(m.Role))
+	case "/latest/meta-data/iam/security-credentials/" + m.Role:
+		json.NewEncoder(w).Encode(map[string]string{
+			"Code":            "Success",
+			"Type":            "AWS-HMAC",
+			"AccessKeyId":     "AKIDFAKEROLE",
+			"SecretAccessKey": "fakeaws-role-secret",
+			"Token":           "fakeaws-role-token",
+			"Expiration":      time.Now().Add(time.Hour).UTC().Format(time.RFC3339),
+		})
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// token - hand out an IMDSv2 token for the requested ttl
+func (m *Metadata) token(w http.ResponseWriter, r *http.Request) {
+	if m.NoTokens {
+		http.NotFound(w, r)
+		return
+	}
+	if r.Method != "PUT" {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	seconds, err := strconv.Atoi(r.Header.Get("X-aws-ec2-metadata-token-ttl-seconds"))
+	if err != nil || seconds < 1 || seconds > 21600 {
+		http.Error(w, "invalid token ttl", http.StatusBadRequest)
+		return
+	}
+	ttl := time.Duration(seconds) * time.Second
+	if m.MaxTokenTTL > 0 && ttl > m.MaxTokenTTL {
+		ttl = m.MaxTokenTTL
+	}
+
+	m.issued++
+	token := fmt.Sprintf("fakeaws-token-%d", m.issued)
+	m.tokens[token] = time.Now().Add(ttl)
+	w.Header().Set("X-aws-ec2-metadata-token-ttl-seconds", strconv.Itoa(int(ttl/time.Second)))
+	w.Write([]byte(token))
+}
@@ -0,0 +1,270 @@
+// Package fakeaws -
+package fakeaws
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// s3State -
+type s3State struct {
+	buckets map[string]map[string][]byte
+	uploads map[string]*upload
+}
+
+// upload - an in flight multipart upload
+type upload struct {
+	bucket string
+	key    string
+	parts  map[int64][]byte
+}
+
+// newS3State -
+func newS3State() *s3State {
+	return &s3State{
+		buckets: make(map[string]map[string][]byte),
+		uploads: make(map[string]*upload),
+	}
+}
+
+// CreateBucket - add an empty bucket
+func (s *Server) CreateBucket(bucket string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.s3.buckets[bucket]; !ok {
+		s.s3.buckets[bucket] = make(map[string][]byte)
+	}
+}
+
+// PutObject - store an object, creating the bucket when needed
+func (s *Server) PutObject(bucket, key string, body []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.s3.buckets[bucket]; !ok {
+		s.s3.buckets[bucket] = make(map[string][]byte)
+	}
+	s.s3.buckets[bucket][key] = append([]byte(nil), body...)
+}
+
+// Object - return a stored object, false when it does not exist
+func (s *Server) Object(bucket, key string) ([]byte, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	body, ok := s.s3.buckets[bucket][key]
+	return body, ok
+}
+
+// Uploads - number of multipart uploads started but not completed or aborted
+func (s *Server) Uploads() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.s3.uploads)
+}
+
+// s3Input - what a recorded s3 call looked like
+type s3Input struct {
+	Bucket string
+	Key    string
+	Query  string
+	Size   int
+}
+
+// serveS3 - path style s3 rest protocol
+func (s *Server) serveS3(w http.ResponseWriter, r *http.Request) {
+	path := strings.TrimPrefix(r.URL.Path, "/")
+	bucket, key := path, ""
+	if pos := strings.Index(path, "/"); pos >= 0 {
+		bucket, key = path[:pos], path[pos+1:]
+	}
+	query := r.URL.Query()
+
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		writeS3Error(w, r, newError("IncompleteBody", "%s", err))
+		return
+	}
+
+	var action string
+	switch {
+	case r.Method == "POST" && query["uploads"] != nil:
+		action = "CreateMultipartUpload"
+	case r.Method == "PUT" && query.Get("uploadId") != "":
+		action = "UploadPart"
+	case r.Method == "POST" && query.Get("uploadId") != "":
+		action = "CompleteMultipartUpload"
+	case r.Method == "DELETE" && query.Get("uploadId") != "":
+		action = "AbortMultipartUpload"
+	case r.Method == "PUT" && key != "":
+		action = "PutObject"
+	case r.Method == "GET" && key != "":
+		action = "GetObject"
+	case r.Method == "HEAD" && key != "":
+		action = "HeadObject"
+	default:
+		writeS3Error(w, r, &Error{Status: http.StatusNotImplemented, Code: "NotImplemented",
+			Message: fmt.Sprintf("fakeaws does not implement s3 %s %s", r.Method, r.URL)})
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.record("s3", action, &s3Input{Bucket: bucket, Key: key, Query: r.URL.RawQuery, Size: len(body)}); err != nil {
+		writeS3Error(w, r, err)
+		return
+	}
+
+	objects, ok := s.s3.buckets[bucket]
+	if !ok {
+		writeS3Error(w, r, &Error{Status: http.StatusNotFound, Code: "NoSuchBucket",
+			Message: "The specified bucket does not exist"})
+		return
+	}
+
+	switch action {
+	case "PutObject":
+		objects[key] = body
+		w.Header().Set("ETag", etag(body))
+	case "GetObject", "HeadObject":
+		s.getObject(w, r, objects, key, action == "HeadObject")
+	case "CreateMultipartUpload":
+		id := fmt.Sprintf("fakeaws-upload-%d", s.nextID())
+		s.s3.uploads[id] = &upload{bucket: bucket, key: key, parts: make(map[int64][]byte)}
+		writeS3Result(w, "InitiateMultipartUploadResult",
+			fmt.Sprintf("<Bucket>%s</Bucket><Key>%s</Key><UploadId>%s</UploadId>", escape(bucket), escape(key), id))
+	case "UploadPart":
+		u, err := s.lookupUpload(query.Get("uploadId"))
+		if err != nil {
+			writeS3Error(w, r, err)
+			return
+		}
+		num, err := strconv.ParseInt(query.Get("partNumber"), 10, 64)
+		if err != nil || num < 1 || num > 10000 {
+			writeS3Error(w, r, newError("InvalidArgument", "Part number must be an integer between 1 and 10000, inclusive"))
+			return
+		}
+		u.parts[num] = body
+		w.Header().Set("ETag", etag(body))
+	case "CompleteMultipartUpload":
+		s.completeUpload(w, r, query.Get("uploadId"), body)
+	case "AbortMultipartUpload":
+		if _, err := s.lookupUpload(query.Get("uploadId")); err != nil {
+			writeS3Error(w, r, err)
+			return
+		}
+		delete(s.s3.uploads, query.Get("uploadId"))
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// getObject - serve an object or a single byte range of it, the caller holds mu
+func (s *Server) getObject(w http.ResponseWriter, r *http.Request, objects map[string][]byte, key string, head bool) {
+	body, ok := objects[key]
+	if !ok {
+		writeS3Error(w, r, &Error{Status: http.StatusNotFound, Code: "NoSuchKey",
+			Message: "The specified key does not exist."})
+		return
+	}
+	w.Header().Set("ETag", etag(body))
+
+	status := http.StatusOK
+	if rng := r.Header.Get("Range"); rng != "" {
+		var start, end int64
+		if _, err := fmt.Sscanf(rng, "bytes=%d-%d", &start, &end); err != nil || start >= int64(len(body)) {
+			writeS3Error(w, r, &Error{Status: http.StatusRequestedRangeNotSatisfiable, Code: "InvalidRange",
+				Message: "The requested range is not satisfiable"})
+			return
+		}
+		if end >= int64(len(body)) {
+			end = int64(len(body)) - 1
+		}
+		w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", start, end, len(body)))
+		body = body[start : end+1]
+		status = http.StatusPartialContent
+	}
+
+	w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+	w.WriteHeader(status)
+	if !head {
+		w.Write(body)
+	}
+}
+
+// completeUpload - join the listed parts into the object, the caller holds mu
+func (s *Server) completeUpload(w http.ResponseWriter, r *http.Request, id string, body []byte) {
+	u, err := s.lookupUpload(id)
+	if err != nil {
+		writeS3Error(w, r, err)
+		return
+	}
+
+	var req struct {
+		Parts []struct {
+			ETag       string
+			PartNumber int64
+		} `xml:"Part"`
+	}
+	if err := xml.Unmarshal(body, &req); err != nil {
+		writeS3Error(w, r, newError("MalformedXML", "%s", err))
+		return
+	}
+	for i := 1; i < len(req.Parts); i++ {
+		if req.Parts[i-1].PartNumber >= req.Parts[i].PartNumber {
+			writeS3Error(w, r, newError("InvalidPartOrder", "The list of parts was not in ascending order."))
+			return
+		}
+	}
+
+	var object bytes.Buffer
+	for _, part := range req.Parts {
+		data, ok := u.parts[part.PartNumber]
+		if !ok || etag(data) != part.ETag {
+			writeS3Error(w, r, newError("InvalidPart", "One or more of the specified parts could not be found."))
+			return
+		}
+		object.Write(data)
+	}
+
+	s.s3.buckets[u.bucket][u.key] = object.Bytes()
+	delete(s.s3.uploads, id)
+	writeS3Result(w, "CompleteMultipartUploadResult",
+		fmt.Sprintf("<Location>%s/%s/%s</Location><Bucket>%s</Bucket><Key>%s</Key><ETag>%s</ETag>",
+			s.URL, escape(u.bucket), escape(u.key), escape(u.bucket), escape(u.key), escape(etag(object.Bytes()))))
+}
+
+// lookupUpload - the caller holds mu
+func (s *Server) lookupUpload(id string) (*upload, error) {
+	u, ok := s.s3.uploads[id]
+	if !ok {
+		return nil, &Error{Status: http.StatusNotFound, Code: "NoSuchUpload",
+			Message: "The specified upload does not exist."}
+	}
+	return u, nil
+}
+
+// writeS3Result -
+func writeS3Result(w http.ResponseWriter, root, fields string) {
+	w.Header().Set("Content-Type", "application/xml")
+	fmt.Fprintf(w, "%s<%s>%s</%s>", xml.Header, root, fields, root)
+}
+
+// writeS3Error - s3 errors carry no envelope, HEAD responses no body at all
+func writeS3Error(w http.ResponseWriter, r *http.Request, err error) {
+	e := toError(err)
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(e.Status)
+	if r.Method == "HEAD" {
+		return
+	}
+	fmt.Fprintf(w, "%s<Error><Code>%s</Code><Message>%s</Message><RequestId>fakeaws-s3</RequestId></Error>",
+		xml.Header, escape(e.Code), escape(e.Message))
+}
+
+// etag - quoted md5 of body like s3 returns for single part objects
+func etag(body []byte) string {
+	return fmt.Sprintf("\"%s\"", md5Hex(string(body)))
+}
@@ -0,0 +1,360 @@
+// Package fakeaws - in-process fake aws endpoint for offline tests.
+//
+// A Server speaks just enough of the EC2 query, ECR JSON-RPC, SQS query and S3
+// REST-XML protocols for the operations this project uses. State is kept in
+// memory and every call is recorded so specs can assert on what reached "aws".
+//
+// Point any tool at it with the endpoint override:
+//
+//     srv := fakeaws.New()
+//     defer srv.Close()
+//     svc := ec2.New(srv.AwsCli().Session(), srv.AwsCli().ServiceConfig(ec2.ServiceName))
+package fakeaws
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/private/protocol/json/jsonutil"
+
+	"github.com/aidevops/awscli"
+	"github.com/aidevops/awscli/ec2ext"
+)
+
+// DefaultAccount - account id reported by the fake server
+const DefaultAccount = "123456789012"
+
+// DefaultRegion - region reported by the fake server
+const DefaultRegion = "us-east-1"
+
+// sqsVersion - api version the sqs client sends, used to tell sqs from ec2 query requests
+const sqsVersion = "2012-11-05"
+
+// Error - an aws style error returned by a fake handler
+type Error struct {
+	Status  int
+	Code    string
+	Message string
+}
+
+// Error -
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s: %s", e.Code, e.Message)
+}
+
+// newError - return a 400 aws error
+func newError(code, format string, args ...interface{}) *Error {
+	return &Error{Status: http.StatusBadRequest, Code: code, Message: fmt.Sprintf(format, args...)}
+}
+
+// Call - a recorded api call
+type Call struct {
+	Service string
+	Action  string
+	Input   interface{}
+}
+
+// Server - fake aws endpoint
+type Server struct {
+	*httptest.Server
+	Account string
+	Region  string
+	// PageSize - items per page of paginated describe calls that set no MaxResults,
+	// everything in one page when zero
+	PageSize int
+	// Regions - the regions DescribeRegions lists, Region alone when empty
+	Regions []string
+
+	mu       sync.Mutex
+	calls    []Call
+	failures map[string][]*Error
+	ids      int
+
+	ec2 *ec2State
+	ecr *ecrState
+	sqs *sqsState
+	s3  *s3State
+}
+
+// New - start a new fake server, Close it when done
+func New() *Server {
+	s := &Server{
+		Account:  DefaultAccount,
+		Region:   DefaultRegion,
+		failures: make(map[string][]*Error),
+		ec2:      newEC2State(),
+		ecr:      newECRState(),
+		sqs:      newSQSState(),
+		s3:       newS3State(),
+	}
+	s.Server = httptest.NewServer(s)
+	return s
+}
+
+// AwsCli - return an AwsCli with static credentials pointed at the server
+func (s *Server) AwsCli() *awscli.AwsCli {
+	cli := awscli.New(0, "AKIDFAKEAWS", "fakeaws-secret", "", s.Region, s.Account)
+	cli.EndpointURL = s.URL
+	cli.S3PathStyle = true
+	return cli
+}
+
+// Fail - make the next call to action return an aws error with code and message
+func (s *Server) Fail(action, code, message string) {
+	s.FailWith(action, &Error{Status: http.StatusBadRequest, Code: code, Message: message})
+}
+
+// FailWith - make the next call to action return err, queued failures are used in order
+func (s *Server) FailWith(action string, err *Error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failures[action] = append(s.failures[action], err)
+}
+
+// Calls - return the recorded calls, all of them when action is empty
+func (s *Server) Calls(action string) []Call {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var calls []Call
+	for _, call := range s.calls {
+		if action == "" || call.Action == action {
+			calls = append(calls, call)
+		}
+	}
+	return calls
+}
+
+// CallCount - number of calls made to action
+func (s *Server) CallCount(action string) int {
+	return len(s.Calls(action))
+}
+
+// Reset - forget recorded calls and queued failures, state is kept
+func (s *Server) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.calls = nil
+	s.failures = make(map[string][]*Error)
+}
+
+// page - the [start, end) window of a paginated result with total items and the
+// token of the next page, nil on the last one; the caller holds mu
+func (s *Server) page(total int, token *string, max *int64) (int, int, *string, error) {
+	start := 0
+	if aws.StringValue(token) != "" {
+		n, err := strconv.Atoi(*token)
+		if err != nil || n < 0 || n > total {
+			return 0, 0, nil, newError("InvalidParameterValue", "Invalid NextToken '%s'", *token)
+		}
+		start = n
+	}
+	size := s.PageSize
+	if aws.Int64Value(max) > 0 {
+		size = int(*max)
+	}
+	end := total
+	if size > 0 && start+size < total {
+		end = start + size
+	}
+	var next *string
+	if end < total {
+		next = aws.String(strconv.Itoa(end))
+	}
+	return start, end, next, nil
+}
+
+// nextID - return a unique suffix for generated ids, the caller holds mu
+func (s *Server) nextID() int {
+	s.ids++
+	return s.ids
+}
+
+// record - record a call and pop a queued failure for it, the caller holds mu
+func (s *Server) record(service, action string, input interface{}) *Error {
+	s.calls = append(s.calls, Call{Service: service, Action: action, Input: input})
+	if queued := s.failures[action]; len(queued) > 0 {
+		s.failures[action] = queued[1:]
+		return queued[0]
+	}
+	return nil
+}
+
+// ServeHTTP - route requests to the protocol that sent them
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if target := r.Header.Get("X-Amz-Target"); target != "" {
+		s.serveJSON(w, r, target)
+		return
+	}
+
+	if r.Method == "POST" && strings.HasPrefix(r.Header.Get("Content-Type"), "application/x-www-form-urlencoded") {
+		if err := r.ParseForm(); err == nil && r.PostForm.Get("Action") != "" {
+			if r.PostForm.Get("Version") == sqsVersion {
+				s.serveQuery(w, r, "sqs", sqsActions)
+			} else {
+				s.serveQuery(w, r, "ec2", ec2Actions)
+			}
+			return
+		}
+	}
+
+	s.serveS3(w, r)
+}
+
+// invoke - decode the input for handler, record the call and run it
+//
+// handlers are methods with the signature func(*Server, *In) (*Out, error)
+func (s *Server) invoke(service, action string, handler interface{}, decode func(interface{}) error) (interface{}, error) {
+	fn := reflect.ValueOf(handler)
+	input := reflect.New(fn.Type().In(1).Elem())
+	if err := decode(input.Interface()); err != nil {
+		return nil, newError("MalformedInput", "%s", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.record(service, action, input.Interface()); err != nil {
+		return nil, err
+	}
+	if dryRun := input.Elem().FieldByName("DryRun"); dryRun.IsValid() && !dryRun.IsNil() && dryRun.Elem().Bool() {
+		return nil, &Error{Status: http.StatusPreconditionFailed, Code: "DryRunOperation",
+			Message: "Request would have succeeded, but DryRun flag is set."}
+	}
+
+	out := fn.Call([]reflect.Value{reflect.ValueOf(s), input})
+	if err, ok := out[1].Interface().(error); ok && err != nil {
+		return nil, err
+	}
+	return out[0].Interface(), nil
+}
+
+// serveQuery - ec2 and sqs query protocol
+func (s *Server) serveQuery(w http.ResponseWriter, r *http.Request, service string, actions map[string]interface{}) {
+	action := r.PostForm.Get("Action")
+	requestID := fmt.Sprintf("fakeaws-%s-%d", service, len(s.Calls(""))+1)
+
+	handler, ok := actions[action]
+	if !ok {
+		s.writeQueryError(w, service, requestID, newError("InvalidAction", "fakeaws does not implement %s %s", service, action))
+		return
+	}
+	if service == "ec2" {
+		if err := checkVersion(action, r.PostForm); err != nil {
+			s.writeQueryError(w, service, requestID, err)
+			return
+		}
+	}
+
+	out, err := s.invoke(service, action, handler, func(v interface{}) error {
+		return decodeQuery(r.PostForm, v, service == "ec2")
+	})
+	if err != nil {
+		s.writeQueryError(w, service, requestID, err)
+		return
+	}
+	if groups, ok := out.(*ec2ext.DescribeSecurityGroupsOutput); ok && r.PostForm.Get("Version") < ec2ext.APIVersion {
+		out = legacyGroups(groups)
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString(xml.Header)
+	if service == "ec2" {
+		fmt.Fprintf(&buf, "<%sResponse>", action)
+		encodeFields(&buf, reflect.ValueOf(out))
+		fmt.Fprintf(&buf, "<requestId>%s</requestId></%sResponse>", requestID, action)
+	} else {
+		w.Header().Set("X-Amzn-Requestid", requestID)
+		fmt.Fprintf(&buf, "<%sResponse><%sResult>", action, action)
+		encodeFields(&buf, reflect.ValueOf(out))
+		fmt.Fprintf(&buf, "</%sResult><ResponseMetadata><RequestId>%s</RequestId></ResponseMetadata></%sResponse>",
+			action, requestID, action)
+	}
+	w.Header().Set("Content-Type", "text/xml")
+	w.Write(buf.Bytes())
+}
+
+// writeQueryError -
+func (s *Server) writeQueryError(w http.ResponseWriter, service, requestID string, err error) {
+	e := toError(err)
+	w.Header().Set("Content-Type", "text/xml")
+	w.WriteHeader(e.Status)
+	if service == "ec2" {
+		fmt.Fprintf(w, "%s<Response><Errors><Error><Code>%s</Code><Message>%s</Message></Error></Errors><RequestID>%s</RequestID></Response>",
+			xml.Header, escape(e.Code), escape(e.Message), requestID)
+		return
+	}
+	fmt.Fprintf(w, "%s<ErrorResponse><Error><Type>Sender</Type><Code>%s</Code><Message>%s</Message></Error><RequestId>%s</RequestId></ErrorResponse>",
+		xml.Header, escape(e.Code), escape(e.Message), requestID)
+}
+
+// serveJSON - ecr json-rpc protocol
+func (s *Server) serveJSON(w http.ResponseWriter, r *http.Request, target string) {
+	w.Header().Set("Content-Type", "application/x-amz-json-1.1")
+	parts := strings.SplitN(target, ".", 2)
+	if len(parts) != 2 {
+		writeJSONError(w, newError("UnknownOperationException", "bad target %s", target))
+		return
+	}
+	action := parts[1]
+
+	handler, ok := ecrActions[action]
+	if !ok {
+		writeJSONError(w, newError("UnknownOperationException", "fakeaws does not implement ecr %s", action))
+		return
+	}
+
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		writeJSONError(w, newError("SerializationException", "%s", err))
+		return
+	}
+
+	out, err := s.invoke("ecr", action, handler, func(v interface{}) error {
+		if len(body) == 0 {
+			return nil
+		}
+		return jsonutil.UnmarshalJSON(v, strings.NewReader(string(body)))
+	})
+	if err != nil {
+		writeJSONError(w, err)
+		return
+	}
+
+	b, err := jsonutil.BuildJSON(out)
+	if err != nil {
+		writeJSONError(w, newError("SerializationException", "%s", err))
+		return
+	}
+	w.Write(b)
+}
+
+// writeJSONError -
+func writeJSONError(w http.ResponseWriter, err error) {
+	e := toError(err)
+	w.WriteHeader(e.Status)
+	json.NewEncoder(w).Encode(map[string]string{"__type": e.Code, "message": e.Message})
+}
+
+// toError - convert any handler error into an aws error
+func toError(err error) *Error {
+	if e, ok := err.(*Error); ok {
+		return e
+	}
+	return &Error{Status: http.StatusInternalServerError, Code: "InternalError", Message: err.Error()}
+}
+
+// escape - escape text for inclusion in xml
+func escape(text string) string {
+	var buf bytes.Buffer
+	xml.EscapeText(&buf, []byte(text))
+	return buf.String()
+}
@@ -0,0 +1,220 @@
+// Package fakeaws -
+package fakeaws
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/sqs"
+)
+
+// sqsActions - sqs query actions the fake server implements
+var sqsActions = map[string]interface{}{
+	"GetQueueUrl":    (*Server).getQueueURL,
+	"SendMessage":    (*Server).sendMessage,
+	"ReceiveMessage": (*Server).receiveMessage,
+	"DeleteMessage":  (*Server).deleteMessage,
+}
+
+// sqsState -
+type sqsState struct {
+	queues map[string]*queue
+}
+
+// queue - messages in the order they were sent
+type queue struct {
+	messages []*message
+}
+
+// message - a queued message and when it becomes visible again
+type message struct {
+	msg       *sqs.Message
+	sent      time.Time
+	visibleAt time.Time
+	receives  int
+}
+
+// newSQSState -
+func newSQSState() *sqsState {
+	return &sqsState{queues: make(map[string]*queue)}
+}
+
+// CreateQueue - add an empty queue and return its url
+func (s *Server) CreateQueue(name string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.sqs.queues[name]; !ok {
+		s.sqs.queues[name] = &queue{}
+	}
+	return s.queueURL(s.Account, name)
+}
+
+// Messages - return the messages currently held by queue name, visible or not
+func (s *Server) Messages(name string) []*sqs.Message {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var messages []*sqs.Message
+	if q, ok := s.sqs.queues[name]; ok {
+		for _, m := range q.messages {
+			messages = append(messages, m.msg)
+		}
+	}
+	return messages
+}
+
+// queueURL -
+func (s *Server) queueURL(account, name string) string {
+	return fmt.Sprintf("%s/%s/%s", s.URL, account, name)
+}
+
+// lookupQueue - find a queue by its url, only the trailing name is significant; the caller holds mu
+func (s *Server) lookupQueue(url *string) (*queue, error) {
+	u := strings.TrimSuffix(aws.StringValue(url), "/")
+	name := u[strings.LastIndex(u, "/")+1:]
+	q, ok := s.sqs.queues[name]
+	if !ok {
+		return nil, newError("AWS.SimpleQueueService.NonExistentQueue", "The specified queue does not exist for this wsdl version.")
+	}
+	return q, nil
+}
+
+// getQueueURL -
+func (s *Server) getQueueURL(in *sqs.GetQueueUrlInput) (*sqs.GetQueueUrlOutput, error) {
+	name := aws.StringValue(in.QueueName)
+	if _, ok := s.sqs.queues[name]; !ok {
+		return nil, newError("AWS.SimpleQueueService.NonExistentQueue", "The specified queue does not exist for this wsdl version.")
+	}
+	account := aws.StringValue(in.QueueOwnerAWSAccountId)
+	if account == "" {
+		account = s.Account
+	}
+	return &sqs.GetQueueUrlOutput{QueueUrl: aws.String(s.queueURL(account, name))}, nil
+}
+
+// sendMessage -
+func (s *Server) sendMessage(in *sqs.SendMessageInput) (*sqs.SendMessageOutput, error) {
+	q, err := s.lookupQueue(in.QueueUrl)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	id := fmt.Sprintf("fakeaws-message-%d", s.nextID())
+	digest := md5Hex(aws.StringValue(in.MessageBody))
+	q.messages = append(q.messages, &message{
+		msg: &sqs.Message{
+			MessageId:         aws.String(id),
+			Body:              in.MessageBody,
+			MD5OfBody:         aws.String(digest),
+			MessageAttributes: in.MessageAttributes,
+		},
+		sent:      now,
+		visibleAt: now.Add(time.Duration(aws.Int64Value(in.DelaySeconds)) * time.Second),
+	})
+	return &sqs.SendMessageOutput{MessageId: aws.String(id), MD5OfMessageBody: aws.String(digest)}, nil
+}
+
+// receiveMessage - return up to MaxNumberOfMessages visible messages, WaitTimeSeconds is ignored
+func (s *Server) receiveMessage(in *sqs.ReceiveMessageInput) (*sqs.ReceiveMessageOutput, error) {
+	q, err := s.lookupQueue(in.QueueUrl)
+	if err != nil {
+		return nil, err
+	}
+
+	max := int(aws.Int64Value(in.MaxNumberOfMessages))
+	if max == 0 {
+		max = 1
+	}
+	if max > 10 {
+		return nil, newError("InvalidParameterValue", "Value %d for parameter MaxNumberOfMessages is invalid. Reason: Must be between 1 and 10, if provided.", max)
+	}
+	visibility := time.Duration(aws.Int64Value(in.VisibilityTimeout)) * time.Second
+	if in.VisibilityTimeout == nil {
+		visibility = 30 * time.Second
+	}
+
+	now := time.Now()
+	out := &sqs.ReceiveMessageOutput{Messages: []*sqs.Message{}}
+	for _, m := range q.messages {
+		if len(out.Messages) == max {
+			break
+		}
+		if m.visibleAt.After(now) {
+			continue
+		}
+		m.receives++
+		m.visibleAt = now.Add(visibility)
+
+		msg := *m.msg
+		msg.ReceiptHandle = aws.String(fmt.Sprintf("%s#%d", aws.StringValue(m.msg.MessageId), m.receives))
+		msg.Attributes = filterAttributes(map[string]*string{
+			"SenderId":                aws.String(s.Account),
+			"SentTimestamp":           aws.String(fmt.Sprintf("%d", m.sent.UnixNano()/int64(time.Millisecond))),
+			"ApproximateReceiveCount": aws.String(fmt.Sprintf("%d", m.receives)),
+		}, aws.StringValueSlice(in.AttributeNames))
+		msg.MessageAttributes = filterMessageAttributes(m.msg.MessageAttributes, aws.StringValueSlice(in.MessageAttributeNames))
+		out.Messages = append(out.Messages, &msg)
+	}
+	return out, nil
+}
+
+// deleteMessage -
+func (s *Server) deleteMessage(in *sqs.DeleteMessageInput) (*sqs.DeleteMessageOutput, error) {
+	q, err := s.lookupQueue(in.QueueUrl)
+	if err != nil {
+		return nil, err
+	}
+	handle := aws.StringValue(in.ReceiptHandle)
+	id := handle[:strings.LastIndex(handle+"#", "#")]
+	for pos, m := range q.messages {
+		if aws.StringValue(m.msg.MessageId) == id {
+			q.messages = append(q.messages[:pos], q.messages[pos+1:]...)
+			return &sqs.DeleteMessageOutput{}, nil
+		}
+	}
+	return nil, newError("ReceiptHandleIsInvalid", "The input receipt handle \"%s\" is not a valid receipt handle.", handle)
+}
+
+// filterAttributes - keep the requested system attributes, "All" keeps everything
+func filterAttributes(attrs map[string]*string, names []string) map[string]*string {
+	if len(names) == 0 {
+		return nil
+	}
+	if contains(names, "All") {
+		return attrs
+	}
+	filtered := make(map[string]*string)
+	for _, name := range names {
+		if v, ok := attrs[name]; ok {
+			filtered[name] = v
+		}
+	}
+	return filtered
+}
+
+// filterMessageAttributes - keep the requested message attributes, "All" or ".*" keeps everything
+func filterMessageAttributes(attrs map[string]*sqs.MessageAttributeValue, names []string) map[string]*sqs.MessageAttributeValue {
+	if len(names) == 0 || len(attrs) == 0 {
+		return nil
+	}
+	if contains(names, "All") || contains(names, ".*") {
+		return attrs
+	}
+	filtered := make(map[string]*sqs.MessageAttributeValue)
+	for _, name := range names {
+		if v, ok := attrs[name]; ok {
+			filtered[name] = v
+		}
+	}
+	return filtered
+}
+
+// md5Hex -
+func md5Hex(body string) string {
+	sum := md5.Sum([]byte(body))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,155 @@
+// Package imds - ec2 instance metadata service emulator.
+//
+// A Server answers the IMDS paths the sdk's ec2metadata client and the tools
+// read, including IMDSv2 tokens, from a Fixture usually loaded from yaml:
+//
+//	srv := imds.New(imds.DefaultFixture())
+//	http.ListenAndServe("127.0.0.1:1338", srv)
+//
+// Clients reach it through the metadata endpoint override,
+// $AWS_EC2_METADATA_SERVICE_ENDPOINT=http://127.0.0.1:1338.
+package imds
+
+import (
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v2"
+)
+
+// HTTPTokensOptional and HTTPTokensRequired - the HttpTokens setting of an instance,
+// required answers token-less requests with 401 like an instance with IMDSv1 off
+const (
+	HTTPTokensOptional = "optional"
+	HTTPTokensRequired = "required"
+)
+
+// Credentials - the instance profile role's credentials
+type Credentials struct {
+	Role            string `yaml:"role"`
+	AccessKeyID     string `yaml:"access-key-id"`
+	SecretAccessKey string `yaml:"secret-access-key"`
+	Token           string `yaml:"token"`
+	// Expiration - reported expiry in RFC 3339, an hour after each request when empty
+	Expiration string `yaml:"expiration"`
+}
+
+// Fixture - the instance the emulator pretends to be
+type Fixture struct {
+	InstanceID       string `yaml:"instance-id"`
+	InstanceType     string `yaml:"instance-type"`
+	ImageID          string `yaml:"ami-id"`
+	AccountID        string `yaml:"account-id"`
+	Region           string `yaml:"region"`
+	AvailabilityZone string `yaml:"availability-zone"`
+	PrivateIP        string `yaml:"private-ip"`
+	Hostname         string `yaml:"hostname"`
+	UserData         string `yaml:"user-data"`
+
+	IAM *Credentials `yaml:"iam"`
+
+	// MetaData - further meta-data paths and their values, e.g. 'public-ipv4' or
+	// 'tags/instance/Name', overriding the ones derived from the fields above
+	MetaData map[string]string `yaml:"meta-data"`
+
+	// HTTPTokens - optional (the default) or required
+	HTTPTokens string `yaml:"http-tokens"`
+}
+
+// DefaultFixture - a t2.micro in us-east-1a with an instance profile role
+func DefaultFixture() *Fixture {
+	return &Fixture{
+		InstanceID:       "i-0123456789abcdef0",
+		InstanceType:     "t2.micro",
+		ImageID:          "ami-12345678",
+		AccountID:        "123456789012",
+		Region:           "us-east-1",
+		AvailabilityZone: "us-east-1a",
+		PrivateIP:        "10.0.0.10",
+		Hostname:         "ip-10-0-0-10.ec2.internal",
+		IAM: &Credentials{
+			Role:            "imds-role",
+			AccessKeyID:     "AKIDIMDSEMULATOR",
+			SecretAccessKey: "imds-emulator-secret",
+			Token:           "imds-emulator-token",
+		},
+		HTTPTokens: HTTPTokensOptional,
+	}
+}
+
+// LoadFixture - read a yaml fixture from path, fields it leaves out are taken from
+// DefaultFixture, except iam which is only served when given
+func LoadFixture(path string) (*Fixture, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseFixture(b)
+}
+
+// ParseFixture - parse a yaml fixture, see LoadFixture
+func ParseFixture(b []byte) (*Fixture, error) {
+	f := DefaultFixture()
+	f.IAM = nil
+	if err := yaml.Unmarshal(b, f); err != nil {
+		return nil, fmt.Errorf("invalid fixture: %s", err)
+	}
+	if err := f.Validate(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// Validate - check the fixture describes an instance the emulator can serve
+func (f *Fixture) Validate() error {
+	if !strings.HasPrefix(f.InstanceID, "i-") {
+		return fmt.Errorf("invalid fixture: instance-id '%s' doesn't start with i-", f.InstanceID)
+	}
+	if len(f.AccountID) != 12 {
+		return fmt.Errorf("invalid fixture: account-id '%s' isn't 12 digits", f.AccountID)
+	}
+	if !strings.HasPrefix(f.AvailabilityZone, f.Region) {
+		return fmt.Errorf("invalid fixture: availability-zone '%s' isn't in region '%s'", f.AvailabilityZone, f.Region)
+	}
+	switch f.HTTPTokens {
+	case "":
+		f.HTTPTokens = HTTPTokensOptional
+	case HTTPTokensOptional, HTTPTokensRequired:
+	default:
+		return fmt.Errorf("invalid fixture: http-tokens '%s', valid values optional or required", f.HTTPTokens)
+	}
+	if f.IAM != nil && f.IAM.Role == "" {
+		return fmt.Errorf("invalid fixture: iam without a role")
+	}
+	if f.IAM != nil && f.IAM.Expiration != "" {
+		if _, err := time.Parse(time.RFC3339, f.IAM.Expiration); err != nil {
+			return fmt.Errorf("invalid fixture: iam expiration: %s", err)
+		}
+	}
+	return nil
+}
+
+// metaData - every meta-data path served and its value
+func (f *Fixture) metaData() map[string]string {
+	md := map[string]string{
+		"instance-id":                 f.InstanceID,
+		"instance-type":               f.InstanceType,
+		"ami-id":                      f.ImageID,
+		"local-ipv4":                  f.PrivateIP,
+		"local-hostname":              f.Hostname,
+		"hostname":                    f.Hostname,
+		"placement/availability-zone": f.AvailabilityZone,
+		"placement/region":            f.Region,
+	}
+	for path, value := range f.MetaData {
+		md[strings.Trim(path, "/")] = value
+	}
+	for path, value := range md {
+		if value == "" {
+			delete(md, path)
+		}
+	}
+	return md
+}
@@ -0,0 +1,146 @@
+package imds_test
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/aidevops/awscli"
+	"github.com/aidevops/awscli/imds"
+)
+
+// get - GET path from srv with token, returning the status and body
+func get(srv *httptest.Server, path, token string) (int, string) {
+	req, err := http.NewRequest("GET", srv.URL+path, nil)
+	Expect(err).NotTo(HaveOccurred())
+	if token != "" {
+		req.Header.Set(imds.TokenHeader, token)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	Expect(err).NotTo(HaveOccurred())
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	Expect(err).NotTo(HaveOccurred())
+	return resp.StatusCode, string(body)
+}
+
+var _ = Describe("IMDS", func() {
+
+	Describe("ParseFixture", func() {
+		It("Fills in defaults and keeps account ids as written", func() {
+			f, err := imds.ParseFixture([]byte("instance-id: i-0abc\naccount-id: 012345678901\nregion: eu-west-1\navailability-zone: eu-west-1b\n"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(f.InstanceID).To(Equal("i-0abc"))
+			Expect(f.AccountID).To(Equal("012345678901"))
+			Expect(f.InstanceType).To(Equal("t2.micro"))
+			Expect(f.HTTPTokens).To(Equal(imds.HTTPTokensOptional))
+			Expect(f.IAM).To(BeNil())
+		})
+		It("Rejects an availability zone outside the region", func() {
+			_, err := imds.ParseFixture([]byte("region: eu-west-1\n"))
+			Expect(err).To(MatchError(ContainSubstring("availability-zone")))
+		})
+		It("Rejects unknown http-tokens settings", func() {
+			_, err := imds.ParseFixture([]byte("http-tokens: sometimes\n"))
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	Describe("Server", func() {
+		var (
+			fixture *imds.Fixture
+			srv     *httptest.Server
+			saved   map[string]string
+			envKeys = []string{
+				"AWS_EC2_METADATA_DISABLED", "AWS_EC2_METADATA_SERVICE_ENDPOINT",
+				"AWS_ACCESS_KEY_ID", "AWS_SECRET_ACCESS_KEY", "AWS_SHARED_CREDENTIALS_FILE",
+				"AWS_PROFILE", "AWS_REGION", "AWS_DEFAULT_REGION", "AWS_CONFIG_FILE",
+			}
+		)
+
+		BeforeEach(func() {
+			fixture = imds.DefaultFixture()
+			fixture.UserData = "#!/bin/sh\necho hello\n"
+			fixture.MetaData = map[string]string{"tags/instance/Name": "web"}
+			srv = httptest.NewServer(imds.New(fixture))
+
+			saved = make(map[string]string)
+			for _, key := range envKeys {
+				saved[key] = os.Getenv(key)
+				os.Unsetenv(key)
+			}
+			missing := filepath.Join(os.TempDir(), "imds-missing")
+			os.Setenv("AWS_SHARED_CREDENTIALS_FILE", missing)
+			os.Setenv("AWS_CONFIG_FILE", missing)
+			os.Setenv("AWS_EC2_METADATA_SERVICE_ENDPOINT", srv.URL)
+		})
+
+		AfterEach(func() {
+			srv.Close()
+			for key, value := range saved {
+				if value == "" {
+					os.Unsetenv(key)
+				} else {
+					os.Setenv(key, value)
+				}
+			}
+		})
+
+		It("Serves the fixture to the discovery helpers", func() {
+			cli := &awscli.AwsCli{}
+			Expect(cli.GetRegion()).To(Equal(fixture.Region))
+			Expect(cli.GetAccount()).To(Equal(fixture.AccountID))
+			Expect(cli.InstanceID()).To(Equal(fixture.InstanceID))
+		})
+
+		It("Serves meta-data paths, listings and user-data", func() {
+			client := (&awscli.AwsCli{}).MetadataClient()
+			Expect(client.GetMetadata("placement/availability-zone")).To(Equal("us-east-1a"))
+			Expect(client.GetMetadata("tags/instance/Name")).To(Equal("web"))
+			Expect(client.GetMetadata("tags/instance")).To(Equal("Name"))
+			Expect(client.GetMetadata("iam/security-credentials/")).To(Equal("imds-role"))
+			Expect(client.GetMetadata("placement")).To(Equal("availability-zone\nregion"))
+
+			status, body := get(srv, "/latest/user-data", "")
+			Expect(status).To(Equal(http.StatusOK))
+			Expect(body).To(Equal(fixture.UserData))
+		})
+
+		It("Hands instance role credentials to the credential chain", func() {
+			value, err := (&awscli.AwsCli{}).Credentials().Get()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(value.AccessKeyID).To(Equal("AKIDIMDSEMULATOR"))
+			Expect(value.SessionToken).To(Equal("imds-emulator-token"))
+		})
+
+		It("Requires a token when http-tokens is required", func() {
+			fixture.HTTPTokens = imds.HTTPTokensRequired
+			status, _ := get(srv, "/latest/meta-data/instance-id", "")
+			Expect(status).To(Equal(http.StatusUnauthorized))
+			status, _ = get(srv, "/latest/meta-data/instance-id", "not-a-token")
+			Expect(status).To(Equal(http.StatusUnauthorized))
+
+			Expect((&awscli.AwsCli{}).MetadataClient().GetMetadata("instance-id")).To(Equal(fixture.InstanceID))
+		})
+
+		It("Validates token requests", func() {
+			req, _ := http.NewRequest("PUT", srv.URL+"/latest/api/token", nil)
+			resp, err := http.DefaultClient.Do(req)
+			Expect(err).NotTo(HaveOccurred())
+			resp.Body.Close()
+			Expect(resp.StatusCode).To(Equal(http.StatusBadRequest))
+
+			req.Header.Set(imds.TokenTTLHeader, "60")
+			req.Header.Set("X-Forwarded-For", "10.0.0.1")
+			resp, err = http.DefaultClient.Do(req)
+			Expect(err).NotTo(HaveOccurred())
+			resp.Body.Close()
+			Expect(resp.StatusCode).To(Equal(http.StatusForbidden))
+		})
+	})
+})
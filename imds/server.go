@@ -0,0 +1,249 @@
+package imds
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// TokenHeader - header carrying the IMDSv2 token on metadata requests
+	TokenHeader = "X-aws-ec2-metadata-token"
+	// TokenTTLHeader - header requesting, and reporting, a token's lifetime in seconds
+	TokenTTLHeader = "X-aws-ec2-metadata-token-ttl-seconds"
+	// MaxTokenTTL - longest lifetime a token may be requested for
+	MaxTokenTTL = 6 * time.Hour
+)
+
+// Server - http.Handler emulating the metadata service of Fixture
+type Server struct {
+	Fixture *Fixture
+	// Log - called with every request's method, path and response status when set
+	Log func(method, path string, status int)
+
+	mu     sync.Mutex
+	tokens map[string]time.Time
+}
+
+// New - return a Server for f
+func New(f *Fixture) *Server {
+	return &Server{Fixture: f, tokens: make(map[string]time.Time)}
+}
+
+// statusWriter - remembers the status written for Log
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+// WriteHeader -
+func (w *statusWriter) WriteHeader(status int) {
+	w.status = status
+	w.ResponseWriter.WriteHeader(status)
+}
+
+// ServeHTTP -
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	sw := &statusWriter{ResponseWriter: w, status: http.StatusOK}
+	s.serve(sw, r)
+	if s.Log != nil {
+		s.Log(r.Method, r.URL.Path, sw.status)
+	}
+}
+
+func (s *Server) serve(w http.ResponseWriter, r *http.Request) {
+	path := strings.Trim(r.URL.Path, "/")
+	if path == "latest/api/token" {
+		s.token(w, r)
+		return
+	}
+
+	if r.Method != "GET" && r.Method != "HEAD" {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !s.authorized(r) {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	f := s.Fixture
+	switch {
+	case path == "latest":
+		listing := []string{"dynamic/", "meta-data/"}
+		if f.UserData != "" {
+			listing = append(listing, "user-data")
+		}
+		s.text(w, strings.Join(listing, "\n"))
+	case path == "latest/user-data":
+		if f.UserData == "" {
+			http.NotFound(w, r)
+			return
+		}
+		s.text(w, f.UserData)
+	case path == "latest/dynamic" || path == "latest/dynamic/instance-identity":
+		s.text(w, "instance-identity/")
+	case path == "latest/dynamic/instance-identity/document":
+		s.json(w, s.identity())
+	case path == "latest/meta-data" || strings.HasPrefix(path, "latest/meta-data/"):
+		s.metaData(w, r, strings.TrimPrefix(strings.TrimPrefix(path, "latest/meta-data"), "/"))
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// token - hand out a token for the requested ttl
+func (s *Server) token(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "PUT" {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	// like the real service, refuse tokens to requests that went through a proxy
+	if r.Header.Get("X-Forwarded-For") != "" {
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return
+	}
+	seconds, err := strconv.Atoi(r.Header.Get(TokenTTLHeader))
+	if err != nil || seconds < 1 || time.Duration(seconds)*time.Second > MaxTokenTTL {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	token := hex.EncodeToString(b)
+
+	s.mu.Lock()
+	now := time.Now()
+	for t, expires := range s.tokens {
+		if now.After(expires) {
+			delete(s.tokens, t)
+		}
+	}
+	s.tokens[token] = now.Add(time.Duration(seconds) * time.Second)
+	s.mu.Unlock()
+
+	w.Header().Set(TokenTTLHeader, strconv.Itoa(seconds))
+	s.text(w, token)
+}
+
+// authorized - a request carrying a token needs a valid one, one without is
+// only let through while http-tokens is optional
+func (s *Server) authorized(r *http.Request) bool {
+	token := r.Header.Get(TokenHeader)
+	if token == "" {
+		return s.Fixture.HTTPTokens != HTTPTokensRequired
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	expires, ok := s.tokens[token]
+	return ok && time.Now().Before(expires)
+}
+
+// identity - the instance identity document
+func (s *Server) identity() map[string]interface{} {
+	f := s.Fixture
+	return map[string]interface{}{
+		"accountId":        f.AccountID,
+		"architecture":     "x86_64",
+		"availabilityZone": f.AvailabilityZone,
+		"imageId":          f.ImageID,
+		"instanceId":       f.InstanceID,
+		"instanceType":     f.InstanceType,
+		"pendingTime":      "2016-01-01T00:00:00Z",
+		"privateIp":        f.PrivateIP,
+		"region":           f.Region,
+		"version":          "2010-08-31",
+	}
+}
+
+// metaData - serve the meta-data value at path, or list the entries below it
+func (s *Server) metaData(w http.ResponseWriter, r *http.Request, path string) {
+	f := s.Fixture
+	md := f.metaData()
+	if f.IAM != nil {
+		md["iam/info"] = ""
+		md["iam/security-credentials/"+f.IAM.Role] = ""
+	}
+
+	switch {
+	case f.IAM != nil && path == "iam/info":
+		s.json(w, map[string]string{
+			"Code":               "Success",
+			"LastUpdated":        time.Now().UTC().Format(time.RFC3339),
+			"InstanceProfileArn": "arn:aws:iam::" + f.AccountID + ":instance-profile/" + f.IAM.Role,
+			"InstanceProfileId":  "AIPAIMDSEMULATOR",
+		})
+		return
+	case f.IAM != nil && path == "iam/security-credentials/"+f.IAM.Role:
+		expiration := f.IAM.Expiration
+		if expiration == "" {
+			expiration = time.Now().Add(time.Hour).UTC().Format(time.RFC3339)
+		}
+		s.json(w, map[string]string{
+			"Code":            "Success",
+			"LastUpdated":     time.Now().UTC().Format(time.RFC3339),
+			"Type":            "AWS-HMAC",
+			"AccessKeyId":     f.IAM.AccessKeyID,
+			"SecretAccessKey": f.IAM.SecretAccessKey,
+			"Token":           f.IAM.Token,
+			"Expiration":      expiration,
+		})
+		return
+	}
+
+	if value, ok := md[path]; ok {
+		s.text(w, value)
+		return
+	}
+
+	// a directory lists its children, sub directories with a trailing /
+	prefix := path + "/"
+	if path == "" {
+		prefix = ""
+	}
+	seen := make(map[string]bool)
+	var listing []string
+	for key := range md {
+		if !strings.HasPrefix(key, prefix) {
+			continue
+		}
+		child := strings.TrimPrefix(key, prefix)
+		if i := strings.Index(child, "/"); i >= 0 {
+			child = child[:i+1]
+		}
+		if !seen[child] {
+			seen[child] = true
+			listing = append(listing, child)
+		}
+	}
+	if len(listing) == 0 {
+		http.NotFound(w, r)
+		return
+	}
+	sort.Strings(listing)
+	s.text(w, strings.Join(listing, "\n"))
+}
+
+func (s *Server) text(w http.ResponseWriter, body string) {
+	w.Header().Set("Content-Type", "text/plain")
+	w.Write([]byte(body))
+}
+
+func (s *Server) json(w http.ResponseWriter, v interface{}) {
+	b, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	s.text(w, string(b))
+}
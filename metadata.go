@@ -0,0 +1,121 @@
+// Package awscli -
+package awscli
+
+import (
+	"errors"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/ec2metadata"
+	"github.com/aws/aws-sdk-go/aws/session"
+)
+
+// MetadataTimeout - how long a single instance metadata request may take, kept short
+// so tools running off ec2 don't stall while resolving defaults
+const MetadataTimeout = time.Second
+
+// ErrMetadataDisabled - returned by metadata lookups when $AWS_EC2_METADATA_DISABLED is set
+var ErrMetadataDisabled = errors.New("ec2 instance metadata disabled by AWS_EC2_METADATA_DISABLED")
+
+// identities - instance identity documents by metadata endpoint, failures included,
+// so the service is asked at most once per process
+var identities = struct {
+	sync.Mutex
+	docs map[string]*identity
+}{docs: make(map[string]*identity)}
+
+type identity struct {
+	doc ec2metadata.EC2InstanceIdentityDocument
+	err error
+}
+
+// MetadataDisabled - return true when $AWS_EC2_METADATA_DISABLED turns instance metadata off
+func MetadataDisabled() bool {
+	disabled, _ := strconv.ParseBool(os.Getenv("AWS_EC2_METADATA_DISABLED"))
+	return disabled
+}
+
+// MetadataEndpoint - return the instance metadata service override, an empty string
+// means http://169.254.169.254.
+//
+// Lookup order: an 'ec2metadata=url' pair in -endpoint-url, $AWS_EC2_METADATA_SERVICE_ENDPOINT,
+// then the profile's 'ec2_metadata_service_endpoint'. A single -endpoint-url or
+// $AWS_ENDPOINT_URL points the api clients at a stand-in and is not used here.
+func (a *AwsCli) MetadataEndpoint() string {
+	if strings.Contains(a.EndpointURL, "=") {
+		for _, pair := range strings.Split(a.EndpointURL, ",") {
+			kv := strings.SplitN(strings.TrimSpace(pair), "=", 2)
+			if len(kv) == 2 && strings.EqualFold(kv[0], ec2metadata.ServiceName) {
+				return kv[1]
+			}
+		}
+	}
+	if endpoint := os.Getenv("AWS_EC2_METADATA_SERVICE_ENDPOINT"); endpoint != "" {
+		return endpoint
+	}
+	return ProfileValue(a.GetProfile(), "ec2_metadata_service_endpoint")
+}
+
+// MetadataClient - return an instance metadata client for MetadataEndpoint with a
+// MetadataTimeout per request. Requests carry an IMDSv2 token from MetadataToken and
+// are retried once, only when the token was rejected.
+func (a *AwsCli) MetadataClient() *ec2metadata.EC2Metadata {
+	cfg := aws.NewConfig().
+		WithHTTPClient(&http.Client{Timeout: MetadataTimeout}).
+		WithMaxRetries(1)
+	if endpoint := a.MetadataEndpoint(); endpoint != "" {
+		cfg = cfg.WithEndpoint(strings.TrimSuffix(endpoint, "/") + "/latest")
+	}
+	client := ec2metadata.New(session.New(), cfg)
+
+	token := a.MetadataToken(client)
+	client.Handlers.Sign.PushBack(token.Sign)
+	client.Handlers.Retry.PushBack(token.Retry)
+	return client
+}
+
+// Identity - return the instance identity document of the ec2 instance we're running on
+func (a *AwsCli) Identity() (ec2metadata.EC2InstanceIdentityDocument, error) {
+	if MetadataDisabled() {
+		return ec2metadata.EC2InstanceIdentityDocument{}, ErrMetadataDisabled
+	}
+
+	endpoint := a.MetadataEndpoint()
+	identities.Lock()
+	defer identities.Unlock()
+	if id, ok := identities.docs[endpoint]; ok {
+		return id.doc, id.err
+	}
+
+	doc, err := a.MetadataClient().GetInstanceIdentityDocument()
+	identities.docs[endpoint] = &identity{doc: doc, err: err}
+	return doc, err
+}
+
+// GetAccount - return the account in use: -account, then the instance's account
+func (a *AwsCli) GetAccount() string {
+	if a.Account != "" {
+		return a.Account
+	}
+	if doc, err := a.Identity(); err == nil {
+		return doc.AccountID
+	}
+	return ""
+}
+
+// InstanceID - return the id of the ec2 instance we're running on
+func (a *AwsCli) InstanceID() (string, error) {
+	doc, err := a.Identity()
+	if err != nil {
+		return "", err
+	}
+	if doc.InstanceID == "" {
+		return "", errors.New("instance identity document without an instance id")
+	}
+	return doc.InstanceID, nil
+}
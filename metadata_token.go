@@ -0,0 +1,204 @@
+// Package awscli -
+package awscli
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/ec2metadata"
+	"github.com/aws/aws-sdk-go/aws/request"
+)
+
+const (
+	// MetadataTokenHeader - header carrying the IMDSv2 session token on metadata requests
+	MetadataTokenHeader = "X-aws-ec2-metadata-token"
+	// MetadataTokenTTLHeader - header requesting, and reporting, the token's lifetime in seconds
+	MetadataTokenTTLHeader = "X-aws-ec2-metadata-token-ttl-seconds"
+	// MetadataTokenTTL - lifetime requested for new tokens, the service's maximum
+	MetadataTokenTTL = 6 * time.Hour
+	// ErrCodeMetadataToken - aws error code of metadata requests failing for want of a token
+	ErrCodeMetadataToken = "EC2MetadataTokenError"
+)
+
+// metadataTokenPath - where tokens are handed out, relative to the client's .../latest endpoint
+const metadataTokenPath = "/api/token"
+
+// metadataTokenSlack - tokens are renewed this long before they expire so none
+// runs out in flight
+const metadataTokenSlack = time.Minute
+
+// metadataV1Retry - how long a fallback to IMDSv1 is kept before tokens are tried again
+const metadataV1Retry = 5 * time.Minute
+
+// tokens - token caches by metadata endpoint, shared by every client of the process
+var tokens = struct {
+	sync.Mutex
+	byEndpoint map[string]*MetadataToken
+}{byEndpoint: make(map[string]*MetadataToken)}
+
+// MetadataToken - IMDSv2 session tokens for one metadata endpoint. A token is fetched
+// with a PUT to /latest/api/token, cached until shortly before its TTL runs out and
+// sent on every request in the X-aws-ec2-metadata-token header.
+//
+// When the token endpoint can't be used (an IMDSv1 only stand-in, a container a
+// hop too far for the PUT's response) requests go out without a token, unless AllowV1
+// is false. A 403 from the token endpoint means metadata is turned off for the
+// instance and is never retried without a token.
+type MetadataToken struct {
+	// Endpoint - metadata endpoint including /latest, e.g. http://169.254.169.254/latest
+	Endpoint string
+	// TTL - lifetime requested for new tokens, MetadataTokenTTL when zero
+	TTL time.Duration
+	// AllowV1 - fall back to token-less requests when no token can be had
+	AllowV1 bool
+	// Client - http client for the token requests, http.DefaultClient when nil
+	Client *http.Client
+
+	mu      sync.Mutex
+	token   string
+	expires time.Time
+	v1Until time.Time
+}
+
+// Token - return a valid token, fetching a new one when the cached one is about to
+// expire. An empty token and no error means the request is to be sent as IMDSv1.
+func (t *MetadataToken) Token() (string, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := time.Now()
+	if t.token != "" && now.Before(t.expires) {
+		return t.token, nil
+	}
+	if now.Before(t.v1Until) {
+		return "", nil
+	}
+
+	token, ttl, err := t.fetch()
+	if err != nil {
+		if aerr, ok := err.(awserr.RequestFailure); ok && aerr.StatusCode() == http.StatusForbidden {
+			return "", err
+		}
+		if !t.AllowV1 {
+			return "", err
+		}
+		t.v1Until = now.Add(metadataV1Retry)
+		return "", nil
+	}
+
+	t.token = token
+	t.expires = now.Add(ttl - metadataTokenSlack)
+	if ttl <= 2*metadataTokenSlack {
+		t.expires = now.Add(ttl / 2)
+	}
+	return token, nil
+}
+
+// Invalidate - forget the cached token, the next request fetches a new one
+func (t *MetadataToken) Invalidate() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.token = ""
+	t.expires = time.Time{}
+}
+
+// fetch - PUT a token request, returning the token and the TTL the service granted
+func (t *MetadataToken) fetch() (string, time.Duration, error) {
+	ttl := t.TTL
+	if ttl <= 0 {
+		ttl = MetadataTokenTTL
+	}
+
+	req, err := http.NewRequest("PUT", t.Endpoint+metadataTokenPath, nil)
+	if err != nil {
+		return "", 0, awserr.New(ErrCodeMetadataToken, "failed to build the token request", err)
+	}
+	req.Header.Set(MetadataTokenTTLHeader, strconv.Itoa(int(ttl/time.Second)))
+
+	client := t.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", 0, awserr.New(ErrCodeMetadataToken, "failed to request a metadata token", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return "", 0, awserr.New(ErrCodeMetadataToken, "failed to read the metadata token", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", 0, awserr.NewRequestFailure(
+			awserr.New(ErrCodeMetadataToken, fmt.Sprintf("metadata token request failed: %s", resp.Status), nil),
+			resp.StatusCode, "")
+	}
+	if len(body) == 0 {
+		return "", 0, awserr.New(ErrCodeMetadataToken, "empty metadata token", nil)
+	}
+
+	if seconds, err := strconv.Atoi(resp.Header.Get(MetadataTokenTTLHeader)); err == nil && seconds > 0 {
+		ttl = time.Duration(seconds) * time.Second
+	}
+	return string(body), ttl, nil
+}
+
+// Sign - request handler adding the token header to a metadata request
+func (t *MetadataToken) Sign(r *request.Request) {
+	token, err := t.Token()
+	if err != nil {
+		r.Error = err
+		return
+	}
+	if token != "" {
+		r.HTTPRequest.Header.Set(MetadataTokenHeader, token)
+	}
+}
+
+// Retry - request handler retrying a request whose token was rejected with a new
+// one, and nothing else
+func (t *MetadataToken) Retry(r *request.Request) {
+	if r.HTTPResponse != nil && r.HTTPResponse.StatusCode == http.StatusUnauthorized &&
+		r.HTTPRequest.Header.Get(MetadataTokenHeader) != "" {
+		t.Invalidate()
+		r.Retryable = aws.Bool(true)
+		return
+	}
+	r.Retryable = aws.Bool(false)
+}
+
+// MetadataV1Allowed - return false when $AWS_EC2_METADATA_V1_DISABLED or the profile's
+// 'ec2_metadata_v1_disabled' forbid token-less metadata requests
+func (a *AwsCli) MetadataV1Allowed() bool {
+	if disabled, err := strconv.ParseBool(os.Getenv("AWS_EC2_METADATA_V1_DISABLED")); err == nil {
+		return !disabled
+	}
+	disabled, _ := strconv.ParseBool(ProfileValue(a.GetProfile(), "ec2_metadata_v1_disabled"))
+	return !disabled
+}
+
+// MetadataToken - return the process wide token cache for the client's endpoint
+func (a *AwsCli) MetadataToken(client *ec2metadata.EC2Metadata) *MetadataToken {
+	endpoint := client.ClientInfo.Endpoint
+	allowV1 := a.MetadataV1Allowed()
+
+	tokens.Lock()
+	defer tokens.Unlock()
+	t, ok := tokens.byEndpoint[endpoint]
+	if !ok {
+		t = &MetadataToken{Endpoint: endpoint, Client: client.Config.HTTPClient}
+		tokens.byEndpoint[endpoint] = t
+	}
+	t.mu.Lock()
+	t.AllowV1 = allowV1
+	t.mu.Unlock()
+	return t
+}
@@ -0,0 +1,165 @@
+// Package output - render command results as json, text, table or yaml.
+//
+// Every tool prints its results through a Printer so the same sdk response looks
+// the same everywhere. Values are first converted to their json form, keyed by
+// the sdk field names, with null members dropped; -query then applies a JMESPath
+// expression and -output picks the renderer:
+//
+//     json   indented json with keys in sorted order (the default)
+//     text   tab separated values, scalar members in sorted key order
+//     table  a boxed table, one row per list element, -columns picks the columns
+//     yaml   yaml with keys in sorted order
+package output
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// Formats - the supported -output values
+var Formats = []string{"json", "text", "table", "yaml"}
+
+// DefaultFormat - format used when none is given
+const DefaultFormat = "json"
+
+// Printer - writes command results in the selected format
+type Printer struct {
+	Format  string
+	Query   string
+	Columns string
+	Out     io.Writer
+}
+
+// New - return a printer writing format to stdout, an empty format means DefaultFormat
+func New(format string) *Printer {
+	if format == "" {
+		format = DefaultFormat
+	}
+	return &Printer{Format: format, Out: os.Stdout}
+}
+
+// SetFlags - bind -output, -query and -columns to fs, the printer's current format is the default
+func (p *Printer) SetFlags(fs *flag.FlagSet) {
+	fs.StringVar(&p.Format, "output", p.Format, "output format: "+strings.Join(Formats, ", "))
+	fs.StringVar(&p.Query, "query", p.Query, "JMESPath expression applied to the result before printing. E.g. -query='Reservations[].Instances[].InstanceId'")
+	fs.StringVar(&p.Columns, "columns", p.Columns, "table and text columns as 'Header=expression,...' pairs or plain expressions. E.g. -columns='Id=InstanceId,Type=InstanceType'")
+}
+
+// Validate - check the format, query and columns so mistakes fail before any call is made
+func (p *Printer) Validate() error {
+	valid := false
+	for _, format := range Formats {
+		if p.Format == format {
+			valid = true
+		}
+	}
+	if !valid {
+		return fmt.Errorf("invalid -output '%s', expected one of: %s", p.Format, strings.Join(Formats, ", "))
+	}
+	if err := ValidateQuery(p.Query); err != nil {
+		return err
+	}
+	_, err := ParseColumns(p.Columns)
+	return err
+}
+
+// Print - apply the query to v and write it in the printer's format
+func (p *Printer) Print(v interface{}) error {
+	data, err := Query(p.Query, v)
+	if err != nil {
+		return err
+	}
+
+	out := p.Out
+	if out == nil {
+		out = os.Stdout
+	}
+
+	switch p.Format {
+	case "", "json":
+		b, err := json.MarshalIndent(data, "", "    ")
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintln(out, string(b))
+		return err
+	case "yaml":
+		b, err := yaml.Marshal(integers(data))
+		if err != nil {
+			return err
+		}
+		_, err = out.Write(b)
+		return err
+	case "text", "table":
+		columns, err := ParseColumns(p.Columns)
+		if err != nil {
+			return err
+		}
+		if p.Format == "table" {
+			return writeTable(out, data, columns)
+		}
+		if len(columns) > 0 {
+			return writeColumns(out, data, columns)
+		}
+		return writeText(out, data)
+	default:
+		return fmt.Errorf("invalid -output '%s', expected one of: %s", p.Format, strings.Join(Formats, ", "))
+	}
+}
+
+// toData - convert v to its json form, dropping null members like the python cli does
+func toData(v interface{}) (interface{}, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var data interface{}
+	if err := json.Unmarshal(b, &data); err != nil {
+		return nil, err
+	}
+	return dropNulls(data), nil
+}
+
+// dropNulls -
+func dropNulls(data interface{}) interface{} {
+	switch v := data.(type) {
+	case map[string]interface{}:
+		for key, value := range v {
+			if value == nil {
+				delete(v, key)
+				continue
+			}
+			v[key] = dropNulls(value)
+		}
+	case []interface{}:
+		for i, value := range v {
+			v[i] = dropNulls(value)
+		}
+	}
+	return data
+}
+
+// integers - turn whole float64 values back into int64 so yaml does not print them as 1.4e+09
+func integers(data interface{}) interface{} {
+	switch v := data.(type) {
+	case float64:
+		if v == float64(int64(v)) {
+			return int64(v)
+		}
+	case map[string]interface{}:
+		for key, value := range v {
+			v[key] = integers(value)
+		}
+	case []interface{}:
+		for i, value := range v {
+			v[i] = integers(value)
+		}
+	}
+	return data
+}
@@ -0,0 +1,137 @@
+package output_test
+
+import (
+	"bytes"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/sqs"
+
+	"github.com/aidevops/awscli/output"
+)
+
+var _ = Describe("Printer", func() {
+
+	var (
+		buf  *bytes.Buffer
+		p    *output.Printer
+		resp *ec2.DescribeInstancesOutput
+	)
+
+	BeforeEach(func() {
+		buf = &bytes.Buffer{}
+		p = output.New("")
+		p.Out = buf
+		resp = &ec2.DescribeInstancesOutput{
+			Reservations: []*ec2.Reservation{
+				{
+					ReservationId: aws.String("r-1"),
+					Instances: []*ec2.Instance{
+						{InstanceId: aws.String("i-1"), InstanceType: aws.String("t2.micro"), EbsOptimized: aws.Bool(false),
+							LaunchTime: aws.Time(time.Date(2016, 7, 1, 12, 0, 0, 0, time.UTC))},
+						{InstanceId: aws.String("i-2"), InstanceType: aws.String("m4.large")},
+					},
+				},
+			},
+		}
+	})
+
+	Describe("Validate", func() {
+		It("Accepts the known formats", func() {
+			for _, format := range output.Formats {
+				p.Format = format
+				Expect(p.Validate()).To(Succeed())
+			}
+		})
+		It("Rejects unknown formats, queries and columns", func() {
+			p.Format = "xml"
+			Expect(p.Validate()).NotTo(Succeed())
+			p.Format = "json"
+			p.Query = "Reservations["
+			Expect(p.Validate()).NotTo(Succeed())
+			p.Query = ""
+			p.Columns = "Id=InstanceId["
+			Expect(p.Validate()).NotTo(Succeed())
+		})
+	})
+
+	Describe("Query", func() {
+		It("Uses the sdk field names", func() {
+			result, err := output.Query("Reservations[].Instances[].InstanceId", resp)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result).To(Equal([]interface{}{"i-1", "i-2"}))
+		})
+		It("Drops null members", func() {
+			result, err := output.Query("", &sqs.SendMessageOutput{MessageId: aws.String("m-1")})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result).To(Equal(map[string]interface{}{"MessageId": "m-1"}))
+		})
+	})
+
+	Describe("json", func() {
+		It("Prints indented json", func() {
+			p.Query = "Reservations[0].Instances[1]"
+			Expect(p.Print(resp)).To(Succeed())
+			Expect(buf.String()).To(Equal("{\n    \"InstanceId\": \"i-2\",\n    \"InstanceType\": \"m4.large\"\n}\n"))
+		})
+	})
+
+	Describe("text", func() {
+		It("Prints scalar lists on one line", func() {
+			p.Format = "text"
+			p.Query = "Reservations[].Instances[].InstanceId"
+			Expect(p.Print(resp)).To(Succeed())
+			Expect(buf.String()).To(Equal("i-1\ti-2\n"))
+		})
+		It("Prints nested members under their upper cased name in sorted key order", func() {
+			p.Format = "text"
+			Expect(p.Print(resp)).To(Succeed())
+			Expect(buf.String()).To(Equal(
+				"RESERVATIONS\tr-1\n" +
+					"INSTANCES\tFalse\ti-1\tt2.micro\t2016-07-01T12:00:00Z\n" +
+					"INSTANCES\t\ti-2\tm4.large\t\n"))
+		})
+		It("Restricts rows to the selected columns", func() {
+			p.Format = "text"
+			p.Query = "Reservations[].Instances[]"
+			p.Columns = "InstanceType,InstanceId"
+			Expect(p.Print(resp)).To(Succeed())
+			Expect(buf.String()).To(Equal("t2.micro\ti-1\nm4.large\ti-2\n"))
+		})
+	})
+
+	Describe("table", func() {
+		It("Prints the selected columns with headers", func() {
+			p.Format = "table"
+			p.Query = "Reservations[].Instances[]"
+			p.Columns = "Id=InstanceId,Type=InstanceType"
+			Expect(p.Print(resp)).To(Succeed())
+			Expect(buf.String()).To(Equal(
+				"+-----+----------+\n" +
+					"| Id  | Type     |\n" +
+					"+-----+----------+\n" +
+					"| i-1 | t2.micro |\n" +
+					"| i-2 | m4.large |\n" +
+					"+-----+----------+\n"))
+		})
+		It("Defaults to the scalar members of the response's list", func() {
+			p.Format = "table"
+			Expect(p.Print(&sqs.ReceiveMessageOutput{Messages: []*sqs.Message{{Body: aws.String("hi"), MessageId: aws.String("m-1")}}})).To(Succeed())
+			Expect(buf.String()).To(ContainSubstring("| Body | MessageId |"))
+			Expect(buf.String()).To(ContainSubstring("| hi   | m-1       |"))
+		})
+	})
+
+	Describe("yaml", func() {
+		It("Prints whole numbers as integers", func() {
+			p.Format = "yaml"
+			p.Query = "{Count: length(Reservations[].Instances[]), Size: `1460000000`}"
+			Expect(p.Print(resp)).To(Succeed())
+			Expect(buf.String()).To(Equal("Count: 2\nSize: 1460000000\n"))
+		})
+	})
+})
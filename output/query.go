@@ -0,0 +1,40 @@
+// Package output -
+package output
+
+import (
+	"fmt"
+
+	"github.com/jmespath/go-jmespath"
+)
+
+// ValidateQuery - parse a JMESPath expression so a typo fails before any call is made
+func ValidateQuery(expression string) error {
+	if expression == "" {
+		return nil
+	}
+	if _, err := jmespath.Compile(expression); err != nil {
+		return fmt.Errorf("invalid -query '%s': %s", expression, err)
+	}
+	return nil
+}
+
+// Query - apply a JMESPath expression to v, an sdk response or anything encoding/json accepts.
+//
+// v is converted to its json form first so expressions use the same keys as the printed
+// json, the sdk field names (e.g. 'Reservations[].Instances[].InstanceId'), and an empty
+// expression returns that json form unchanged.
+func Query(expression string, v interface{}) (interface{}, error) {
+	data, err := toData(v)
+	if err != nil {
+		return nil, err
+	}
+	if expression == "" {
+		return data, nil
+	}
+
+	result, err := jmespath.Search(expression, data)
+	if err != nil {
+		return nil, fmt.Errorf("-query '%s': %s", expression, err)
+	}
+	return result, nil
+}
@@ -0,0 +1,178 @@
+// Package output -
+package output
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/jmespath/go-jmespath"
+)
+
+// Column - a table or text column, Expression is evaluated against each row
+type Column struct {
+	Header     string
+	Expression string
+	path       *jmespath.JMESPath
+}
+
+// ParseColumns - parse 'Header=expression,...', a plain expression is also its own header
+func ParseColumns(spec string) ([]Column, error) {
+	var columns []Column
+	if strings.TrimSpace(spec) == "" {
+		return columns, nil
+	}
+	for _, field := range strings.Split(spec, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+		column := Column{Header: field, Expression: field}
+		if pos := strings.Index(field, "="); pos > 0 && !strings.ContainsAny(field[:pos], "[]().`'") {
+			column.Header = strings.TrimSpace(field[:pos])
+			column.Expression = strings.TrimSpace(field[pos+1:])
+		}
+		path, err := jmespath.Compile(column.Expression)
+		if err != nil {
+			return nil, fmt.Errorf("invalid -columns expression '%s': %s", column.Expression, err)
+		}
+		column.path = path
+		columns = append(columns, column)
+	}
+	return columns, nil
+}
+
+// rows - the elements a table shows: a list's elements, the single list held by a
+// response such as {"Reservations": [...]}, or the value itself
+func rows(data interface{}) []interface{} {
+	switch v := data.(type) {
+	case []interface{}:
+		return v
+	case map[string]interface{}:
+		var lists []string
+		for key, value := range v {
+			if _, ok := value.([]interface{}); ok {
+				lists = append(lists, key)
+			}
+		}
+		if len(lists) == 1 {
+			return v[lists[0]].([]interface{})
+		}
+	case nil:
+		return nil
+	}
+	return []interface{}{data}
+}
+
+// defaultColumns - one column per scalar member across the rows, or a single Value
+// column for rows that are not dicts
+func defaultColumns(list []interface{}) []Column {
+	for _, row := range list {
+		if _, ok := row.(map[string]interface{}); !ok {
+			return []Column{{Header: "Value", Expression: "@"}}
+		}
+	}
+	var columns []Column
+	for _, key := range scalarKeysOf(list) {
+		columns = append(columns, Column{Header: key, Expression: key})
+	}
+	return columns
+}
+
+// cells - evaluate the columns against a row
+func cells(row interface{}, columns []Column) []string {
+	values := make([]string, len(columns))
+	for i, column := range columns {
+		var value interface{}
+		if column.path != nil {
+			value, _ = column.path.Search(row)
+		} else if column.Expression == "@" {
+			value = row
+		} else if dict, ok := row.(map[string]interface{}); ok {
+			value = dict[column.Expression]
+		}
+		values[i] = cell(value)
+	}
+	return values
+}
+
+// cell - scalars as in text output, lists of scalars comma separated, anything else as json
+func cell(v interface{}) string {
+	switch value := v.(type) {
+	case nil:
+		return ""
+	case []interface{}:
+		parts := make([]string, 0, len(value))
+		for _, item := range value {
+			if !isScalar(item) {
+				return scalar(v)
+			}
+			parts = append(parts, scalar(item))
+		}
+		return strings.Join(parts, ",")
+	}
+	return scalar(v)
+}
+
+// writeColumns - text output restricted to columns, one tab separated line per row
+func writeColumns(w io.Writer, data interface{}, columns []Column) error {
+	var buf bytes.Buffer
+	for _, row := range rows(data) {
+		buf.WriteString(strings.Join(cells(row, columns), "\t") + "\n")
+	}
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// writeTable - a boxed table with a header row
+func writeTable(w io.Writer, data interface{}, columns []Column) error {
+	list := rows(data)
+	if len(columns) == 0 {
+		columns = defaultColumns(list)
+	}
+	if len(columns) == 0 {
+		return nil
+	}
+
+	table := [][]string{make([]string, len(columns))}
+	for i, column := range columns {
+		table[0][i] = column.Header
+	}
+	for _, row := range list {
+		table = append(table, cells(row, columns))
+	}
+
+	widths := make([]int, len(columns))
+	for _, line := range table {
+		for i, value := range line {
+			if n := utf8.RuneCountInString(value); n > widths[i] {
+				widths[i] = n
+			}
+		}
+	}
+
+	var buf bytes.Buffer
+	separator := "+"
+	for _, width := range widths {
+		separator += strings.Repeat("-", width+2) + "+"
+	}
+	separator += "\n"
+
+	buf.WriteString(separator)
+	for n, line := range table {
+		buf.WriteString("|")
+		for i, value := range line {
+			buf.WriteString(" " + value + strings.Repeat(" ", widths[i]-utf8.RuneCountInString(value)) + " |")
+		}
+		buf.WriteString("\n")
+		if n == 0 {
+			buf.WriteString(separator)
+		}
+	}
+	buf.WriteString(separator)
+
+	_, err := w.Write(buf.Bytes())
+	return err
+}
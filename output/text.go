@@ -0,0 +1,185 @@
+// Package output -
+package output
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// writeText - tab separated output laid out the way the python cli's text format does:
+// a dict's scalar members go on one line in sorted key order, prefixed by the upper
+// cased name of the member holding it, nested lists and dicts follow on their own lines
+func writeText(w io.Writer, data interface{}) error {
+	var buf bytes.Buffer
+	formatText(&buf, data, "", nil)
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// formatText -
+func formatText(buf *bytes.Buffer, data interface{}, identifier string, scalarKeys []string) {
+	switch v := data.(type) {
+	case map[string]interface{}:
+		formatDict(buf, v, identifier, scalarKeys)
+	case []interface{}:
+		formatList(buf, v, identifier)
+	default:
+		buf.WriteString(scalar(v))
+		buf.WriteString("\n")
+	}
+}
+
+// formatList -
+func formatList(buf *bytes.Buffer, list []interface{}, identifier string) {
+	if len(list) == 0 {
+		return
+	}
+
+	var scalars, nonScalars []interface{}
+	hasDict := false
+	for _, item := range list {
+		switch item.(type) {
+		case map[string]interface{}:
+			hasDict = true
+			nonScalars = append(nonScalars, item)
+		case []interface{}:
+			nonScalars = append(nonScalars, item)
+		default:
+			scalars = append(scalars, item)
+		}
+	}
+
+	// rows of dicts share one column layout, the union of their scalar keys
+	if hasDict {
+		keys := scalarKeysOf(list)
+		for _, item := range list {
+			formatText(buf, item, identifier, keys)
+		}
+		return
+	}
+
+	if len(scalars) > 0 {
+		formatScalarList(buf, scalars, identifier)
+	}
+	for _, item := range nonScalars {
+		formatText(buf, item, identifier, nil)
+	}
+}
+
+// formatScalarList -
+func formatScalarList(buf *bytes.Buffer, list []interface{}, identifier string) {
+	if identifier != "" {
+		for _, item := range list {
+			buf.WriteString(strings.ToUpper(identifier) + "\t" + scalar(item) + "\n")
+		}
+		return
+	}
+	cells := make([]string, len(list))
+	for i, item := range list {
+		cells[i] = scalar(item)
+	}
+	buf.WriteString(strings.Join(cells, "\t") + "\n")
+}
+
+// formatDict -
+func formatDict(buf *bytes.Buffer, dict map[string]interface{}, identifier string, scalarKeys []string) {
+	var scalars []string
+	var nested []string
+	if scalarKeys == nil {
+		for _, key := range sortedKeys(dict) {
+			if isScalar(dict[key]) {
+				scalars = append(scalars, scalar(dict[key]))
+			} else {
+				nested = append(nested, key)
+			}
+		}
+	} else {
+		for _, key := range scalarKeys {
+			value, ok := dict[key]
+			if !ok {
+				scalars = append(scalars, "")
+				continue
+			}
+			scalars = append(scalars, scalar(value))
+		}
+		for _, key := range sortedKeys(dict) {
+			if !isScalar(dict[key]) {
+				nested = append(nested, key)
+			}
+		}
+	}
+
+	if len(scalars) > 0 {
+		if identifier != "" {
+			scalars = append([]string{strings.ToUpper(identifier)}, scalars...)
+		}
+		buf.WriteString(strings.Join(scalars, "\t") + "\n")
+	}
+	for _, key := range nested {
+		formatText(buf, dict[key], key, nil)
+	}
+}
+
+// scalarKeysOf - sorted union of the scalar member names of the dicts in list
+func scalarKeysOf(list []interface{}) []string {
+	seen := make(map[string]bool)
+	for _, item := range list {
+		if dict, ok := item.(map[string]interface{}); ok {
+			for key, value := range dict {
+				if isScalar(value) {
+					seen[key] = true
+				}
+			}
+		}
+	}
+	keys := make([]string, 0, len(seen))
+	for key := range seen {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// isScalar -
+func isScalar(v interface{}) bool {
+	switch v.(type) {
+	case map[string]interface{}, []interface{}:
+		return false
+	}
+	return true
+}
+
+// scalar - render a json value for text and table cells, nulls and booleans are
+// spelled the way the python cli prints them so scripts keep working
+func scalar(v interface{}) string {
+	switch value := v.(type) {
+	case nil:
+		return "None"
+	case string:
+		return value
+	case bool:
+		if value {
+			return "True"
+		}
+		return "False"
+	case float64:
+		return strconv.FormatFloat(value, 'f', -1, 64)
+	default:
+		b, _ := json.Marshal(value)
+		return string(b)
+	}
+}
+
+// sortedKeys -
+func sortedKeys(dict map[string]interface{}) []string {
+	keys := make([]string, 0, len(dict))
+	for key := range dict {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
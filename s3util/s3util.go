@@ -0,0 +1,86 @@
+// Package s3util - upload and download s3 objects
+package s3util
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3iface"
+	"github.com/aws/aws-sdk-go/service/s3/s3manager"
+)
+
+// GetResult - what a download fetched
+type GetResult struct {
+	Bucket        string
+	Key           string
+	File          string
+	ContentLength int64
+}
+
+// Put - upload body to bucket/key, multipart for large bodies. The upload stops
+// with ctx's error at the next read of body once ctx is done.
+func Put(ctx context.Context, svc s3iface.S3API, bucket, key string, body io.Reader) (*s3manager.UploadOutput, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	uploader := s3manager.NewUploaderWithClient(svc)
+	resp, err := uploader.Upload(&s3manager.UploadInput{
+		Body:   &reader{ctx: ctx, r: body},
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Could not put '%s' into bucket '%s': %s", key, bucket, err)
+	}
+	return resp, nil
+}
+
+// Get - download bucket/key into w, returning the number of bytes written. The
+// download stops with ctx's error at the next write to w once ctx is done.
+func Get(ctx context.Context, svc s3iface.S3API, bucket, key string, w io.WriterAt) (int64, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	downloader := s3manager.NewDownloaderWithClient(svc)
+	n, err := downloader.Download(&writerAt{ctx: ctx, w: w}, &s3.GetObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return n, fmt.Errorf("Could not get '%s' from bucket '%s': %s", key, bucket, err)
+	}
+	return n, nil
+}
+
+// reader - io.Reader failing with its context's error once the context is done
+type reader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+// Read -
+func (r *reader) Read(p []byte) (int, error) {
+	if err := r.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return r.r.Read(p)
+}
+
+// writerAt - io.WriterAt failing with its context's error once the context is done
+type writerAt struct {
+	ctx context.Context
+	w   io.WriterAt
+}
+
+// WriteAt -
+func (w *writerAt) WriteAt(p []byte, off int64) (int, error) {
+	if err := w.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return w.w.WriteAt(p, off)
+}
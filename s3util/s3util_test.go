@@ -0,0 +1,57 @@
+package s3util_test
+
+import (
+	"bytes"
+	"context"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+
+	"github.com/aidevops/awscli/fakeaws"
+	"github.com/aidevops/awscli/s3util"
+)
+
+var _ = Describe("S3util", func() {
+
+	var (
+		srv *fakeaws.Server
+		svc *s3.S3
+	)
+
+	BeforeEach(func() {
+		srv = fakeaws.New()
+		srv.CreateBucket("bucket")
+		cli := srv.AwsCli()
+		svc = s3.New(cli.Session(), cli.ServiceConfig(s3.ServiceName))
+	})
+
+	AfterEach(func() {
+		srv.Close()
+	})
+
+	It("Puts and gets objects", func() {
+		_, err := s3util.Put(context.Background(), svc, "bucket", "path/file.txt", bytes.NewBufferString("hello world"))
+		Expect(err).NotTo(HaveOccurred())
+		body, ok := srv.Object("bucket", "path/file.txt")
+		Expect(ok).To(BeTrue())
+		Expect(string(body)).To(Equal("hello world"))
+
+		buf := &aws.WriteAtBuffer{}
+		n, err := s3util.Get(context.Background(), svc, "bucket", "path/file.txt", buf)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(n).To(BeEquivalentTo(11))
+		Expect(string(buf.Bytes())).To(Equal("hello world"))
+	})
+
+	It("Stops once the context is done", func() {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		_, err := s3util.Put(ctx, svc, "bucket", "file.txt", bytes.NewBufferString("hello"))
+		Expect(err).To(Equal(context.Canceled))
+		_, found := srv.Object("bucket", "file.txt")
+		Expect(found).To(BeFalse())
+	})
+})
@@ -0,0 +1,195 @@
+package sgregister
+
+import (
+	"fmt"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+
+	"github.com/aidevops/awscli/ec2ext"
+)
+
+// Permission - a single rule: one protocol and port range from, or to for
+// egress, one peer. Peer is an ipv4 or ipv6 cidr, a prefix list id, or a group
+// id, prefixed with its account id and a '/' when in another account.
+type Permission struct {
+	Egress      bool `json:",omitempty"`
+	Protocol    string
+	FromPort    int64
+	ToPort      int64
+	Peer        string
+	Description string `json:",omitempty"`
+}
+
+// protocolNames - the protocol numbers ec2 describes by name
+var protocolNames = map[string]string{"1": "icmp", "6": "tcp", "17": "udp", "58": "icmpv6"}
+
+// groupPeer - a security group peer, 'sg-1a2b' or '123456789012/sg-1a2b'
+var groupPeer = regexp.MustCompile(`^(?:(\d{12})/)?(sg-[0-9a-f]+)$`)
+
+// NewPermission - a validated Permission, its protocol named as ec2 describes
+// it: -1 for all, tcp, udp, icmp, icmpv6 or a number. Tcp and udp take a
+// port range, icmp and icmpv6 a type and code, -1 for any, as from and to.
+// Other protocols have no ports, from and to must be 0 or -1.
+func NewPermission(egress bool, protocol string, from, to int64, peer, description string) (Permission, error) {
+	p := Permission{Egress: egress, Protocol: protocolName(protocol), FromPort: from, ToPort: to, Peer: peer, Description: description}
+
+	switch p.Protocol {
+	case "tcp", "udp":
+		if from < 0 || to > 65535 || from > to {
+			return p, fmt.Errorf("%s port range %d-%d isn't within 0-65535", p.Protocol, from, to)
+		}
+	case "icmp", "icmpv6":
+		if from < -1 || from > 255 || to < -1 || to > 255 {
+			return p, fmt.Errorf("%s type %d and code %d must be -1 for any or 0-255", p.Protocol, from, to)
+		}
+		if from == -1 && to != -1 {
+			return p, fmt.Errorf("%s code %d needs a type", p.Protocol, to)
+		}
+	case "":
+		return p, fmt.Errorf("no protocol")
+	default:
+		if n, err := strconv.Atoi(p.Protocol); p.Protocol != "-1" && (err != nil || n < 0 || n > 255) {
+			return p, fmt.Errorf("unknown protocol '%s', expected tcp, udp, icmp, icmpv6, all, -1 or 0-255", protocol)
+		}
+		if (from != 0 && from != -1) || (to != 0 && to != -1) {
+			return p, fmt.Errorf("protocol %s has no ports, only tcp and udp do", p.Protocol)
+		}
+		p.FromPort, p.ToPort = 0, 0
+	}
+
+	switch {
+	case groupPeer.MatchString(peer), strings.HasPrefix(peer, "pl-"):
+	default:
+		ip, cidr, err := net.ParseCIDR(peer)
+		if err != nil {
+			return p, fmt.Errorf("peer '%s' is neither a cidr, group id nor prefix list", peer)
+		}
+		// ec2 keeps cidrs as their network, lower case
+		p.Peer = cidr.String()
+		if ip.To4() == nil && p.Protocol == "icmp" || ip.To4() != nil && p.Protocol == "icmpv6" {
+			return p, fmt.Errorf("%s doesn't apply to %s", p.Protocol, peer)
+		}
+	}
+	return p, nil
+}
+
+// protocolName - the protocol as ec2 describes it
+func protocolName(protocol string) string {
+	protocol = strings.ToLower(strings.TrimSpace(protocol))
+	if name, ok := protocolNames[protocol]; ok {
+		return name
+	}
+	if protocol == "all" {
+		return "-1"
+	}
+	return protocol
+}
+
+// Key - identity of the permission, its description aside
+func (p Permission) Key() string {
+	return fmt.Sprintf("%t|%s|%d|%d|%s", p.Egress, p.Protocol, p.FromPort, p.ToPort, p.Peer)
+}
+
+// String - e.g. 'ingress tcp 443 from 10.0.0.0/8 "https"'
+func (p Permission) String() string {
+	direction, preposition := "ingress", "from"
+	if p.Egress {
+		direction, preposition = "egress", "to"
+	}
+
+	var ports string
+	switch p.Protocol {
+	case "tcp", "udp":
+		ports = fmt.Sprintf(" %d", p.FromPort)
+		if p.ToPort != p.FromPort {
+			ports = fmt.Sprintf(" %d-%d", p.FromPort, p.ToPort)
+		}
+	case "icmp", "icmpv6":
+		ports = fmt.Sprintf(" type %d code %d", p.FromPort, p.ToPort)
+	}
+	protocol := p.Protocol
+	if protocol == "-1" {
+		protocol = "all"
+	}
+
+	s := fmt.Sprintf("%s %s%s %s %s", direction, protocol, ports, preposition, p.Peer)
+	if p.Description != "" {
+		s += fmt.Sprintf(" %q", p.Description)
+	}
+	return s
+}
+
+// IPPermission - the permission as the ec2 api takes it
+func (p Permission) IPPermission() *ec2ext.IpPermission {
+	perm := &ec2ext.IpPermission{IpProtocol: aws.String(p.Protocol)}
+	switch p.Protocol {
+	case "tcp", "udp", "icmp", "icmpv6":
+		perm.FromPort, perm.ToPort = aws.Int64(p.FromPort), aws.Int64(p.ToPort)
+	}
+
+	var description *string
+	if p.Description != "" {
+		description = aws.String(p.Description)
+	}
+	if m := groupPeer.FindStringSubmatch(p.Peer); m != nil {
+		pair := &ec2ext.UserIdGroupPair{GroupId: aws.String(m[2]), Description: description}
+		if m[1] != "" {
+			pair.UserId = aws.String(m[1])
+		}
+		perm.UserIdGroupPairs = []*ec2ext.UserIdGroupPair{pair}
+		return perm
+	}
+	if strings.HasPrefix(p.Peer, "pl-") {
+		perm.PrefixListIds = []*ec2ext.PrefixListId{{PrefixListId: aws.String(p.Peer), Description: description}}
+		return perm
+	}
+	if ip, _, _ := net.ParseCIDR(p.Peer); ip != nil && ip.To4() == nil {
+		perm.Ipv6Ranges = []*ec2ext.Ipv6Range{{CidrIpv6: aws.String(p.Peer), Description: description}}
+		return perm
+	}
+	perm.IpRanges = []*ec2ext.IpRange{{CidrIp: aws.String(p.Peer), Description: description}}
+	return perm
+}
+
+// Flatten - the single permissions of described rules, owner is the group's
+// account, whose group peers go without it
+func Flatten(perms []*ec2ext.IpPermission, egress bool, owner string) []Permission {
+	var flat []Permission
+	for _, perm := range perms {
+		base := Permission{Egress: egress, Protocol: aws.StringValue(perm.IpProtocol)}
+		if name, ok := protocolNames[base.Protocol]; ok {
+			base.Protocol = name
+		}
+		switch base.Protocol {
+		case "tcp", "udp", "icmp", "icmpv6":
+			base.FromPort, base.ToPort = aws.Int64Value(perm.FromPort), aws.Int64Value(perm.ToPort)
+		}
+
+		add := func(peer string, description *string) {
+			p := base
+			p.Peer, p.Description = peer, aws.StringValue(description)
+			flat = append(flat, p)
+		}
+		for _, r := range perm.IpRanges {
+			add(aws.StringValue(r.CidrIp), r.Description)
+		}
+		for _, r := range perm.Ipv6Ranges {
+			add(aws.StringValue(r.CidrIpv6), r.Description)
+		}
+		for _, pair := range perm.UserIdGroupPairs {
+			peer := aws.StringValue(pair.GroupId)
+			if user := aws.StringValue(pair.UserId); user != "" && user != owner {
+				peer = user + "/" + peer
+			}
+			add(peer, pair.Description)
+		}
+		for _, list := range perm.PrefixListIds {
+			add(aws.StringValue(list.PrefixListId), list.Description)
+		}
+	}
+	return flat
+}
@@ -0,0 +1,98 @@
+package sgregister
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+
+	"github.com/aidevops/awscli"
+	"github.com/aidevops/awscli/ec2ext"
+)
+
+// Expired - a rule whose expiry has passed, that Reap revoked or would have
+type Expired struct {
+	GroupID   string `json:"GroupId"`
+	GroupName string
+	Expires   time.Time
+	Permission
+}
+
+// expiryPattern - the expiry Register records in a rule's description
+var expiryPattern = regexp.MustCompile(`(?:^|\s)sg_register expires (\S+)`)
+
+// expiryDescription - the description of a rule expiring at t
+func expiryDescription(t time.Time) string {
+	return "sg_register expires " + t.UTC().Format(time.RFC3339)
+}
+
+// expiry - the expiry recorded in a rule's description, if any
+func expiry(description string) (time.Time, bool) {
+	m := expiryPattern.FindStringSubmatch(description)
+	if m == nil {
+		return time.Time{}, false
+	}
+	t, err := time.Parse(time.RFC3339, m[1])
+	return t, err == nil
+}
+
+// Reap - revoke the rules of the groups ids, every group when there are none,
+// whose expiry has passed by now. Groups failing to revoke don't stop the
+// others, their errors are returned together with what was revoked.
+func Reap(ctx context.Context, svc ec2ext.Client, ids []string, now time.Time, dryrun bool) ([]Expired, error) {
+	input := &ec2.DescribeSecurityGroupsInput{}
+	if len(ids) > 0 {
+		input.GroupIds = aws.StringSlice(ids)
+	}
+	req, out := ec2ext.DescribeSecurityGroupsRequest(svc, input)
+	if err := awscli.Send(ctx, req); err != nil {
+		return nil, err
+	}
+
+	reaped := []Expired{}
+	var errs []string
+	for _, sg := range out.SecurityGroups {
+		owner := aws.StringValue(sg.OwnerId)
+		plan := &Plan{GroupID: aws.StringValue(sg.GroupId)}
+		var expired []Expired
+		for _, p := range append(Flatten(sg.IpPermissions, false, owner), Flatten(sg.IpPermissionsEgress, true, owner)...) {
+			if t, ok := expiry(p.Description); ok && !t.After(now) {
+				plan.Revoke = append(plan.Revoke, p)
+				expired = append(expired, Expired{GroupID: plan.GroupID, GroupName: aws.StringValue(sg.GroupName), Expires: t, Permission: p})
+			}
+		}
+		if plan.Empty() {
+			continue
+		}
+		if !dryrun {
+			if err := Apply(ctx, svc, plan); err != nil {
+				errs = append(errs, fmt.Sprintf("%s: %s", plan.GroupID, err))
+				continue
+			}
+		}
+		reaped = append(reaped, expired...)
+	}
+
+	sort.Sort(byGroupExpiry(reaped))
+	if len(errs) > 0 {
+		return reaped, fmt.Errorf("failed to revoke expired rules of %s", strings.Join(errs, "; "))
+	}
+	return reaped, nil
+}
+
+// byGroupExpiry - sorts by group id, then expiry
+type byGroupExpiry []Expired
+
+func (e byGroupExpiry) Len() int      { return len(e) }
+func (e byGroupExpiry) Swap(i, j int) { e[i], e[j] = e[j], e[i] }
+func (e byGroupExpiry) Less(i, j int) bool {
+	if e[i].GroupID != e[j].GroupID {
+		return e[i].GroupID < e[j].GroupID
+	}
+	return e[i].Expires.Before(e[j].Expires)
+}
@@ -0,0 +1,128 @@
+package sgregister_test
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+
+	"github.com/aidevops/awscli/fakeaws"
+	"github.com/aidevops/awscli/sgregister"
+)
+
+var _ = Describe("Reap", func() {
+
+	var (
+		srv *fakeaws.Server
+		svc *ec2.EC2
+		web string
+		db  string
+		now time.Time
+	)
+
+	BeforeEach(func() {
+		srv = fakeaws.New()
+		cli := srv.AwsCli()
+		svc = ec2.New(cli.Session(), cli.ServiceConfig(ec2.ServiceName))
+		web = srv.CreateSecurityGroup("web", "vpc-1")
+		db = srv.CreateSecurityGroup("db", "vpc-1")
+		now = time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+
+		register := func(group, cidr string, expires time.Time) {
+			rule := sgregister.Rule{GroupID: group, CidrIPs: []string{cidr}, IPProtocol: "tcp", FromPort: 22, ToPort: 22}
+			if !expires.IsZero() {
+				rule.Expires = &expires
+			}
+			_, err := sgregister.Register(context.Background(), svc, rule, false)
+			Expect(err).NotTo(HaveOccurred())
+		}
+		register(web, "10.0.0.1/32", now.Add(-time.Minute))
+		register(web, "10.0.0.2/32", now.Add(time.Minute))
+		register(web, "10.0.0.3/32", time.Time{})
+		register(db, "10.0.0.4/32", now.Add(-time.Hour))
+	})
+
+	AfterEach(func() {
+		srv.Close()
+	})
+
+	cidrs := func(id string) []string {
+		var cidrs []string
+		for _, perm := range srv.SecurityGroup(id).IpPermissions {
+			for _, r := range perm.IpRanges {
+				cidrs = append(cidrs, aws.StringValue(r.CidrIp))
+			}
+		}
+		return cidrs
+	}
+
+	It("Records the expiry in the rule's description", func() {
+		ranges := srv.SecurityGroup(web).IpPermissions[0].IpRanges
+		Expect(aws.StringValue(ranges[0].Description)).To(Equal("sg_register expires 2026-10-18T11:59:00Z"))
+		Expect(ranges[2].Description).To(BeNil())
+	})
+
+	It("Renews the expiry of a rule registered again", func() {
+		expires := now.Add(time.Hour)
+		rule := sgregister.Rule{GroupID: web, CidrIPs: []string{"10.0.0.2/32"}, IPProtocol: "tcp", FromPort: 22, ToPort: 22, Expires: &expires}
+		result, err := sgregister.Register(context.Background(), svc, rule, false)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result.Unchanged).To(BeFalse())
+
+		_, err = sgregister.Reap(context.Background(), svc, []string{web}, now.Add(30*time.Minute), false)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(cidrs(web)).To(ConsistOf("10.0.0.2/32", "10.0.0.3/32"))
+
+		result, err = sgregister.Register(context.Background(), svc, rule, false)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result.Unchanged).To(BeTrue())
+	})
+
+	It("Leaves a permanent rule registered again for a while permanent", func() {
+		office, err := sgregister.NewPermission(false, "tcp", 443, 443, "10.0.1.0/24", "office")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(sgregister.Apply(context.Background(), svc, &sgregister.Plan{GroupID: db, Authorize: []sgregister.Permission{office}})).To(Succeed())
+
+		expires := now.Add(-time.Minute)
+		rule := sgregister.Rule{GroupID: db, CidrIPs: []string{"10.0.1.0/24"}, IPProtocol: "tcp", FromPort: 443, ToPort: 443, Expires: &expires}
+		result, err := sgregister.Register(context.Background(), svc, rule, false)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result.Unchanged).To(BeTrue())
+
+		_, err = sgregister.Reap(context.Background(), svc, []string{db}, now, false)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(cidrs(db)).To(ConsistOf("10.0.1.0/24"))
+		ranges := srv.SecurityGroup(db).IpPermissions[0].IpRanges
+		Expect(aws.StringValue(ranges[0].Description)).To(Equal("office"))
+	})
+
+	It("Revokes the rules whose expiry has passed", func() {
+		reaped, err := sgregister.Reap(context.Background(), svc, nil, now, false)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(reaped).To(HaveLen(2))
+		Expect([]string{reaped[0].Peer, reaped[1].Peer}).To(ConsistOf("10.0.0.1/32", "10.0.0.4/32"))
+		Expect(cidrs(web)).To(ConsistOf("10.0.0.2/32", "10.0.0.3/32"))
+		Expect(cidrs(db)).To(BeEmpty())
+	})
+
+	It("Only reaps the groups given", func() {
+		reaped, err := sgregister.Reap(context.Background(), svc, []string{web}, now, false)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(reaped).To(HaveLen(1))
+		Expect(reaped[0].Peer).To(Equal("10.0.0.1/32"))
+		Expect(reaped[0].GroupName).To(Equal("web"))
+		Expect(cidrs(db)).To(HaveLen(1))
+	})
+
+	It("Revokes nothing on a dry run", func() {
+		reaped, err := sgregister.Reap(context.Background(), svc, nil, now, true)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(reaped).To(HaveLen(2))
+		Expect(cidrs(web)).To(HaveLen(3))
+		Expect(cidrs(db)).To(HaveLen(1))
+	})
+})
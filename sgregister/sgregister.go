@@ -0,0 +1,278 @@
+// Package sgregister - authorize and revoke security group ingress and egress
+package sgregister
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/ec2/ec2iface"
+
+	"github.com/aidevops/awscli"
+	"github.com/aidevops/awscli/ec2ext"
+	"github.com/aidevops/awscli/ec2info"
+)
+
+// Rule - ingress, or egress, of a security group from, or to, ipv4 and ipv6
+// cidrs and other groups: names in the group's vpc, ids, or ids of another
+// account as 'account/sg-1a2b'. GroupName is looked up when GroupID is not
+// set, among the groups of VpcID having GroupTags when set. IPProtocol,
+// FromPort and ToPort are as NewPermission takes them. A rule that Expires is
+// registered with its expiry in the description, for Reap to revoke it once
+// passed.
+type Rule struct {
+	GroupID    string            `json:"GroupId,omitempty"`
+	GroupName  string            `json:",omitempty"`
+	VpcID      string            `json:"VpcId,omitempty"`
+	GroupTags  map[string]string `json:",omitempty"`
+	Egress     bool              `json:",omitempty"`
+	CidrIPs    []string          `json:"CidrIps,omitempty"`
+	Groups     []string          `json:",omitempty"`
+	IPProtocol string            `json:"IpProtocol"`
+	FromPort   int64
+	ToPort     int64
+	Expires    *time.Time `json:",omitempty"`
+}
+
+// EC2 - the ec2 api sgregister uses, an *ec2.EC2 is one
+type EC2 interface {
+	ec2iface.EC2API
+	ec2ext.Client
+}
+
+// Result - the rule Register or Deregister applied. Unchanged is set when the
+// group already had all of the rule registered, or deregistered.
+type Result struct {
+	Action    string
+	Unchanged bool `json:",omitempty"`
+	Rule
+}
+
+// unchangedCodes - the errors of an operation finding a permission already as
+// asked, or gone
+var unchangedCodes = map[string]string{
+	ec2ext.AuthorizeIngress:          "InvalidPermission.Duplicate",
+	ec2ext.AuthorizeEgress:           "InvalidPermission.Duplicate",
+	ec2ext.RevokeIngress:             "InvalidPermission.NotFound",
+	ec2ext.RevokeEgress:              "InvalidPermission.NotFound",
+	ec2ext.UpdateIngressDescriptions: "InvalidPermission.NotFound",
+	ec2ext.UpdateEgressDescriptions:  "InvalidPermission.NotFound",
+}
+
+// Register - authorize the rule's permissions the group doesn't have yet and
+// renew the expiry of those it has that expire, a group having them all as
+// asked is an Unchanged result
+func Register(ctx context.Context, svc EC2, rule Rule, dryrun bool) (*Result, error) {
+	operation := ec2ext.AuthorizeIngress
+	if rule.Egress {
+		operation = ec2ext.AuthorizeEgress
+	}
+	unchanged, err := apply(ctx, svc, operation, rule, dryrun)
+	if err != nil {
+		return nil, err
+	}
+	return &Result{Action: "register", Unchanged: unchanged, Rule: rule}, nil
+}
+
+// Deregister - revoke the rule's permissions the group has, a group having
+// none of them is an Unchanged result
+func Deregister(ctx context.Context, svc EC2, rule Rule, dryrun bool) (*Result, error) {
+	operation := ec2ext.RevokeIngress
+	if rule.Egress {
+		operation = ec2ext.RevokeEgress
+	}
+	unchanged, err := apply(ctx, svc, operation, rule, dryrun)
+	if err != nil {
+		return nil, err
+	}
+	return &Result{Action: "deregister", Unchanged: unchanged, Rule: rule}, nil
+}
+
+// String - e.g. 'ingress tcp 443 from 10.0.0.0/8, 2001:db8::/32, web'
+func (r Rule) String() string {
+	peers := append(append([]string{}, r.CidrIPs...), r.Groups...)
+	return Permission{Egress: r.Egress, Protocol: protocolName(r.IPProtocol), FromPort: r.FromPort, ToPort: r.ToPort, Peer: strings.Join(peers, ", ")}.String()
+}
+
+// Permissions - the rule's single permissions in group, its group names
+// looked up in group's vpc
+func (r Rule) Permissions(ctx context.Context, svc ec2iface.EC2API, group *Group) ([]Permission, error) {
+	peers := append([]string{}, r.CidrIPs...)
+	for _, name := range r.Groups {
+		if !groupPeer.MatchString(name) {
+			id, err := LookupSGID(ctx, svc, name, GroupFilters(group.VpcID, nil))
+			if err != nil {
+				return nil, fmt.Errorf("failed to lookup sg '%s' by name: %s", name, err)
+			}
+			name = id
+		}
+		peers = append(peers, name)
+	}
+	if len(peers) == 0 {
+		return nil, fmt.Errorf("no cidrs or groups to register")
+	}
+
+	var description string
+	if r.Expires != nil {
+		description = expiryDescription(*r.Expires)
+	}
+	var perms []Permission
+	for _, peer := range peers {
+		p, err := NewPermission(r.Egress, r.IPProtocol, r.FromPort, r.ToPort, peer, description)
+		if err != nil {
+			return nil, err
+		}
+		perms = append(perms, p)
+	}
+	return perms, nil
+}
+
+// LookupSGID - the id of the one security group named name matching filters,
+// see GroupFilters. Several groups matching is an error listing them, as is
+// none. The lookup is never a dry run, a dry run needs the id too.
+func LookupSGID(ctx context.Context, svc ec2iface.EC2API, name string, filters []*ec2.Filter) (string, error) {
+	req, resp := svc.DescribeSecurityGroupsRequest(&ec2.DescribeSecurityGroupsInput{
+		Filters: append([]*ec2.Filter{{Name: aws.String("group-name"), Values: aws.StringSlice([]string{name})}}, filters...),
+	})
+	if err := awscli.Send(ctx, req); err != nil {
+		return "", err
+	}
+
+	var candidates []string
+	for _, sg := range resp.SecurityGroups {
+		candidate := aws.StringValue(sg.GroupId)
+		if vpc := aws.StringValue(sg.VpcId); vpc != "" {
+			candidate += " (" + vpc + ")"
+		}
+		candidates = append(candidates, candidate)
+	}
+	sort.Strings(candidates)
+
+	var matching string
+	for _, f := range filters {
+		matching += fmt.Sprintf(" %s=%s", aws.StringValue(f.Name), strings.Join(aws.StringValueSlice(f.Values), "|"))
+	}
+	switch len(candidates) {
+	case 0:
+		if matching != "" {
+			return "", fmt.Errorf("no security group named '%s' matches%s", name, matching)
+		}
+		return "", fmt.Errorf("no security group named '%s'", name)
+	case 1:
+		return aws.StringValue(resp.SecurityGroups[0].GroupId), nil
+	}
+	return "", fmt.Errorf("%d security groups named '%s' match, narrow it down by vpc or tags: %s", len(candidates), name, strings.Join(candidates, ", "))
+}
+
+// GroupFilters - the filters of the groups in vpcID, when set, having tags,
+// a bare key matching any value and several values separated by '|'
+func GroupFilters(vpcID string, tags map[string]string) []*ec2.Filter {
+	var filters []*ec2.Filter
+	if vpcID != "" {
+		filters = append(filters, &ec2.Filter{Name: aws.String("vpc-id"), Values: aws.StringSlice([]string{vpcID})})
+	}
+	return append(filters, ec2info.TagFilters(tags)...)
+}
+
+// applyAttempts - how often apply describes the group and sends what's still
+// to change, ec2 rejecting the whole call when the group changed meanwhile
+const applyAttempts = 3
+
+// apply - authorize the rule's permissions the group lacks, renewing the expiry
+// of those it has that expire, permanent ones are left so, or revoke those it
+// has. Unchanged when there are none, ec2 finding some already so has the
+// group described again and the rest resent.
+func apply(ctx context.Context, svc EC2, operation string, rule Rule, dryrun bool) (unchanged bool, err error) {
+	sid, err := groupID(ctx, svc, rule)
+	if err != nil {
+		return false, err
+	}
+	group, err := Describe(ctx, svc, sid)
+	if err != nil {
+		return false, err
+	}
+	perms, err := rule.Permissions(ctx, svc, group)
+	if err != nil {
+		return false, err
+	}
+
+	revoke := operation == ec2ext.RevokeIngress || operation == ec2ext.RevokeEgress
+	update := ec2ext.UpdateIngressDescriptions
+	if rule.Egress {
+		update = ec2ext.UpdateEgressDescriptions
+	}
+	send := func(operation string, ipPermissions []*ec2ext.IpPermission) error {
+		if len(ipPermissions) == 0 {
+			return nil
+		}
+		req := ec2ext.RulesRequest(svc, operation, &ec2ext.RulesInput{
+			DryRun:        aws.Bool(dryrun),
+			GroupId:       aws.String(sid),
+			IpPermissions: ipPermissions,
+		})
+		if err := awscli.Send(ctx, req); err != nil {
+			return err
+		}
+		unchanged = false
+		return nil
+	}
+
+	unchanged = true
+	for attempt := 1; ; attempt++ {
+		current := group.Ingress
+		if rule.Egress {
+			current = group.Egress
+		}
+		have := make(map[string]Permission, len(current))
+		for _, p := range current {
+			have[p.Key()] = p
+		}
+		var ipPermissions, descriptions []*ec2ext.IpPermission
+		for _, p := range perms {
+			existing, ok := have[p.Key()]
+			_, temporary := expiry(existing.Description)
+			switch {
+			case revoke && ok:
+				ipPermissions = append(ipPermissions, existing.IPPermission())
+			case !revoke && !ok:
+				ipPermissions = append(ipPermissions, p.IPPermission())
+			case !revoke && rule.Expires != nil && temporary && existing.Description != p.Description:
+				descriptions = append(descriptions, p.IPPermission())
+			}
+		}
+		if len(ipPermissions) == 0 && len(descriptions) == 0 {
+			return unchanged, nil
+		}
+
+		failed, err := operation, send(operation, ipPermissions)
+		if err == nil {
+			failed, err = update, send(update, descriptions)
+		}
+		if err == nil {
+			return false, nil
+		}
+		if aerr, ok := err.(awserr.Error); !ok || aerr.Code() != unchangedCodes[failed] || attempt == applyAttempts {
+			return false, err
+		}
+		if group, err = Describe(ctx, svc, sid); err != nil {
+			return false, err
+		}
+	}
+}
+
+// groupID - the rule's group id, looked up by name when not set
+func groupID(ctx context.Context, svc ec2iface.EC2API, rule Rule) (string, error) {
+	if rule.GroupID != "" {
+		return rule.GroupID, nil
+	}
+	sid, err := LookupSGID(ctx, svc, rule.GroupName, GroupFilters(rule.VpcID, rule.GroupTags))
+	if err != nil {
+		return "", fmt.Errorf("failed to lookup sg '%s' by name: %s", rule.GroupName, err)
+	}
+	return sid, nil
+}
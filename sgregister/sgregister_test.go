@@ -0,0 +1,201 @@
+package sgregister_test
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+
+	"github.com/aidevops/awscli/fakeaws"
+	"github.com/aidevops/awscli/sgregister"
+)
+
+var _ = Describe("Sgregister", func() {
+
+	var (
+		srv  *fakeaws.Server
+		svc  *ec2.EC2
+		id   string
+		rule sgregister.Rule
+	)
+
+	BeforeEach(func() {
+		srv = fakeaws.New()
+		cli := srv.AwsCli()
+		svc = ec2.New(cli.Session(), cli.ServiceConfig(ec2.ServiceName))
+		id = srv.CreateSecurityGroup("web", "vpc-1")
+		rule = sgregister.Rule{GroupName: "web", CidrIPs: []string{"10.0.0.1/32"}, IPProtocol: "tcp", FromPort: 443, ToPort: 443}
+	})
+
+	AfterEach(func() {
+		srv.Close()
+	})
+
+	It("Looks up groups by name", func() {
+		sid, err := sgregister.LookupSGID(context.Background(), svc, "web", nil)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(sid).To(Equal(id))
+	})
+
+	It("Looks up names several vpcs have by vpc or tags, listing the candidates", func() {
+		other := srv.CreateSecurityGroup("web", "vpc-2")
+		srv.SetTags(other, map[string]string{"environment": "prod"})
+
+		_, err := sgregister.LookupSGID(context.Background(), svc, "web", nil)
+		Expect(err).To(MatchError(ContainSubstring("2 security groups named 'web' match")))
+		Expect(err).To(MatchError(ContainSubstring(id + " (vpc-1)")))
+		Expect(err).To(MatchError(ContainSubstring(other + " (vpc-2)")))
+
+		sid, err := sgregister.LookupSGID(context.Background(), svc, "web", sgregister.GroupFilters("vpc-1", nil))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(sid).To(Equal(id))
+
+		sid, err = sgregister.LookupSGID(context.Background(), svc, "web", sgregister.GroupFilters("", map[string]string{"environment": "prod"}))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(sid).To(Equal(other))
+
+		_, err = sgregister.LookupSGID(context.Background(), svc, "web", sgregister.GroupFilters("vpc-3", nil))
+		Expect(err).To(MatchError("no security group named 'web' matches vpc-id=vpc-3"))
+
+		rule.VpcID = "vpc-2"
+		_, err = sgregister.Register(context.Background(), svc, rule, false)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(srv.SecurityGroup(other).IpPermissions).To(HaveLen(1))
+		Expect(srv.SecurityGroup(id).IpPermissions).To(BeEmpty())
+	})
+
+	It("Fails to register with a group name nothing matches", func() {
+		rule.GroupName = "cache"
+		_, err := sgregister.Register(context.Background(), svc, rule, false)
+		Expect(err).To(MatchError("failed to lookup sg 'cache' by name: no security group named 'cache'"))
+	})
+
+	It("Registers and deregisters the rule", func() {
+		result, err := sgregister.Register(context.Background(), svc, rule, false)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result.Action).To(Equal("register"))
+		permissions := srv.SecurityGroup(id).IpPermissions
+		Expect(permissions).To(HaveLen(1))
+		Expect(aws.StringValue(permissions[0].IpRanges[0].CidrIp)).To(Equal("10.0.0.1/32"))
+
+		result, err = sgregister.Deregister(context.Background(), svc, rule, false)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result.Action).To(Equal("deregister"))
+		Expect(srv.SecurityGroup(id).IpPermissions).To(BeEmpty())
+	})
+
+	It("Reports a rule already registered or deregistered as unchanged", func() {
+		result, err := sgregister.Register(context.Background(), svc, rule, false)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result.Unchanged).To(BeFalse())
+		result, err = sgregister.Register(context.Background(), svc, rule, false)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result.Unchanged).To(BeTrue())
+		Expect(srv.SecurityGroup(id).IpPermissions).To(HaveLen(1))
+
+		result, err = sgregister.Deregister(context.Background(), svc, rule, false)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result.Unchanged).To(BeFalse())
+		result, err = sgregister.Deregister(context.Background(), svc, rule, false)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result.Unchanged).To(BeTrue())
+	})
+
+	It("Matches cidrs however they're written", func() {
+		rule.CidrIPs = []string{"2001:DB8::1/32", "10.0.0.1/24"}
+		result, err := sgregister.Register(context.Background(), svc, rule, false)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result.Unchanged).To(BeFalse())
+		perm := srv.SecurityGroup(id).IpPermissions[0]
+		Expect(aws.StringValue(perm.IpRanges[0].CidrIp)).To(Equal("10.0.0.0/24"))
+		Expect(aws.StringValue(perm.Ipv6Ranges[0].CidrIpv6)).To(Equal("2001:db8::/32"))
+
+		result, err = sgregister.Register(context.Background(), svc, rule, false)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result.Unchanged).To(BeTrue())
+		result, err = sgregister.Deregister(context.Background(), svc, rule, false)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result.Unchanged).To(BeFalse())
+		Expect(srv.SecurityGroup(id).IpPermissions).To(BeEmpty())
+	})
+
+	It("Resends the permissions still to change when ec2 rejects a call", func() {
+		srv.Fail("AuthorizeSecurityGroupIngress", "InvalidPermission.Duplicate", "the specified rule already exists")
+		result, err := sgregister.Register(context.Background(), svc, rule, false)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result.Unchanged).To(BeFalse())
+		Expect(srv.CallCount("AuthorizeSecurityGroupIngress")).To(Equal(2))
+		Expect(srv.SecurityGroup(id).IpPermissions).To(HaveLen(1))
+
+		srv.Fail("RevokeSecurityGroupIngress", "InvalidPermission.NotFound", "The specified rule does not exist")
+		result, err = sgregister.Deregister(context.Background(), svc, rule, false)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result.Unchanged).To(BeFalse())
+		Expect(srv.SecurityGroup(id).IpPermissions).To(BeEmpty())
+	})
+
+	It("Registers groups by name, id and account, cidrs and ipv6 in one call", func() {
+		db := srv.CreateSecurityGroup("db", "vpc-1")
+		rule.CidrIPs = []string{"10.0.0.1/32", "2001:db8::/64"}
+		rule.Groups = []string{"db", id, "210987654321/sg-0a1b2c3d"}
+		result, err := sgregister.Register(context.Background(), svc, rule, false)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result.Unchanged).To(BeFalse())
+
+		perm := srv.SecurityGroup(id).IpPermissions[0]
+		Expect(perm.IpRanges).To(HaveLen(1))
+		Expect(aws.StringValue(perm.Ipv6Ranges[0].CidrIpv6)).To(Equal("2001:db8::/64"))
+		var pairs []string
+		for _, pair := range perm.UserIdGroupPairs {
+			pairs = append(pairs, aws.StringValue(pair.UserId)+"/"+aws.StringValue(pair.GroupId))
+		}
+		Expect(pairs).To(ConsistOf(srv.Account+"/"+db, srv.Account+"/"+id, "210987654321/sg-0a1b2c3d"))
+		Expect(rule.String()).To(Equal("ingress tcp 443 from 10.0.0.1/32, 2001:db8::/64, db, " + id + ", 210987654321/sg-0a1b2c3d"))
+
+		rule.CidrIPs = append(rule.CidrIPs, "10.0.0.2/32")
+		result, err = sgregister.Register(context.Background(), svc, rule, false)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result.Unchanged).To(BeFalse())
+		Expect(srv.SecurityGroup(id).IpPermissions[0].IpRanges).To(HaveLen(2))
+
+		result, err = sgregister.Deregister(context.Background(), svc, rule, false)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(srv.SecurityGroup(id).IpPermissions).To(BeEmpty())
+	})
+
+	It("Registers and deregisters egress", func() {
+		rule = sgregister.Rule{GroupID: id, Egress: true, CidrIPs: []string{"::/0"}, IPProtocol: "icmpv6", FromPort: -1, ToPort: -1}
+		_, err := sgregister.Register(context.Background(), svc, rule, false)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(srv.SecurityGroup(id).IpPermissions).To(BeEmpty())
+		Expect(srv.SecurityGroup(id).IpPermissionsEgress).To(HaveLen(2))
+
+		_, err = sgregister.Deregister(context.Background(), svc, rule, false)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(srv.SecurityGroup(id).IpPermissionsEgress).To(HaveLen(1))
+	})
+
+	It("Validates protocols and ports", func() {
+		for message, r := range map[string]sgregister.Rule{
+			"tcp port range 443--1":               {IPProtocol: "tcp", FromPort: 443, ToPort: -1},
+			"icmp code 0 needs a type":            {IPProtocol: "icmp", FromPort: -1, ToPort: 0},
+			"protocol -1 has no ports":            {IPProtocol: "all", FromPort: 22, ToPort: 22},
+			"unknown protocol 'gre'":              {IPProtocol: "gre"},
+			"icmpv6 doesn't apply to 10.0.0.1/32": {IPProtocol: "icmpv6", FromPort: -1, ToPort: -1},
+		} {
+			r.GroupID, r.CidrIPs = id, []string{"10.0.0.1/32"}
+			_, err := sgregister.Register(context.Background(), svc, r, false)
+			Expect(err).To(MatchError(ContainSubstring(message)))
+		}
+		Expect(srv.SecurityGroup(id).IpPermissions).To(BeEmpty())
+	})
+
+	It("Returns other api errors", func() {
+		rule.GroupID = "sg-00000000"
+		_, err := sgregister.Register(context.Background(), svc, rule, false)
+		Expect(err).To(MatchError(ContainSubstring("InvalidGroup.NotFound")))
+	})
+})
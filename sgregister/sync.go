@@ -0,0 +1,331 @@
+package sgregister
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"gopkg.in/yaml.v2"
+
+	"github.com/aidevops/awscli"
+	"github.com/aidevops/awscli/ec2ext"
+)
+
+// Rules - a group's rules as a rules file declares them. A direction the file
+// leaves out is left alone, an empty one has every rule revoked.
+type Rules struct {
+	Ingress *[]RuleSpec `yaml:"ingress"`
+	Egress  *[]RuleSpec `yaml:"egress"`
+}
+
+// RuleSpec - the rules of one protocol and port range for several peers
+type RuleSpec struct {
+	Protocol string `yaml:"protocol"`
+	// Ports - tcp and udp ports, '443', '8000-8100' or 'all'
+	Ports string `yaml:"ports"`
+	// Type, Code - icmp and icmpv6 type and code, any when left out
+	Type *int64 `yaml:"type"`
+	Code *int64 `yaml:"code"`
+	// CIDRs - ipv4 and ipv6 cidrs
+	CIDRs []string `yaml:"cidrs"`
+	// Groups - security group names in the group's vpc, ids, or ids of another
+	// account as 'account/sg-1a2b'
+	Groups      []string `yaml:"groups"`
+	PrefixLists []string `yaml:"prefix_lists"`
+	Description string   `yaml:"description"`
+}
+
+// Group - a security group and its single rules
+type Group struct {
+	ID      string `json:"GroupId"`
+	Name    string `json:"GroupName"`
+	VpcID   string `json:"VpcId,omitempty"`
+	OwnerID string `json:"OwnerId"`
+	Ingress []Permission
+	Egress  []Permission
+}
+
+// Plan - the calls that bring a group's rules to those of a rules file,
+// Describe are the rules whose descriptions are updated
+type Plan struct {
+	GroupID   string       `json:"GroupId"`
+	Authorize []Permission `json:",omitempty"`
+	Revoke    []Permission `json:",omitempty"`
+	Describe  []Permission `json:",omitempty"`
+}
+
+// LoadRules - read a yaml or json rules file
+func LoadRules(path string) (*Rules, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	rules, err := ParseRules(b)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", path, err)
+	}
+	return rules, nil
+}
+
+// ParseRules - parse yaml or json rules, checking every permission they make
+func ParseRules(b []byte) (*Rules, error) {
+	rules := &Rules{}
+	if err := yaml.Unmarshal(b, rules); err != nil {
+		return nil, err
+	}
+	if rules.Ingress == nil && rules.Egress == nil {
+		return nil, fmt.Errorf("neither ingress nor egress rules")
+	}
+	// group names are resolved later, any id stands in for them
+	_, err := rules.permissions(func(string) (string, error) { return "sg-0", nil })
+	return rules, err
+}
+
+// Describe - the group with its rules
+func Describe(ctx context.Context, svc ec2ext.Client, id string) (*Group, error) {
+	req, out := ec2ext.DescribeSecurityGroupsRequest(svc, &ec2.DescribeSecurityGroupsInput{GroupIds: aws.StringSlice([]string{id})})
+	if err := awscli.Send(ctx, req); err != nil {
+		return nil, err
+	}
+	if len(out.SecurityGroups) != 1 {
+		return nil, fmt.Errorf("security group '%s' not found", id)
+	}
+	sg := out.SecurityGroups[0]
+	owner := aws.StringValue(sg.OwnerId)
+	return &Group{
+		ID:      aws.StringValue(sg.GroupId),
+		Name:    aws.StringValue(sg.GroupName),
+		VpcID:   aws.StringValue(sg.VpcId),
+		OwnerID: owner,
+		Ingress: Flatten(sg.IpPermissions, false, owner),
+		Egress:  Flatten(sg.IpPermissionsEgress, true, owner),
+	}, nil
+}
+
+// PlanSync - the plan bringing the group id's rules to rules. Group names are
+// looked up in the group's vpc.
+func PlanSync(ctx context.Context, svc EC2, id string, rules *Rules) (*Plan, error) {
+	group, err := Describe(ctx, svc, id)
+	if err != nil {
+		return nil, err
+	}
+
+	names := make(map[string]string)
+	desired, err := rules.permissions(func(name string) (string, error) {
+		if _, ok := names[name]; !ok {
+			gid, err := LookupSGID(ctx, svc, name, GroupFilters(group.VpcID, nil))
+			if err != nil {
+				return "", fmt.Errorf("failed to lookup sg '%s' by name: %s", name, err)
+			}
+			names[name] = gid
+		}
+		return names[name], nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var current []Permission
+	if rules.Ingress != nil {
+		current = append(current, group.Ingress...)
+	}
+	if rules.Egress != nil {
+		current = append(current, group.Egress...)
+	}
+	plan := diff(current, desired)
+	plan.GroupID = group.ID
+	return plan, nil
+}
+
+// Apply - make the plan's calls: authorize, update descriptions, then revoke,
+// so no access is lost in between. One call per direction and kind.
+func Apply(ctx context.Context, svc ec2ext.Client, plan *Plan) error {
+	calls := []struct {
+		ingress, egress string
+		perms           []Permission
+	}{
+		{ec2ext.AuthorizeIngress, ec2ext.AuthorizeEgress, plan.Authorize},
+		{ec2ext.UpdateIngressDescriptions, ec2ext.UpdateEgressDescriptions, plan.Describe},
+		{ec2ext.RevokeIngress, ec2ext.RevokeEgress, plan.Revoke},
+	}
+	for _, call := range calls {
+		var ingress, egress []*ec2ext.IpPermission
+		for _, p := range call.perms {
+			if p.Egress {
+				egress = append(egress, p.IPPermission())
+			} else {
+				ingress = append(ingress, p.IPPermission())
+			}
+		}
+		if err := send(ctx, svc, call.ingress, plan.GroupID, ingress); err != nil {
+			return err
+		}
+		if err := send(ctx, svc, call.egress, plan.GroupID, egress); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Empty - nothing to change
+func (p *Plan) Empty() bool {
+	return len(p.Authorize) == 0 && len(p.Revoke) == 0 && len(p.Describe) == 0
+}
+
+// Diff - '+' for a rule authorized, '-' revoked and '~' redescribed
+func (p *Plan) Diff() []string {
+	var lines []string
+	for _, perm := range p.Authorize {
+		lines = append(lines, "+ "+perm.String())
+	}
+	for _, perm := range p.Describe {
+		lines = append(lines, "~ "+perm.String())
+	}
+	for _, perm := range p.Revoke {
+		lines = append(lines, "- "+perm.String())
+	}
+	return lines
+}
+
+// send - one rule operation on the group, none without permissions
+func send(ctx context.Context, svc ec2ext.Client, operation, groupID string, perms []*ec2ext.IpPermission) error {
+	if len(perms) == 0 {
+		return nil
+	}
+	req := ec2ext.RulesRequest(svc, operation, &ec2ext.RulesInput{GroupId: aws.String(groupID), IpPermissions: perms})
+	if err := awscli.Send(ctx, req); err != nil {
+		return fmt.Errorf("%s: %s", operation, err)
+	}
+	return nil
+}
+
+// diff - what turns current into desired, in rule order
+func diff(current, desired []Permission) *Plan {
+	plan := &Plan{}
+	have := make(map[string]Permission, len(current))
+	for _, p := range current {
+		have[p.Key()] = p
+	}
+	want := make(map[string]bool, len(desired))
+	for _, p := range desired {
+		if want[p.Key()] {
+			continue
+		}
+		want[p.Key()] = true
+		existing, ok := have[p.Key()]
+		switch {
+		case !ok:
+			plan.Authorize = append(plan.Authorize, p)
+		case existing.Description != p.Description:
+			plan.Describe = append(plan.Describe, p)
+		}
+	}
+	for _, p := range current {
+		if !want[p.Key()] {
+			plan.Revoke = append(plan.Revoke, p)
+		}
+	}
+	return plan
+}
+
+// permissions - the single permissions of the rules, resolve turns a group
+// name into its id
+func (r *Rules) permissions(resolve func(name string) (string, error)) ([]Permission, error) {
+	var perms []Permission
+	for _, direction := range []struct {
+		egress bool
+		specs  *[]RuleSpec
+	}{{false, r.Ingress}, {true, r.Egress}} {
+		if direction.specs == nil {
+			continue
+		}
+		for i, spec := range *direction.specs {
+			ps, err := spec.permissions(direction.egress, resolve)
+			if err != nil {
+				name := "ingress"
+				if direction.egress {
+					name = "egress"
+				}
+				return nil, fmt.Errorf("%s rule %d: %s", name, i+1, err)
+			}
+			perms = append(perms, ps...)
+		}
+	}
+	return perms, nil
+}
+
+// permissions - one for each peer of the spec
+func (s RuleSpec) permissions(egress bool, resolve func(name string) (string, error)) ([]Permission, error) {
+	from, to, err := s.ports()
+	if err != nil {
+		return nil, err
+	}
+
+	peers := append(append([]string{}, s.CIDRs...), s.PrefixLists...)
+	for _, group := range s.Groups {
+		if !groupPeer.MatchString(group) {
+			if group, err = resolve(group); err != nil {
+				return nil, err
+			}
+		}
+		peers = append(peers, group)
+	}
+	if len(peers) == 0 {
+		return nil, fmt.Errorf("no cidrs, groups or prefix lists")
+	}
+
+	var perms []Permission
+	for _, peer := range peers {
+		p, err := NewPermission(egress, s.Protocol, from, to, peer, s.Description)
+		if err != nil {
+			return nil, err
+		}
+		perms = append(perms, p)
+	}
+	return perms, nil
+}
+
+// ports - the from and to of the spec's protocol
+func (s RuleSpec) ports() (int64, int64, error) {
+	switch strings.ToLower(s.Protocol) {
+	case "tcp", "udp", "6", "17":
+		switch s.Ports {
+		case "":
+			return 0, 0, fmt.Errorf("%s needs ports", s.Protocol)
+		case "all":
+			return 0, 65535, nil
+		}
+		bounds := strings.SplitN(s.Ports, "-", 2)
+		from, err := strconv.ParseInt(strings.TrimSpace(bounds[0]), 10, 64)
+		if err != nil {
+			return 0, 0, fmt.Errorf("ports '%s' aren't a port or 'from-to' range", s.Ports)
+		}
+		to := from
+		if len(bounds) == 2 {
+			if to, err = strconv.ParseInt(strings.TrimSpace(bounds[1]), 10, 64); err != nil {
+				return 0, 0, fmt.Errorf("ports '%s' aren't a port or 'from-to' range", s.Ports)
+			}
+		}
+		return from, to, nil
+	case "icmp", "icmpv6", "1", "58":
+		if s.Ports != "" {
+			return 0, 0, fmt.Errorf("%s takes a type and code, not ports", s.Protocol)
+		}
+		from, to := int64(-1), int64(-1)
+		if s.Type != nil {
+			from = *s.Type
+		}
+		if s.Code != nil {
+			to = *s.Code
+		}
+		return from, to, nil
+	}
+	if s.Ports != "" || s.Type != nil || s.Code != nil {
+		return 0, 0, fmt.Errorf("protocol %s has no ports", s.Protocol)
+	}
+	return 0, 0, nil
+}
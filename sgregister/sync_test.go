@@ -0,0 +1,154 @@
+package sgregister_test
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+
+	"github.com/aidevops/awscli/fakeaws"
+	"github.com/aidevops/awscli/sgregister"
+)
+
+var _ = Describe("Sync", func() {
+
+	var (
+		srv *fakeaws.Server
+		svc *ec2.EC2
+		id  string
+		db  string
+	)
+
+	BeforeEach(func() {
+		srv = fakeaws.New()
+		cli := srv.AwsCli()
+		svc = ec2.New(cli.Session(), cli.ServiceConfig(ec2.ServiceName))
+		id = srv.CreateSecurityGroup("web", "vpc-1")
+		db = srv.CreateSecurityGroup("db", "vpc-1")
+	})
+
+	AfterEach(func() {
+		srv.Close()
+	})
+
+	sync := func(yaml string) *sgregister.Plan {
+		rules, err := sgregister.ParseRules([]byte(yaml))
+		Expect(err).NotTo(HaveOccurred())
+		plan, err := sgregister.PlanSync(context.Background(), svc, id, rules)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(sgregister.Apply(context.Background(), svc, plan)).To(Succeed())
+		return plan
+	}
+
+	It("Converges the group on the rules", func() {
+		plan := sync(`
+ingress:
+  - protocol: tcp
+    ports: 443
+    cidrs: [10.0.0.0/8, "2001:db8::/32"]
+    description: https
+  - protocol: tcp
+    ports: 8000-8100
+    groups: [db]
+  - protocol: icmp
+    type: 8
+    cidrs: [10.0.0.0/8]
+egress:
+  - protocol: all
+    cidrs: [0.0.0.0/0]
+`)
+		Expect(plan.Diff()).To(Equal([]string{
+			`+ ingress tcp 443 from 10.0.0.0/8 "https"`,
+			`+ ingress tcp 443 from 2001:db8::/32 "https"`,
+			"+ ingress tcp 8000-8100 from " + db,
+			"+ ingress icmp type 8 code -1 from 10.0.0.0/8",
+		}))
+
+		group := srv.SecurityGroup(id)
+		Expect(group.IpPermissions).To(HaveLen(3))
+		Expect(group.IpPermissionsEgress).To(HaveLen(1))
+
+		plan = sync(`
+ingress:
+  - protocol: tcp
+    ports: 443
+    cidrs: [10.0.0.0/8]
+    description: internal https
+egress: []
+`)
+		Expect(plan.Diff()).To(Equal([]string{
+			`~ ingress tcp 443 from 10.0.0.0/8 "internal https"`,
+			`- ingress tcp 443 from 2001:db8::/32 "https"`,
+			"- ingress tcp 8000-8100 from " + db,
+			"- ingress icmp type 8 code -1 from 10.0.0.0/8",
+			"- egress all to 0.0.0.0/0",
+		}))
+
+		group = srv.SecurityGroup(id)
+		Expect(group.IpPermissionsEgress).To(BeEmpty())
+		Expect(group.IpPermissions).To(HaveLen(1))
+		Expect(aws.StringValue(group.IpPermissions[0].IpRanges[0].Description)).To(Equal("internal https"))
+	})
+
+	It("Leaves a direction the rules leave out alone", func() {
+		plan := sync(`
+ingress: []
+`)
+		Expect(plan.Empty()).To(BeTrue())
+		Expect(srv.SecurityGroup(id).IpPermissionsEgress).To(HaveLen(1))
+	})
+
+	It("Plans nothing once converged", func() {
+		rules := `
+ingress:
+  - protocol: udp
+    ports: 53
+    groups: [` + db + `]
+`
+		Expect(sync(rules).Diff()).To(HaveLen(1))
+		Expect(sync(rules).Empty()).To(BeTrue())
+	})
+
+	It("Keeps the descriptions of prefix lists", func() {
+		rules := `
+egress:
+  - protocol: tcp
+    ports: 443
+    prefix_lists: [pl-1a2b3c4d]
+    description: s3
+`
+		Expect(sync(rules).Diff()).To(Equal([]string{
+			`+ egress tcp 443 to pl-1a2b3c4d "s3"`,
+			"- egress all to 0.0.0.0/0",
+		}))
+		lists := srv.SecurityGroup(id).IpPermissionsEgress[0].PrefixListIds
+		Expect(lists).To(HaveLen(1))
+		Expect(aws.StringValue(lists[0].Description)).To(Equal("s3"))
+		Expect(sync(rules).Empty()).To(BeTrue())
+	})
+
+	It("Rejects invalid rules", func() {
+		for yaml, message := range map[string]string{
+			"": "neither ingress nor egress",
+			"ingress: [{protocol: tcp, cidrs: [10.0.0.0/8]}]":          "ingress rule 1: tcp needs ports",
+			"egress: [{protocol: tcp, ports: 99999, cidrs: ['::/0']}]": "egress rule 1: tcp port range",
+			"ingress: [{protocol: icmp, cidrs: ['::/0']}]":             "icmp doesn't apply to ::/0",
+			"ingress: [{protocol: all, ports: 22, cidrs: ['::/0']}]":   "protocol all has no ports",
+			"ingress: [{protocol: tcp, ports: 22}]":                    "no cidrs, groups or prefix lists",
+			"ingress: [{protocol: tcp, ports: 22, cidrs: [x]}]":        "peer 'x' is neither",
+		} {
+			_, err := sgregister.ParseRules([]byte(yaml))
+			Expect(err).To(MatchError(ContainSubstring(message)), yaml)
+		}
+	})
+
+	It("Fails on unknown group names", func() {
+		rules, err := sgregister.ParseRules([]byte("ingress: [{protocol: tcp, ports: 22, groups: [cache]}]"))
+		Expect(err).NotTo(HaveOccurred())
+		_, err = sgregister.PlanSync(context.Background(), svc, id, rules)
+		Expect(err).To(MatchError(ContainSubstring("failed to lookup sg 'cache' by name: no security group named 'cache' matches vpc-id=vpc-1")))
+	})
+})
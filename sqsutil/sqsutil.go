@@ -0,0 +1,85 @@
+// Package sqsutil - send and receive sqs messages
+package sqsutil
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/sqs"
+	"github.com/aws/aws-sdk-go/service/sqs/sqsiface"
+
+	"github.com/aidevops/awscli"
+)
+
+// VisibilityTimeout - http://docs.aws.amazon.com/AWSSimpleQueueService/latest/SQSDeveloperGuide/AboutVT.html
+const VisibilityTimeout = 1
+
+// WaitTimeSeconds - The duration (in seconds) for which the call will wait for a message to arrive in the queue before returning.
+const WaitTimeSeconds = 10
+
+// MessageAttributeNames - the message attributes Receive asks for
+var MessageAttributeNames = []string{"id", "node", "role", "instance", "registered"}
+
+// QueueURL - return the url of a queue by its name
+func QueueURL(ctx context.Context, svc sqsiface.SQSAPI, account, queue string) (string, error) {
+	req, resp := svc.GetQueueUrlRequest(&sqs.GetQueueUrlInput{
+		QueueName:              aws.String(queue), // Required
+		QueueOwnerAWSAccountId: aws.String(account),
+	})
+	if err := awscli.Send(ctx, req); err != nil {
+		return "", fmt.Errorf("failed to lookup queue by name '%s' %s", queue, err.Error())
+	}
+	return aws.StringValue(resp.QueueUrl), nil
+}
+
+// BuildQueueURL - Builds the url based on provided input instead of querying AWS sqs,
+// endpoint overrides the regional sqs endpoint when not empty.
+func BuildQueueURL(endpoint, account, region, queue string) string {
+	if endpoint != "" {
+		return fmt.Sprintf("%s/%s/%s", strings.TrimSuffix(endpoint, "/"), account, queue)
+	}
+	return fmt.Sprintf("https://sqs.%s.amazonaws.com/%s/%s", region, account, queue)
+}
+
+// Send - send a message with string attributes to the queue at queueURL
+func Send(ctx context.Context, svc sqsiface.SQSAPI, queueURL, message string, attributes map[string]string) (*sqs.SendMessageOutput, error) {
+	params := &sqs.SendMessageInput{
+		MessageBody:  aws.String(message),
+		QueueUrl:     aws.String(queueURL),
+		DelaySeconds: aws.Int64(1),
+	}
+	if len(attributes) > 0 {
+		attrs := make(map[string]*sqs.MessageAttributeValue, len(attributes))
+		for k, v := range attributes {
+			attrs[k] = &sqs.MessageAttributeValue{DataType: aws.String("String"), StringValue: aws.String(v)}
+		}
+		params.MessageAttributes = attrs
+	}
+
+	req, resp := svc.SendMessageRequest(params)
+	if err := awscli.Send(ctx, req); err != nil {
+		return nil, fmt.Errorf("Could not send message '%s' to queue '%s': %s", message, queueURL, err)
+	}
+	return resp, nil
+}
+
+// Receive - receive up to count messages from the queue at queueURL
+func Receive(ctx context.Context, svc sqsiface.SQSAPI, queueURL string, count int64) (*sqs.ReceiveMessageOutput, error) {
+	if count < 1 || count > 10 {
+		return nil, fmt.Errorf("invalid count valid values 1 - 10, received: %d", count)
+	}
+
+	req, resp := svc.ReceiveMessageRequest(&sqs.ReceiveMessageInput{
+		QueueUrl:              aws.String(queueURL),
+		MaxNumberOfMessages:   aws.Int64(count),
+		MessageAttributeNames: aws.StringSlice(MessageAttributeNames),
+		VisibilityTimeout:     aws.Int64(VisibilityTimeout),
+		WaitTimeSeconds:       aws.Int64(WaitTimeSeconds),
+	})
+	if err := awscli.Send(ctx, req); err != nil {
+		return nil, fmt.Errorf("Could not receive message(s) from queue '%s': %s", queueURL, err)
+	}
+	return resp, nil
+}
@@ -0,0 +1,67 @@
+package sqsutil_test
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/sqs"
+
+	"github.com/aidevops/awscli/fakeaws"
+	"github.com/aidevops/awscli/sqsutil"
+)
+
+var _ = Describe("Sqsutil", func() {
+
+	var (
+		srv *fakeaws.Server
+		svc *sqs.SQS
+		ctx context.Context
+	)
+
+	BeforeEach(func() {
+		srv = fakeaws.New()
+		cli := srv.AwsCli()
+		svc = sqs.New(cli.Session(), cli.ServiceConfig(sqs.ServiceName))
+		ctx = context.Background()
+	})
+
+	AfterEach(func() {
+		srv.Close()
+	})
+
+	It("Looks up the queue url", func() {
+		url := srv.CreateQueue("jobs")
+		found, err := sqsutil.QueueURL(ctx, svc, srv.Account, "jobs")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(found).To(Equal(url))
+
+		_, err = sqsutil.QueueURL(ctx, svc, srv.Account, "missing")
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("Builds the queue url", func() {
+		Expect(sqsutil.BuildQueueURL("", "123456789012", "eu-west-1", "jobs")).To(Equal("https://sqs.eu-west-1.amazonaws.com/123456789012/jobs"))
+		Expect(sqsutil.BuildQueueURL("http://localhost:4566/", "123456789012", "eu-west-1", "jobs")).To(Equal("http://localhost:4566/123456789012/jobs"))
+	})
+
+	It("Sends messages with attributes", func() {
+		url := srv.CreateQueue("jobs")
+		resp, err := sqsutil.Send(ctx, svc, url, "hello", map[string]string{"role": "web"})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(resp.MessageId).NotTo(BeNil())
+
+		messages := srv.Messages("jobs")
+		Expect(messages).To(HaveLen(1))
+		Expect(aws.StringValue(messages[0].Body)).To(Equal("hello"))
+		Expect(aws.StringValue(messages[0].MessageAttributes["role"].StringValue)).To(Equal("web"))
+	})
+
+	It("Rejects invalid counts", func() {
+		_, err := sqsutil.Receive(ctx, svc, srv.CreateQueue("jobs"), 11)
+		Expect(err).To(MatchError(ContainSubstring("invalid count")))
+		Expect(srv.CallCount("ReceiveMessage")).To(Equal(0))
+	})
+})